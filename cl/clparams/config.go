@@ -84,6 +84,13 @@ type CaplinConfig struct {
 	BootstrapNodes []string
 	StaticPeers    []string
 
+	// MaxPendingProposerSlashings bounds the proposer slashings waiting for inclusion, 0 means the default,
+	// see --caplin.max-pending-proposer-slashings
+	MaxPendingProposerSlashings uint64
+	// ProposerSlashingQueueDepth bounds the proposer slashings being processed and the queued ones, 0 means
+	// the default, see --caplin.proposer-slashing-queue-depth
+	ProposerSlashingQueueDepth uint64
+
 	// Extra
 	EnableEngineAPI bool
 }
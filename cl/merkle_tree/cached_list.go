@@ -0,0 +1,159 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package merkle_tree
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/erigontech/erigon-lib/types/ssz"
+	"github.com/erigontech/erigon/cl/utils"
+)
+
+// CachedListHasher computes the same root as ListObjectSSZRoot, but keeps the
+// element roots and the inner nodes of the tree between calls. Only the
+// elements invalidated with Invalidate, the new ones and their ancestors are
+// rehashed on the next call.
+type CachedListHasher[T ssz.HashableSSZ] struct {
+	limit uint64
+	// layers are the layers of the tree, from the element roots up, see merkleLayers
+	layers [][][32]byte
+	dirty  map[int]struct{}
+	mu     sync.Mutex
+}
+
+func NewCachedListHasher[T ssz.HashableSSZ](limit uint64) *CachedListHasher[T] {
+	return &CachedListHasher[T]{limit: limit, dirty: make(map[int]struct{})}
+}
+
+// Invalidate marks the element at index as changed since the last HashSSZ.
+func (c *CachedListHasher[T]) Invalidate(index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirty[index] = struct{}{}
+}
+
+// Reset drops the cache, the next HashSSZ rehashes every element.
+func (c *CachedListHasher[T]) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.layers = nil
+	clear(c.dirty)
+}
+
+// HashSSZ returns the root of list. The elements not invalidated since the
+// previous call are assumed to be unchanged. On error the cache is dropped, as
+// it may hold a partial update.
+func (c *CachedListHasher[T]) HashSSZ(list []T) ([32]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer clear(c.dirty)
+
+	root, err := c.hash(list)
+	if err != nil {
+		c.layers = nil
+		return [32]byte{}, err
+	}
+	return root, nil
+}
+
+func (c *CachedListHasher[T]) hash(list []T) ([32]byte, error) {
+	if uint64(len(list)) > c.limit {
+		return [32]byte{}, fmt.Errorf("list of %d elements exceeds the limit of %d", len(list), c.limit)
+	}
+	depth := GetDepth(c.limit)
+	if c.layers == nil || len(c.dirty) > len(list)/2 {
+		if err := c.rebuild(list, depth); err != nil {
+			return [32]byte{}, err
+		}
+		return c.root(len(list)), nil
+	}
+
+	prevLen := len(c.layers[0])
+	if len(list) != prevLen {
+		// the last element gets a new sibling, or loses it
+		c.dirty[min(prevLen, len(list))-1] = struct{}{}
+		for index := prevLen; index < len(list); index++ {
+			c.dirty[index] = struct{}{}
+		}
+		c.resize(len(list))
+	}
+	indices := make([]int, 0, len(c.dirty))
+	for index := range c.dirty {
+		if index >= 0 && index < len(list) {
+			indices = append(indices, index)
+		}
+	}
+	slices.Sort(indices)
+	for _, index := range indices {
+		root, err := list[index].HashSSZ()
+		if err != nil {
+			return [32]byte{}, err
+		}
+		c.layers[0][index] = root
+	}
+	for level := 0; level < int(depth); level++ {
+		layer := c.layers[level]
+		n := 0
+		for _, index := range indices {
+			parent := index / 2
+			if n > 0 && indices[n-1] == parent {
+				continue
+			}
+			right := ZeroHashes[level]
+			if 2*parent+1 < len(layer) {
+				right = layer[2*parent+1]
+			}
+			c.layers[level+1][parent] = utils.Sha256(layer[2*parent][:], right[:])
+			indices[n] = parent
+			n++
+		}
+		indices = indices[:n]
+	}
+	return c.root(len(list)), nil
+}
+
+func (c *CachedListHasher[T]) rebuild(list []T, depth uint8) error {
+	leaves := make([][32]byte, len(list))
+	for i, element := range list {
+		root, err := element.HashSSZ()
+		if err != nil {
+			return err
+		}
+		leaves[i] = root
+	}
+	layers, err := merkleLayers(leaves, depth)
+	if err != nil {
+		return err
+	}
+	c.layers = layers
+	return nil
+}
+
+// resize sets the number of elements to size, each layer holding the parents of
+// the previous one.
+func (c *CachedListHasher[T]) resize(size int) {
+	for level := range c.layers {
+		c.layers[level] = slices.Grow(c.layers[level][:min(size, len(c.layers[level]))], size)[:size]
+		size = (size + 1) / 2
+	}
+}
+
+func (c *CachedListHasher[T]) root(size int) [32]byte {
+	return MixInLength(layerNode(c.layers, 1), uint64(size))
+}
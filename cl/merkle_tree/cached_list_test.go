@@ -0,0 +1,144 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package merkle_tree_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon/cl/merkle_tree"
+)
+
+type testElement struct {
+	value uint64
+	err   error // returned once by HashSSZ
+}
+
+func (e *testElement) HashSSZ() ([32]byte, error) {
+	if err := e.err; err != nil {
+		e.err = nil
+		return [32]byte{}, err
+	}
+	return merkle_tree.Uint64Root(e.value), nil
+}
+
+func testElements(n int) []*testElement {
+	elements := make([]*testElement, n)
+	for i := range elements {
+		elements[i] = &testElement{value: uint64(i)}
+	}
+	return elements
+}
+
+func TestCachedListHasher(t *testing.T) {
+	const limit = 1 << 40
+	elements := testElements(1000)
+	hasher := merkle_tree.NewCachedListHasher[*testElement](limit)
+	check := func(msg string) {
+		t.Helper()
+		want, err := merkle_tree.ListObjectSSZRoot(elements, limit)
+		require.NoError(t, err)
+		got, err := hasher.HashSSZ(elements)
+		require.NoError(t, err)
+		require.Equal(t, want, got, msg)
+	}
+
+	check("initial")
+	check("unchanged")
+	for _, i := range []int{0, 1, 500, 998, 999} {
+		elements[i].value += 1000
+		hasher.Invalidate(i)
+	}
+	check("invalidated")
+	elements = append(elements, testElements(3)...)
+	check("grown to odd")
+	elements = append(elements, testElements(1)...)
+	check("grown to even")
+	elements = elements[:777]
+	check("shrunk")
+	elements[776].value++
+	elements = elements[:1]
+	hasher.Invalidate(0)
+	check("single")
+	elements = elements[:0]
+	check("empty")
+	elements = testElements(10)
+	check("refilled")
+
+	// changes that are not invalidated are not seen until a reset
+	elements[3].value++
+	want, err := hasher.HashSSZ(elements)
+	require.NoError(t, err)
+	elements[3].value--
+	stale, err := merkle_tree.ListObjectSSZRoot(elements, limit)
+	require.NoError(t, err)
+	require.Equal(t, stale, want)
+	elements[3].value++
+	hasher.Reset()
+	check("reset")
+}
+
+func TestCachedListHasherError(t *testing.T) {
+	const limit = 1 << 40
+	elements := testElements(10)
+	hasher := merkle_tree.NewCachedListHasher[*testElement](limit)
+	_, err := hasher.HashSSZ(elements)
+	require.NoError(t, err)
+
+	for _, i := range []int{2, 5} {
+		elements[i].value += 1000
+		hasher.Invalidate(i)
+	}
+	errHash := errors.New("hash failed")
+	elements[5].err = errHash
+	_, err = hasher.HashSSZ(elements)
+	require.ErrorIs(t, err, errHash)
+
+	// the invalidations are not lost with the failed call
+	want, err := merkle_tree.ListObjectSSZRoot(elements, limit)
+	require.NoError(t, err)
+	got, err := hasher.HashSSZ(elements)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func BenchmarkCachedListHasher(b *testing.B) {
+	const limit = 1 << 40
+	elements := testElements(1 << 18)
+	b.Run("ListObjectSSZRoot", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			elements[i%len(elements)].value++
+			if _, err := merkle_tree.ListObjectSSZRoot(elements, limit); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("CachedListHasher", func(b *testing.B) {
+		hasher := merkle_tree.NewCachedListHasher[*testElement](limit)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			elements[i%len(elements)].value++
+			hasher.Invalidate(i % len(elements))
+			if _, err := hasher.HashSSZ(elements); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -21,21 +21,71 @@ import (
 
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon/cl/utils"
+	"github.com/erigontech/erigon/cl/utils/threading"
 	"github.com/prysmaticlabs/gohashtree"
 )
 
-var globalHasher *merkleHasher
+// hasherPool holds the merkleHashers, so that independent calls do not contend
+// on a shared buffer.
+var hasherPool = sync.Pool{
+	New: func() any { return newMerkleHasher() },
+}
+
+func getMerkleHasher() *merkleHasher {
+	return hasherPool.Get().(*merkleHasher)
+}
+
+func putMerkleHasher(m *merkleHasher) {
+	hasherPool.Put(m)
+}
+
+// Hasher hashes the layers of a merkle tree.
+type Hasher interface {
+	// Hash writes the hash of each pair of chunks to digests, see gohashtree.Hash.
+	// digests and chunks may overlap as long as digests starts at chunks.
+	Hash(digests [][32]byte, chunks [][32]byte) error
+}
+
+// DefaultHasher is the Hasher of MerkleizeVector.
+var DefaultHasher Hasher = gohashtreeHasher{}
+
+type gohashtreeHasher struct{}
+
+func (gohashtreeHasher) Hash(digests [][32]byte, chunks [][32]byte) error {
+	return gohashtree.Hash(digests, chunks)
+}
 
 const initialBufferSize = 0 // it is whatever
 
 // merkleHasher is used internally to provide shared buffer internally to the merkle_tree package.
+// It is not safe for concurrent use, see getMerkleHasher.
 type merkleHasher struct {
 	// internalBuffer is the shared buffer we use for each operation
-	internalBuffer           [][32]byte
-	internalBufferForSSZList [][32]byte
-	// mu is the lock to ensure thread safety
-	mu  sync.Mutex
-	mu2 sync.Mutex // lock onto ssz list buffer
+	internalBuffer [][32]byte
+}
+
+// leavesPool holds the scratch buffers of the list and vector roots, so that
+// they can be computed concurrently without allocating.
+var leavesPool = sync.Pool{
+	New: func() any { return new([][32]byte) },
+}
+
+// getLeavesBuffer returns a pooled buffer of size leaves, with room for the zero
+// hash MerkleizeVector appends to odd layers.
+func getLeavesBuffer(size int) *[][32]byte {
+	buf := leavesPool.Get().(*[][32]byte)
+	if cap(*buf) < size+1 {
+		*buf = make([][32]byte, size, size*2+1)
+	}
+	*buf = (*buf)[:size]
+	return buf
+}
+
+// putLeavesBuffer zeroes buf and returns it to the pool.
+func putLeavesBuffer(buf *[][32]byte) {
+	clear((*buf)[:cap(*buf)])
+	*buf = (*buf)[:0]
+	leavesPool.Put(buf)
 }
 
 func newMerkleHasher() *merkleHasher {
@@ -50,8 +100,6 @@ func (m *merkleHasher) merkleizeTrieLeavesFlat(leaves []byte, out []byte, limit
 }
 
 func (m *merkleHasher) merkleizeTrieLeavesFlatWithStart(leaves []byte, out []byte, limit, start uint64) (err error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
 	layer := m.getBufferFromFlat(leaves)
 	for i := uint8(start); i < GetDepth(limit); i++ {
 		layerLen := len(layer)
@@ -75,14 +123,7 @@ func (m *merkleHasher) getBuffer(size int) [][32]byte {
 	return m.internalBuffer[:size]
 }
 
-// getBuffer provides buffer of given size.
-func (m *merkleHasher) getBufferForSSZList(size int) [][32]byte {
-	if size > len(m.internalBufferForSSZList) {
-		m.internalBufferForSSZList = make([][32]byte, size*2)
-	}
-	return m.internalBufferForSSZList[:size]
-}
-
+// getBufferFromFlat provides a buffer holding the 32 bytes chunks of xs.
 func (m *merkleHasher) getBufferFromFlat(xs []byte) [][32]byte {
 	buf := m.getBuffer(len(xs) / 32)
 	for i := 0; i < len(xs)/32; i = i + 1 {
@@ -91,22 +132,39 @@ func (m *merkleHasher) getBufferFromFlat(xs []byte) [][32]byte {
 	return buf
 }
 
-func (m *merkleHasher) transactionsListRoot(transactions [][]byte) ([32]byte, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// parallelTransactionsThreshold is the number of transactions from which their
+// roots are computed across workers.
+const parallelTransactionsThreshold = 256
+
+func (m *merkleHasher) transactionsListRoot(transactions [][]byte, workers int) ([32]byte, error) {
 	txCount := uint64(len(transactions))
 
 	leaves := m.getBuffer(len(transactions))
-	for i, transaction := range transactions {
+	transactionRoot := func(i int) error {
+		transaction := transactions[i]
 		transactionLength := uint64(len(transaction))
-		packedTransactions := packBits(transaction) // Pack transactions
-		transactionsBaseRoot, err := MerkleizeVector(packedTransactions, 33554432)
+		packedTransactions := getLeavesBuffer(0)
+		*packedTransactions = packBits(*packedTransactions, transaction) // Pack transactions
+		transactionsBaseRoot, err := MerkleizeVector(*packedTransactions, 33554432)
+		putLeavesBuffer(packedTransactions)
 		if err != nil {
-			return [32]byte{}, err
+			return err
 		}
 
 		lengthRoot := Uint64Root(transactionLength)
 		leaves[i] = utils.Sha256(transactionsBaseRoot[:], lengthRoot[:])
+		return nil
+	}
+	if workers > 1 && len(transactions) >= parallelTransactionsThreshold {
+		if err := threading.ParallellForLoop(workers, 0, len(transactions), transactionRoot); err != nil {
+			return [32]byte{}, err
+		}
+	} else {
+		for i := range transactions {
+			if err := transactionRoot(i); err != nil {
+				return [32]byte{}, err
+			}
+		}
 	}
 	transactionsBaseRoot, err := MerkleizeVector(leaves, 1048576)
 	if err != nil {
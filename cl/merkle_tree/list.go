@@ -17,56 +17,172 @@
 package merkle_tree
 
 import (
+	"fmt"
 	"math/bits"
-
-	"github.com/prysmaticlabs/gohashtree"
+	"runtime"
+	"slices"
+	"sync"
 
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/length"
 	"github.com/erigontech/erigon-lib/types/ssz"
 
 	"github.com/erigontech/erigon/cl/utils"
+	"github.com/erigontech/erigon/cl/utils/threading"
 )
 
+// parallelMerkleizeThreshold is the layer width from which MerkleizeVector
+// splits the hashing of a layer across workers.
+const parallelMerkleizeThreshold = 1 << 16
+
 // MerkleizeVector uses our optimized routine to hash a list of 32-byte
 // elements.
 func MerkleizeVector(elements [][32]byte, length uint64) ([32]byte, error) {
+	return MerkleizeVectorWithHasher(DefaultHasher, elements, length)
+}
+
+// MerkleizeVectorWithHasher is MerkleizeVector hashing the layers with h.
+func MerkleizeVectorWithHasher(h Hasher, elements [][32]byte, length uint64) ([32]byte, error) {
+	if uint64(len(elements)) > length {
+		return [32]byte{}, fmt.Errorf("merkleize vector: %d elements exceed the limit of %d", len(elements), length)
+	}
 	depth := GetDepth(length)
 	// Return zerohash at depth
 	if len(elements) == 0 {
 		return ZeroHashes[depth], nil
 	}
-	for i := uint8(0); i < depth; i++ {
+	if workers := runtime.NumCPU(); workers > 1 && len(elements) >= parallelMerkleizeThreshold {
+		return merkleizeVectorParallel(h, elements, depth, workers)
+	}
+	return merkleizeLayers(h, elements, 0, depth)
+}
+
+// merkleizeLayers hashes the layers from start to depth in place.
+func merkleizeLayers(h Hasher, elements [][32]byte, start, depth uint8) ([32]byte, error) {
+	layer, err := hashLayers(h, elements, start, depth)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return layer[0], nil
+}
+
+// hashLayers hashes the layers from start to stop in place and returns the
+// layer at stop, without padding.
+func hashLayers(h Hasher, elements [][32]byte, start, stop uint8) ([][32]byte, error) {
+	for i := start; i < stop; i++ {
 		// Sequential
 		layerLen := len(elements)
 		if layerLen%2 == 1 {
 			elements = append(elements, ZeroHashes[i])
 		}
 		outputLen := len(elements) / 2
-		if err := gohashtree.Hash(elements, elements); err != nil {
-			return [32]byte{}, err
+		if err := h.Hash(elements, elements); err != nil {
+			return nil, err
 		}
 		elements = elements[:outputLen]
 	}
-	return elements[0], nil
+	return elements, nil
 }
 
-// MerkleizeVector uses our optimized routine to hash a list of 32-byte
-// elements.
+// MerkleizeVectorToDepth hashes elements in place up to stopDepth, counted from
+// the leaves, and returns the layer at stopDepth. The following layers are
+// padded with ZeroHashes from stopDepth on, see
+// MerkleRootFromFlatFromIntermediateLevelWithLimit.
+func MerkleizeVectorToDepth(elements [][32]byte, limit uint64, stopDepth uint8) ([][32]byte, error) {
+	if uint64(len(elements)) > limit {
+		return nil, fmt.Errorf("merkleize vector: %d elements exceed the limit of %d", len(elements), limit)
+	}
+	if depth := GetDepth(limit); stopDepth > depth {
+		return nil, fmt.Errorf("merkleize vector: stop depth %d beyond the depth %d of limit %d", stopDepth, depth, limit)
+	}
+	return hashLayers(DefaultHasher, elements, 0, stopDepth)
+}
+
+// merkleizeVectorParallel hashes the wide layers across workers and finishes
+// the narrow ones sequentially. A layer cannot be hashed in place by several
+// workers, so the layers alternate between elements and a scratch buffer.
+func merkleizeVectorParallel(h Hasher, elements [][32]byte, depth uint8, workers int) ([32]byte, error) {
+	var scratch [][32]byte
+	i := uint8(0)
+	for ; i < depth && len(elements) >= parallelMerkleizeThreshold; i++ {
+		if len(elements)%2 == 1 {
+			elements = append(elements, ZeroHashes[i])
+		}
+		outputLen := len(elements) / 2
+		if scratch == nil {
+			scratch = make([][32]byte, outputLen)
+		}
+		layer := scratch[:outputLen]
+		if err := hashLayerParallel(h, layer, elements, workers); err != nil {
+			return [32]byte{}, err
+		}
+		elements, scratch = layer, elements
+	}
+	return merkleizeLayers(h, elements, i, depth)
+}
+
+// hashLayerParallel hashes the pairs of layer into out. Every worker gets an
+// even number of nodes so that no pair is split.
+func hashLayerParallel(h Hasher, out, layer [][32]byte, workers int) error {
+	chunk := ((len(layer)+workers-1)/workers + 1) &^ 1
+	wp := threading.NewParallelExecutor()
+	for from := 0; from < len(layer); from += chunk {
+		to := min(from+chunk, len(layer))
+		wp.AddWork(func() error {
+			return h.Hash(out[from/2:to/2], layer[from:to])
+		})
+	}
+	return wp.Execute()
+}
+
+// MerkleizeVectorFlat is MerkleizeVector for elements packed in a single
+// slice. It does not modify in.
 func MerkleizeVectorFlat(in []byte, limit uint64) ([32]byte, error) {
 	elements := make([]byte, len(in))
 	copy(elements, in)
-	for i := uint8(0); i < GetDepth(limit); i++ {
-		// Sequential
+	return MerkleizeVectorFlatInPlace(elements, limit)
+}
+
+// zeroPaddingPool holds the buffers used to hash the last node of an odd layer
+// with its zero hash sibling.
+var zeroPaddingPool = sync.Pool{
+	New: func() any { return new([2 * length.Hash]byte) },
+}
+
+// MerkleizeVectorFlatInPlace is MerkleizeVectorFlat for callers that allow
+// elements to be overwritten, it hashes the layers in place without
+// allocating.
+func MerkleizeVectorFlatInPlace(elements []byte, limit uint64) ([32]byte, error) {
+	depth := GetDepth(limit)
+	if len(elements)%length.Hash != 0 {
+		return [32]byte{}, fmt.Errorf("elements length %d is not a multiple of %d", len(elements), length.Hash)
+	}
+	if uint64(len(elements)/length.Hash) > limit {
+		return [32]byte{}, fmt.Errorf("merkleize vector: %d elements exceed the limit of %d", len(elements)/length.Hash, limit)
+	}
+	if len(elements) == 0 {
+		return ZeroHashes[depth], nil
+	}
+	padding := zeroPaddingPool.Get().(*[2 * length.Hash]byte)
+	defer zeroPaddingPool.Put(padding)
+	for i := uint8(0); i < depth; i++ {
 		layerLen := len(elements)
-		if layerLen%64 == 32 {
-			elements = append(elements, ZeroHashes[i][:]...)
+		evenLen := layerLen - layerLen%(2*length.Hash)
+		if evenLen != layerLen {
+			copy(padding[:length.Hash], elements[evenLen:])
+			copy(padding[length.Hash:], ZeroHashes[i][:])
 		}
-		outputLen := len(elements) / 2
-		if err := HashByteSlice(elements, elements); err != nil {
-			return [32]byte{}, err
+		if evenLen > 0 {
+			if err := HashByteSlice(elements, elements[:evenLen]); err != nil {
+				return [32]byte{}, err
+			}
 		}
-		elements = elements[:outputLen]
+		if evenLen != layerLen {
+			if err := HashByteSlice(elements[evenLen/2:], padding[:]); err != nil {
+				return [32]byte{}, err
+			}
+		}
+		elements = elements[:(layerLen/length.Hash+1)/2*length.Hash]
 	}
 	return common.BytesToHash(elements[:length.Hash]), nil
 }
@@ -74,33 +190,54 @@ func MerkleizeVectorFlat(in []byte, limit uint64) ([32]byte, error) {
 // BitlistRootWithLimit computes the HashSSZ merkleization of
 // participation roots.
 func BitlistRootWithLimit(bits []byte, limit uint64) ([32]byte, error) {
-	var (
-		unpackedRoots []byte
-		size          uint64
-	)
+	base, size, err := BitlistBaseRootWithLimit(bits, limit)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return MixInLength(base, size), nil
+}
+
+// BitlistBaseRootWithLimit returns the root of the bits of a bitlist before the
+// length is mixed in, and the number of bits.
+func BitlistBaseRootWithLimit(bits []byte, limit uint64) (base [32]byte, size uint64, err error) {
+	var unpackedRoots []byte
 	unpackedRoots, size = parseBitlist(unpackedRoots, bits)
 
-	roots := packBits(unpackedRoots)
-	base, err := MerkleizeVector(roots, (limit+255)/256)
+	roots := getLeavesBuffer(0)
+	defer putLeavesBuffer(roots)
+	*roots = packBits(*roots, unpackedRoots)
+	base, err = MerkleizeVector(*roots, (limit+255)/256)
 	if err != nil {
-		return [32]byte{}, err
+		return [32]byte{}, 0, err
 	}
+	return base, size, nil
+}
 
-	lengthRoot := Uint64Root(size)
-	return utils.Sha256(base[:], lengthRoot[:]), nil
+// MixInLength returns the root of a list given the root of its elements and
+// its length.
+func MixInLength(root [32]byte, length uint64) [32]byte {
+	lengthRoot := Uint64Root(length)
+	return utils.Sha256(root[:], lengthRoot[:])
 }
 
 func BitvectorRootWithLimit(bits []byte, limit uint64) ([32]byte, error) {
-	roots := packBits(bits)
-	root, err := MerkleizeVector(roots, (limit+255)/256)
+	if uint64(len(bits)) > (limit+7)/8 {
+		return [32]byte{}, fmt.Errorf("bitvector of %d bytes exceeds the limit of %d bits", len(bits), limit)
+	}
+	roots := getLeavesBuffer(0)
+	defer putLeavesBuffer(roots)
+	*roots = packBits(*roots, bits)
+	root, err := MerkleizeVector(*roots, (limit+255)/256)
 	if err != nil {
 		return [32]byte{}, err
 	}
 	return root, nil
 }
 
-func packBits(bytes []byte) [][32]byte {
-	var chunks [][32]byte
+// packBits appends bytes to chunks split in 32-byte chunks, leaving room for the
+// zero hash MerkleizeVector appends to odd layers.
+func packBits(chunks [][32]byte, bytes []byte) [][32]byte {
+	chunks = slices.Grow(chunks, (len(bytes)+31)/32+1)
 	for i := 0; i < len(bytes); i += 32 {
 		var chunk [32]byte
 		copy(chunk[:], bytes[i:])
@@ -128,25 +265,27 @@ func parseBitlist(dst, buf []byte) ([]byte, uint64) {
 }
 
 func TransactionsListRoot(transactions [][]byte) ([32]byte, error) {
-	return globalHasher.transactionsListRoot(transactions)
+	m := getMerkleHasher()
+	defer putMerkleHasher(m)
+	return m.transactionsListRoot(transactions, runtime.NumCPU())
 }
 
 func ListObjectSSZRoot[T ssz.HashableSSZ](list []T, limit uint64) ([32]byte, error) {
-	globalHasher.mu2.Lock()
-	defer globalHasher.mu2.Unlock()
-	// due to go generics we cannot make a method for global hasher.
-	subLeaves := globalHasher.getBufferForSSZList(len(list))
+	if uint64(len(list)) > limit {
+		return [32]byte{}, fmt.Errorf("list of %d elements exceeds the limit of %d", len(list), limit)
+	}
+	subLeaves := getLeavesBuffer(len(list))
+	defer putLeavesBuffer(subLeaves)
 	for i, element := range list {
 		subLeaf, err := element.HashSSZ()
 		if err != nil {
 			return [32]byte{}, err
 		}
-		subLeaves[i] = subLeaf
+		(*subLeaves)[i] = subLeaf
 	}
-	vectorLeaf, err := MerkleizeVector(subLeaves, limit)
+	vectorLeaf, err := MerkleizeVector(*subLeaves, limit)
 	if err != nil {
 		return [32]byte{}, err
 	}
-	lenLeaf := Uint64Root(uint64(len(list)))
-	return utils.Sha256(vectorLeaf[:], lenLeaf[:]), nil
+	return MixInLength(vectorLeaf, uint64(len(list))), nil
 }
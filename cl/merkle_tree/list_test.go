@@ -0,0 +1,282 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package merkle_tree_test
+
+import (
+	"crypto/rand"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon/cl/merkle_tree"
+	"github.com/erigontech/erigon/cl/utils"
+)
+
+func randomLeaves(t testing.TB, n int) [][32]byte {
+	leaves := make([][32]byte, n)
+	for i := range leaves {
+		_, err := rand.Read(leaves[i][:])
+		require.NoError(t, err)
+	}
+	return leaves
+}
+
+func TestMerkleizeVectorParallel(t *testing.T) {
+	for _, n := range []int{1 << 16, 1<<16 + 1, 100_003, 1 << 17} {
+		leaves := randomLeaves(t, n)
+		for _, limit := range []uint64{uint64(n), 1 << 20, 1 << 40} {
+			for _, workers := range []int{2, 3, 8} {
+				want, err := merkle_tree.MerkleizeLayers(merkle_tree.DefaultHasher, append([][32]byte(nil), leaves...), 0, merkle_tree.GetDepth(limit))
+				require.NoError(t, err)
+				got, err := merkle_tree.MerkleizeVectorParallel(merkle_tree.DefaultHasher, append([][32]byte(nil), leaves...), merkle_tree.GetDepth(limit), workers)
+				require.NoError(t, err)
+				require.Equal(t, want, got, "leaves %d, limit %d, workers %d", n, limit, workers)
+			}
+		}
+	}
+}
+
+func BenchmarkMerkleizeVector(b *testing.B) {
+	leaves := randomLeaves(b, 1<<20)
+	depth := merkle_tree.GetDepth(1 << 40)
+	elements := make([][32]byte, len(leaves))
+	run := func(b *testing.B, merkleize func([][32]byte) ([32]byte, error)) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			copy(elements, leaves)
+			b.StartTimer()
+			if _, err := merkleize(elements); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("sequential", func(b *testing.B) {
+		run(b, func(elements [][32]byte) ([32]byte, error) {
+			return merkle_tree.MerkleizeLayers(merkle_tree.DefaultHasher, elements, 0, depth)
+		})
+	})
+	b.Run("parallel", func(b *testing.B) {
+		run(b, func(elements [][32]byte) ([32]byte, error) {
+			return merkle_tree.MerkleizeVectorParallel(merkle_tree.DefaultHasher, elements, depth, runtime.NumCPU())
+		})
+	})
+}
+
+func TestBitlistBaseRootWithLimit(t *testing.T) {
+	for _, bits := range [][]byte{{0x01}, {0x0b}, {0xff, 0x01}, {0x00, 0x00, 0x80}, append(make([]byte, 40), 0x03)} {
+		want, err := merkle_tree.BitlistRootWithLimit(bits, 2048)
+		require.NoError(t, err)
+		base, size, err := merkle_tree.BitlistBaseRootWithLimit(bits, 2048)
+		require.NoError(t, err)
+		lengthRoot := merkle_tree.Uint64Root(size)
+		require.Equal(t, want, utils.Sha256(base[:], lengthRoot[:]))
+		require.Equal(t, want, merkle_tree.MixInLength(base, size))
+	}
+
+	_, size, err := merkle_tree.BitlistBaseRootWithLimit([]byte{0xff, 0x01}, 2048)
+	require.NoError(t, err)
+	require.Equal(t, uint64(8), size)
+}
+
+func flatten(leaves [][32]byte) []byte {
+	flat := make([]byte, 0, len(leaves)*32)
+	for _, leaf := range leaves {
+		flat = append(flat, leaf[:]...)
+	}
+	return flat
+}
+
+func TestMerkleizeVectorFlatInPlace(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 7, 8, 33} {
+		leaves := randomLeaves(t, n)
+		for _, limit := range []uint64{64, 1 << 20} {
+			want, err := merkle_tree.MerkleizeVector(append([][32]byte(nil), leaves...), limit)
+			require.NoError(t, err)
+
+			in := flatten(leaves)
+			got, err := merkle_tree.MerkleizeVectorFlatInPlace(in, limit)
+			require.NoError(t, err)
+			require.Equal(t, want, got, "leaves %d, limit %d", n, limit)
+
+			in = flatten(leaves)
+			got, err = merkle_tree.MerkleizeVectorFlat(in, limit)
+			require.NoError(t, err)
+			require.Equal(t, want, got, "leaves %d, limit %d", n, limit)
+			require.Equal(t, flatten(leaves), in)
+		}
+	}
+
+	_, err := merkle_tree.MerkleizeVectorFlatInPlace(make([]byte, 33), 64)
+	require.Error(t, err)
+}
+
+func BenchmarkMerkleizeVectorFlat(b *testing.B) {
+	in := flatten(randomLeaves(b, 1<<16+1))
+	elements := make([]byte, len(in))
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := merkle_tree.MerkleizeVectorFlat(in, 1<<20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("in place", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			copy(elements, in)
+			if _, err := merkle_tree.MerkleizeVectorFlatInPlace(elements, 1<<20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkListObjectSSZRoot(b *testing.B) {
+	elements := testElements(1 << 12)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := merkle_tree.ListObjectSSZRoot(elements, 1<<40); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBitlistRootWithLimit(b *testing.B) {
+	bits := make([]byte, 2048/8+1)
+	for i := range bits {
+		bits[i] = byte(i)
+	}
+	bits[len(bits)-1] = 1
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := merkle_tree.BitlistRootWithLimit(bits, 2048); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMerkleizeOverLimit(t *testing.T) {
+	leaves := randomLeaves(t, 5)
+	_, err := merkle_tree.MerkleizeVector(leaves, 4)
+	require.ErrorContains(t, err, "5 elements exceed the limit of 4")
+	_, err = merkle_tree.MerkleizeVector(leaves, 8)
+	require.NoError(t, err)
+
+	_, err = merkle_tree.MerkleizeVectorFlat(flatten(leaves), 4)
+	require.ErrorContains(t, err, "5 elements exceed the limit of 4")
+
+	_, err = merkle_tree.BitvectorRootWithLimit(make([]byte, 3), 16)
+	require.ErrorContains(t, err, "exceeds the limit of 16 bits")
+	_, err = merkle_tree.BitvectorRootWithLimit(make([]byte, 2), 12)
+	require.NoError(t, err)
+
+	_, err = merkle_tree.ListObjectSSZRoot(testElements(5), 4)
+	require.ErrorContains(t, err, "list of 5 elements exceeds the limit of 4")
+	_, err = merkle_tree.NewCachedListHasher[*testElement](4).HashSSZ(testElements(5))
+	require.Error(t, err)
+}
+
+// countingHasher counts the pairs hashed by the default hasher.
+type countingHasher struct {
+	pairs atomic.Int64
+}
+
+func (h *countingHasher) Hash(digests [][32]byte, chunks [][32]byte) error {
+	h.pairs.Add(int64(len(chunks) / 2))
+	return merkle_tree.DefaultHasher.Hash(digests, chunks)
+}
+
+func TestMerkleizeVectorWithHasher(t *testing.T) {
+	leaves := randomLeaves(t, 5)
+	want, err := merkle_tree.MerkleizeVector(append([][32]byte(nil), leaves...), 16)
+	require.NoError(t, err)
+
+	var h countingHasher
+	got, err := merkle_tree.MerkleizeVectorWithHasher(&h, leaves, 16)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	// 5 leaves padded to 6, then 3 nodes padded to 4, 2 and 1
+	require.Equal(t, int64(3+2+1+1), h.pairs.Load())
+}
+
+func TestMerkleizeVectorToDepth(t *testing.T) {
+	const limit = 1 << 10
+	for _, n := range []int{0, 1, 5, 64, 99} {
+		leaves := randomLeaves(t, n)
+		want, err := merkle_tree.MerkleizeVector(append([][32]byte(nil), leaves...), limit)
+		require.NoError(t, err)
+		for _, stopDepth := range []uint8{0, 1, 3, 10} {
+			layer, err := merkle_tree.MerkleizeVectorToDepth(append([][32]byte(nil), leaves...), limit, stopDepth)
+			require.NoError(t, err)
+			require.Len(t, layer, (n+1<<stopDepth-1)>>stopDepth)
+			if n == 0 {
+				continue
+			}
+			var got [32]byte
+			require.NoError(t, merkle_tree.MerkleRootFromFlatFromIntermediateLevelWithLimit(flatten(layer), got[:], limit, int(stopDepth)))
+			require.Equal(t, want, got, "leaves %d, stop depth %d", n, stopDepth)
+		}
+	}
+
+	_, err := merkle_tree.MerkleizeVectorToDepth(randomLeaves(t, 4), 8, 4)
+	require.Error(t, err)
+}
+
+// getDepthLoop is the original GetDepth, halving v until it reaches 1.
+func getDepthLoop(v uint64) uint8 {
+	depth := uint8(0)
+	for v > 1 {
+		v >>= 1
+		depth++
+	}
+	return depth
+}
+
+func TestGetDepth(t *testing.T) {
+	for v := uint64(0); v < 1<<16; v++ {
+		require.Equal(t, getDepthLoop(v), merkle_tree.GetDepth(v), "v %d", v)
+	}
+	for shift := 0; shift < 64; shift++ {
+		for _, v := range []uint64{1<<shift - 1, 1 << shift, 1<<shift + 1} {
+			require.Equal(t, getDepthLoop(v), merkle_tree.GetDepth(v), "v %d", v)
+		}
+	}
+	require.Equal(t, uint8(63), merkle_tree.GetDepth(math.MaxUint64))
+}
+
+var depthSink uint8
+
+func BenchmarkGetDepth(b *testing.B) {
+	limits := []uint64{4, 64, 2048, 1 << 24, 1 << 40}
+	b.Run("loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			depthSink += getDepthLoop(limits[i%len(limits)])
+		}
+	})
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			depthSink += merkle_tree.GetDepth(limits[i%len(limits)])
+		}
+	})
+}
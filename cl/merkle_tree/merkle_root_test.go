@@ -17,7 +17,9 @@
 package merkle_tree_test
 
 import (
+	"crypto/rand"
 	_ "embed"
+	"runtime"
 	"testing"
 
 	"github.com/erigontech/erigon-lib/common"
@@ -49,3 +51,44 @@ func TestHashTreeRootTxs(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, common.Hash(root), common.HexToHash("0x987269bc1075122edff32bfc38479757103cee5c1ed6e990de7ffee85b5dd18a"))
 }
+
+func randomTransactions(n int) [][]byte {
+	txs := make([][]byte, n)
+	for i := range txs {
+		txs[i] = make([]byte, 100+i%300)
+		rand.Read(txs[i])
+	}
+	return txs
+}
+
+func TestTransactionsListRootParallel(t *testing.T) {
+	txs := randomTransactions(1000)
+	want, err := merkle_tree.TransactionsListRootWithWorkers(txs, 1)
+	require.NoError(t, err)
+	for _, workers := range []int{2, 3, 16} {
+		got, err := merkle_tree.TransactionsListRootWithWorkers(txs, workers)
+		require.NoError(t, err)
+		require.Equal(t, want, got, "workers %d", workers)
+	}
+	got, err := merkle_tree.TransactionsListRoot(txs)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func BenchmarkTransactionsListRoot(b *testing.B) {
+	txs := randomTransactions(10_000)
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := merkle_tree.TransactionsListRootWithWorkers(txs, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := merkle_tree.TransactionsListRootWithWorkers(txs, runtime.NumCPU()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -142,3 +142,19 @@ func TestMerkleTreeAppendLeafWithLowMaxDepthAndLimitAndTestWR(t *testing.T) {
 	expectedRoot := getExpectedRootWithLimit(testBuffer, int(lm))
 	require.Equal(t, expectedRoot, mt.ComputeRoot())
 }
+
+func BenchmarkMerkleRootFromFlatLeavesParallel(b *testing.B) {
+	leaves := make([]byte, 1024*length.Hash)
+	for i := range leaves {
+		leaves[i] = byte(i)
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var root common.Hash
+		for pb.Next() {
+			if err := merkle_tree.MerkleRootFromFlatLeaves(leaves, root[:]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package merkle_tree
+
+import (
+	"cmp"
+	"fmt"
+	"math/bits"
+	"slices"
+
+	"github.com/prysmaticlabs/gohashtree"
+
+	"github.com/erigontech/erigon/cl/utils"
+)
+
+// VectorMerkleProof computes the root of elements merkleized up to limit, like
+// MerkleizeVector, and the branch proving the element at index against it. The
+// branch lists the sibling hashes from the leaf up, using the zero hashes past
+// the end of elements.
+func VectorMerkleProof(elements [][32]byte, index int, limit uint64) (root [32]byte, branch [][32]byte, err error) {
+	if uint64(len(elements)) > limit {
+		return [32]byte{}, nil, fmt.Errorf("merkleize vector: %d elements exceed the limit of %d", len(elements), limit)
+	}
+	depth := GetDepth(limit)
+	if index < 0 || index >= len(elements) || uint64(index) >= 1<<depth {
+		return [32]byte{}, nil, fmt.Errorf("index %d out of range, have %d elements with limit %d", index, len(elements), limit)
+	}
+	layer := make([][32]byte, len(elements), len(elements)+1)
+	copy(layer, elements)
+	branch = make([][32]byte, depth)
+	for i := uint8(0); i < depth; i++ {
+		if sibling := index ^ 1; sibling < len(layer) {
+			branch[i] = layer[sibling]
+		} else {
+			branch[i] = ZeroHashes[i]
+		}
+		if len(layer)%2 == 1 {
+			layer = append(layer, ZeroHashes[i])
+		}
+		if err := gohashtree.Hash(layer, layer); err != nil {
+			return [32]byte{}, nil, err
+		}
+		layer = layer[:len(layer)/2]
+		index /= 2
+	}
+	return layer[0], branch, nil
+}
+
+// VerifyProof reports whether branch, as returned by VectorMerkleProof, proves
+// leaf at index against root. The depth of the tree is the length of branch.
+func VerifyProof(root [32]byte, leaf [32]byte, index int, branch [][32]byte) bool {
+	if index < 0 || index>>len(branch) != 0 {
+		return false
+	}
+	value := leaf
+	for i, sibling := range branch {
+		if index>>i&1 == 1 {
+			value = utils.Sha256(sibling[:], value[:])
+		} else {
+			value = utils.Sha256(value[:], sibling[:])
+		}
+	}
+	return value == root
+}
+
+// GeneralizedIndexDepth returns the depth of the node at the generalized index
+// gindex, the root being at generalized index 1 and depth 0.
+func GeneralizedIndexDepth(gindex uint64) uint8 {
+	return uint8(bits.Len64(gindex) - 1)
+}
+
+// GeneralizedIndexProof returns the node at the generalized index gindex of the
+// tree over leaves merkleized up to limit, and the branch proving it against
+// the root. The node may be any inner node, not just a leaf.
+func GeneralizedIndexProof(leaves [][32]byte, limit uint64, gindex uint64) (node [32]byte, branch [][32]byte, err error) {
+	if uint64(len(leaves)) > limit {
+		return [32]byte{}, nil, fmt.Errorf("merkleize vector: %d elements exceed the limit of %d", len(leaves), limit)
+	}
+	depth := GetDepth(limit)
+	if err := checkGeneralizedIndex(gindex, depth); err != nil {
+		return [32]byte{}, nil, err
+	}
+	layers, err := merkleLayers(leaves, depth)
+	if err != nil {
+		return [32]byte{}, nil, err
+	}
+	nodeDepth := GeneralizedIndexDepth(gindex)
+	branch = make([][32]byte, 0, nodeDepth)
+	for i := uint64(0); i < uint64(nodeDepth); i++ {
+		branch = append(branch, layerNode(layers, (gindex>>i)^1))
+	}
+	return layerNode(layers, gindex), branch, nil
+}
+
+func checkGeneralizedIndex(gindex uint64, depth uint8) error {
+	if gindex == 0 || GeneralizedIndexDepth(gindex) > depth {
+		return fmt.Errorf("generalized index %d out of range for depth %d", gindex, depth)
+	}
+	return nil
+}
+
+// merkleLayers returns all the layers of the tree over leaves, from the leaves
+// up to the root. The layers are not padded, see layerNode.
+func merkleLayers(leaves [][32]byte, depth uint8) ([][][32]byte, error) {
+	layers := make([][][32]byte, depth+1)
+	layers[0] = leaves
+	for i := uint8(0); i < depth; i++ {
+		layer := layers[i]
+		if len(layer)%2 == 1 {
+			layer = append(layer[:len(layer):len(layer)], ZeroHashes[i])
+		}
+		layers[i+1] = make([][32]byte, len(layer)/2)
+		if len(layer) > 0 {
+			if err := gohashtree.Hash(layers[i+1], layer); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return layers, nil
+}
+
+// layerNode returns the node at the generalized index gindex, falling back to
+// the zero hashes past the end of its layer.
+func layerNode(layers [][][32]byte, gindex uint64) [32]byte {
+	nodeDepth := GeneralizedIndexDepth(gindex)
+	level := len(layers) - 1 - int(nodeDepth)
+	index := gindex - 1<<nodeDepth
+	if index < uint64(len(layers[level])) {
+		return layers[level][index]
+	}
+	return ZeroHashes[level]
+}
+
+// VerifyGeneralizedIndexProof reports whether branch proves node at the
+// generalized index gindex against root, as is_valid_merkle_branch does in the
+// consensus specs.
+func VerifyGeneralizedIndexProof(node [32]byte, branch [][32]byte, gindex uint64, root [32]byte) bool {
+	if gindex == 0 || len(branch) != int(GeneralizedIndexDepth(gindex)) {
+		return false
+	}
+	value := node
+	for i, sibling := range branch {
+		if gindex>>i&1 == 1 {
+			value = utils.Sha256(sibling[:], value[:])
+		} else {
+			value = utils.Sha256(value[:], sibling[:])
+		}
+	}
+	return value == root
+}
+
+// MultiproofHelperIndices returns the generalized indices of the nodes needed to
+// prove the nodes at indices together, in decreasing order, as
+// get_helper_indices does in the consensus specs.
+func MultiproofHelperIndices(indices []uint64) []uint64 {
+	branch := make(map[uint64]struct{})
+	path := make(map[uint64]struct{})
+	for _, gindex := range indices {
+		for ; gindex > 1; gindex /= 2 {
+			branch[gindex^1] = struct{}{}
+			path[gindex] = struct{}{}
+		}
+	}
+	helpers := make([]uint64, 0, len(branch))
+	for gindex := range branch {
+		if _, ok := path[gindex]; !ok {
+			helpers = append(helpers, gindex)
+		}
+	}
+	slices.SortFunc(helpers, func(a, b uint64) int { return cmp.Compare(b, a) })
+	return helpers
+}
+
+// Multiproof returns the nodes at the generalized indices of the tree over
+// leaves merkleized up to limit, and the helper nodes proving them together
+// against the root, ordered as MultiproofHelperIndices.
+func Multiproof(leaves [][32]byte, limit uint64, indices []uint64) (nodes [][32]byte, proof [][32]byte, err error) {
+	if uint64(len(leaves)) > limit {
+		return nil, nil, fmt.Errorf("merkleize vector: %d elements exceed the limit of %d", len(leaves), limit)
+	}
+	depth := GetDepth(limit)
+	for _, gindex := range indices {
+		if err := checkGeneralizedIndex(gindex, depth); err != nil {
+			return nil, nil, err
+		}
+	}
+	layers, err := merkleLayers(leaves, depth)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes = make([][32]byte, len(indices))
+	for i, gindex := range indices {
+		nodes[i] = layerNode(layers, gindex)
+	}
+	helpers := MultiproofHelperIndices(indices)
+	proof = make([][32]byte, len(helpers))
+	for i, gindex := range helpers {
+		proof[i] = layerNode(layers, gindex)
+	}
+	return nodes, proof, nil
+}
+
+// VerifyMultiproof reports whether proof proves the leaves at the generalized
+// indices against root, following verify_merkle_multiproof in the consensus
+// specs.
+func VerifyMultiproof(root [32]byte, leaves [][32]byte, indices []uint64, proof [][32]byte) bool {
+	helpers := MultiproofHelperIndices(indices)
+	if len(leaves) != len(indices) || len(proof) != len(helpers) {
+		return false
+	}
+	objects := make(map[uint64][32]byte, len(indices)+len(helpers))
+	for i, gindex := range indices {
+		objects[gindex] = leaves[i]
+	}
+	for i, gindex := range helpers {
+		objects[gindex] = proof[i]
+	}
+	keys := make([]uint64, 0, len(objects))
+	for gindex := range objects {
+		keys = append(keys, gindex)
+	}
+	slices.SortFunc(keys, func(a, b uint64) int { return cmp.Compare(b, a) })
+	for pos := 0; pos < len(keys); pos++ {
+		gindex := keys[pos]
+		left, okLeft := objects[gindex&^1]
+		right, okRight := objects[gindex|1]
+		if _, ok := objects[gindex/2]; ok || !okLeft || !okRight || gindex == 1 {
+			continue
+		}
+		objects[gindex/2] = utils.Sha256(left[:], right[:])
+		keys = append(keys, gindex/2)
+	}
+	computed, ok := objects[1]
+	return ok && computed == root
+}
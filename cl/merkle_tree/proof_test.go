@@ -0,0 +1,198 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package merkle_tree_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/merkle_tree"
+	"github.com/erigontech/erigon/cl/phase1/core/state"
+	"github.com/erigontech/erigon/cl/utils"
+)
+
+func TestVectorMerkleProof(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 8, 13} {
+		leaves := randomLeaves(t, n)
+		for _, limit := range []uint64{16, 1 << 10} {
+			want, err := merkle_tree.MerkleizeVector(append([][32]byte(nil), leaves...), limit)
+			require.NoError(t, err)
+			for index := range leaves {
+				root, branch, err := merkle_tree.VectorMerkleProof(leaves, index, limit)
+				require.NoError(t, err)
+				require.Equal(t, want, root)
+
+				depth := merkle_tree.GetDepth(limit)
+				require.Len(t, branch, int(depth))
+				hashes := make([]common.Hash, len(branch))
+				for i := range branch {
+					hashes[i] = branch[i]
+				}
+				require.True(t, utils.IsValidMerkleBranch(leaves[index], hashes, uint64(depth), uint64(index), root), "leaves %d, limit %d, index %d", n, limit, index)
+				require.True(t, merkle_tree.VerifyProof(root, leaves[index], index, branch), "leaves %d, limit %d, index %d", n, limit, index)
+			}
+		}
+	}
+
+	leaves := randomLeaves(t, 4)
+	_, _, err := merkle_tree.VectorMerkleProof(leaves, 4, 8)
+	require.Error(t, err)
+	_, _, err = merkle_tree.VectorMerkleProof(leaves, -1, 8)
+	require.Error(t, err)
+	_, _, err = merkle_tree.VectorMerkleProof(leaves, 3, 2)
+	require.Error(t, err)
+}
+
+func TestGeneralizedIndexProof(t *testing.T) {
+	// a container of 28 fields, like the Deneb BeaconState
+	leaves := randomLeaves(t, 28)
+	root, err := merkle_tree.MerkleizeVector(append([][32]byte(nil), leaves...), 32)
+	require.NoError(t, err)
+
+	for gindex := uint64(1); gindex < 64; gindex++ {
+		node, branch, err := merkle_tree.GeneralizedIndexProof(leaves, 32, gindex)
+		require.NoError(t, err)
+		require.True(t, merkle_tree.VerifyGeneralizedIndexProof(node, branch, gindex, root), "gindex %d", gindex)
+		node[0] ^= 1
+		require.False(t, merkle_tree.VerifyGeneralizedIndexProof(node, branch, gindex, root), "gindex %d", gindex)
+	}
+	node, branch, err := merkle_tree.GeneralizedIndexProof(leaves, 32, 1)
+	require.NoError(t, err)
+	require.Equal(t, root, node)
+	require.Empty(t, branch)
+
+	// CURRENT_SYNC_COMMITTEE_GINDEX matches the schema based proof of the field
+	schema := make([]interface{}, len(leaves))
+	for i := range leaves {
+		schema[i] = leaves[i][:]
+	}
+	want, err := merkle_tree.MerkleProof(5, 22, schema...)
+	require.NoError(t, err)
+	node, branch, err = merkle_tree.GeneralizedIndexProof(leaves, 32, 54)
+	require.NoError(t, err)
+	require.Equal(t, leaves[22], node)
+	require.Equal(t, want, branch)
+
+	_, _, err = merkle_tree.GeneralizedIndexProof(leaves, 32, 0)
+	require.Error(t, err)
+	_, _, err = merkle_tree.GeneralizedIndexProof(leaves, 32, 64)
+	require.Error(t, err)
+}
+
+func TestVerifyGeneralizedIndexProofBeaconState(t *testing.T) {
+	bs := state.New(&clparams.MainnetBeaconConfig)
+	require.NoError(t, utils.DecodeSSZSnappy(bs, beaconState, int(clparams.DenebVersion)))
+	root, err := bs.HashSSZ()
+	require.NoError(t, err)
+
+	// get_generalized_index(BeaconState, 'current_sync_committee') = 54
+	committee, err := bs.CurrentSyncCommittee().HashSSZ()
+	require.NoError(t, err)
+	branch, err := bs.CurrentSyncCommitteeBranch()
+	require.NoError(t, err)
+	require.True(t, merkle_tree.VerifyGeneralizedIndexProof(committee, branch, 54, root))
+	require.False(t, merkle_tree.VerifyGeneralizedIndexProof(committee, branch, 55, root))
+
+	// get_generalized_index(BeaconState, 'finalized_checkpoint', 'root') = 105
+	branch, err = bs.FinalityRootBranch()
+	require.NoError(t, err)
+	require.True(t, merkle_tree.VerifyGeneralizedIndexProof(bs.FinalizedCheckpoint().Root, branch, 105, root))
+}
+
+func TestMultiproof(t *testing.T) {
+	leaves := randomLeaves(t, 28)
+	root, err := merkle_tree.MerkleizeVector(append([][32]byte(nil), leaves...), 32)
+	require.NoError(t, err)
+
+	// the helpers of a single index are its branch
+	require.Equal(t, []uint64{55, 26, 12, 7, 2}, merkle_tree.MultiproofHelperIndices([]uint64{54}))
+	// siblings prove each other
+	require.Equal(t, []uint64{26, 12, 7, 2}, merkle_tree.MultiproofHelperIndices([]uint64{54, 55}))
+
+	for _, indices := range [][]uint64{{54}, {54, 55}, {52, 33, 60}, {2, 47}, {1}, {32, 33, 34, 35, 36, 37, 38, 39, 40}} {
+		nodes, proof, err := merkle_tree.Multiproof(leaves, 32, indices)
+		require.NoError(t, err)
+		require.True(t, merkle_tree.VerifyMultiproof(root, nodes, indices, proof), "indices %v", indices)
+
+		// cross-check against the single proofs of the same indices
+		for i, gindex := range indices {
+			node, branch, err := merkle_tree.GeneralizedIndexProof(leaves, 32, gindex)
+			require.NoError(t, err)
+			require.Equal(t, node, nodes[i])
+			if len(indices) == 1 {
+				require.Equal(t, branch, proof)
+			}
+		}
+		for i, gindex := range merkle_tree.MultiproofHelperIndices(indices) {
+			node, _, err := merkle_tree.GeneralizedIndexProof(leaves, 32, gindex)
+			require.NoError(t, err)
+			require.Equal(t, node, proof[i])
+		}
+
+		nodes[0][0] ^= 1
+		require.False(t, merkle_tree.VerifyMultiproof(root, nodes, indices, proof), "indices %v", indices)
+		require.False(t, merkle_tree.VerifyMultiproof(root, nodes[1:], indices, proof), "indices %v", indices)
+	}
+
+	_, _, err = merkle_tree.Multiproof(leaves, 32, []uint64{54, 64})
+	require.Error(t, err)
+}
+
+func TestProofsOverLimit(t *testing.T) {
+	// 5 leaves do not fit a tree of 4, so the proofs are refused like the root is
+	leaves := randomLeaves(t, 5)
+	_, err := merkle_tree.MerkleizeVector(append([][32]byte(nil), leaves...), 4)
+	require.ErrorContains(t, err, "5 elements exceed the limit of 4")
+	_, _, err = merkle_tree.VectorMerkleProof(leaves, 0, 4)
+	require.ErrorContains(t, err, "5 elements exceed the limit of 4")
+	_, _, err = merkle_tree.GeneralizedIndexProof(leaves, 4, 4)
+	require.ErrorContains(t, err, "5 elements exceed the limit of 4")
+	_, _, err = merkle_tree.Multiproof(leaves, 4, []uint64{4, 5})
+	require.ErrorContains(t, err, "5 elements exceed the limit of 4")
+}
+
+func TestVerifyProof(t *testing.T) {
+	leaves := randomLeaves(t, 5)
+	root, branch, err := merkle_tree.VectorMerkleProof(leaves, 3, 8)
+	require.NoError(t, err)
+	require.True(t, merkle_tree.VerifyProof(root, leaves[3], 3, branch))
+	require.False(t, merkle_tree.VerifyProof(root, leaves[2], 3, branch))
+	require.False(t, merkle_tree.VerifyProof(root, leaves[3], 2, branch))
+	require.False(t, merkle_tree.VerifyProof(root, leaves[3], 3+8, branch))
+	require.False(t, merkle_tree.VerifyProof(root, leaves[3], -1, branch))
+	require.False(t, merkle_tree.VerifyProof(root, leaves[3], 3, branch[:2]))
+
+	// the branches of the mainnet Deneb state served to light clients
+	bs := state.New(&clparams.MainnetBeaconConfig)
+	require.NoError(t, utils.DecodeSSZSnappy(bs, beaconState, int(clparams.DenebVersion)))
+	stateRoot, err := bs.HashSSZ()
+	require.NoError(t, err)
+	committee, err := bs.NextSyncCommittee().HashSSZ()
+	require.NoError(t, err)
+	branch, err = bs.NextSyncCommitteeBranch()
+	require.NoError(t, err)
+	require.True(t, merkle_tree.VerifyProof(stateRoot, committee, 23, branch))
+	require.False(t, merkle_tree.VerifyProof(stateRoot, committee, 22, branch))
+
+	// finalized_checkpoint.root is the second leaf of the field 20 subtree
+	branch, err = bs.FinalityRootBranch()
+	require.NoError(t, err)
+	require.True(t, merkle_tree.VerifyProof(stateRoot, bs.FinalizedCheckpoint().Root, 20*2+1, branch))
+}
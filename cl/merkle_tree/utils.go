@@ -16,6 +16,8 @@
 
 package merkle_tree
 
+import "math/bits"
+
 func NextPowerOfTwo(n uint64) uint64 {
 	if n == 0 {
 		return 1
@@ -33,25 +35,22 @@ func NextPowerOfTwo(n uint64) uint64 {
 	return n
 }
 
+// depthTable holds GetDepth of the small limits, which most SSZ types have.
+var depthTable = func() (table [256]uint8) {
+	for v := 2; v < len(table); v++ {
+		table[v] = table[v/2] + 1
+	}
+	return table
+}()
+
 // GetDepth returns the depth of a merkle tree with a given number of nodes.
 // The depth is defined as the number of levels in the tree, with the root
 // node at level 0 and each child node at a level one greater than its parent.
 // If the number of nodes is less than or equal to 1, the depth is 0.
 func GetDepth(v uint64) uint8 {
-	// If there are 0 or 1 nodes, the depth is 0.
-	if v <= 1 {
-		return 0
+	if v < uint64(len(depthTable)) {
+		return depthTable[v]
 	}
-
-	// Initialize the depth to 0.
-	depth := uint8(0)
-
-	// Divide the number of nodes by 2 until it is less than or equal to 1.
-	// The number of iterations is the depth of the tree.
-	for v > 1 {
-		v >>= 1
-		depth++
-	}
-
-	return depth
+	// The depth is the number of times v can be halved before reaching 1.
+	return uint8(bits.Len64(v) - 1)
 }
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"fmt"
 	"sort"
 	"sync"
 	"time"
@@ -68,8 +69,16 @@ var (
 	// Snapshot metrics
 	frozenBlocks = metrics.GetOrCreateGauge("frozen_blocks")
 	frozenBlobs  = metrics.GetOrCreateGauge("frozen_blobs")
+
+	// Slashing metrics
+	proposerSlashingAccepted         = metrics.GetOrCreateCounter("proposer_slashing_accepted")
+	proposerSlashingIgnored          = metrics.GetOrCreateCounter("proposer_slashing_ignored")
+	proposerSlashingDuplicate        = metrics.GetOrCreateCounter("proposer_slashing_duplicate")
+	proposerSlashingVerificationTime = metrics.GetOrCreateHistogram("proposer_slashing_verification_time")
 )
 
+const proposerSlashingRejectedMetricFormat = `proposer_slashing_rejected{reason="%s"}`
+
 type batchVerificationThroughputMetric struct {
 	totalVerified      uint64
 	currentAverageSecs float64
@@ -236,3 +245,28 @@ func ObserveExecutionClientInsertingBlocks(startTime time.Time) {
 func ObserveExecutionClientValidateChain(startTime time.Time) {
 	executionClientValidateChain.Set(microToMilli(time.Since(startTime).Microseconds()))
 }
+
+// ObserveProposerSlashingAccepted counts a proposer slashing added to the operations pool
+func ObserveProposerSlashingAccepted() {
+	proposerSlashingAccepted.Inc()
+}
+
+// ObserveProposerSlashingIgnored counts a proposer slashing ignored, mostly because it was already known
+func ObserveProposerSlashingIgnored() {
+	proposerSlashingIgnored.Inc()
+}
+
+// ObserveProposerSlashingDuplicate counts a proposer slashing reported for a proposer with an already accepted one
+func ObserveProposerSlashingDuplicate() {
+	proposerSlashingDuplicate.Inc()
+}
+
+// ObserveProposerSlashingRejected counts an invalid proposer slashing, by the reason of its rejection
+func ObserveProposerSlashingRejected(reason string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf(proposerSlashingRejectedMetricFormat, reason)).Inc()
+}
+
+// ObserveProposerSlashingVerificationTime observes the time it took to verify the signatures of proposer slashings
+func ObserveProposerSlashingVerificationTime(startTime time.Time) {
+	proposerSlashingVerificationTime.ObserveDuration(startTime)
+}
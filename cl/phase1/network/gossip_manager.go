@@ -126,6 +126,10 @@ func (g *GossipManager) onRecv(ctx context.Context, data *sentinel.GossipData, l
 	monitor.ObserveGossipTopicSeen(data.Name, len(data.Data))
 
 	if err := g.routeAndProcess(ctx, data); err != nil {
+		if errors.Is(err, services.ErrRateLimited) {
+			// the message is only ignored, but its sender should slow down
+			g.sentinel.PenalizePeer(ctx, data.Peer)
+		}
 		return err
 	}
 	if errors.Is(err, services.ErrIgnore) || errors.Is(err, synced_data.ErrNotSynced) {
@@ -178,26 +182,17 @@ func (g *GossipManager) routeAndProcess(ctx context.Context, data *sentinel.Goss
 			Receiver:                   copyOfPeerData(data),
 			SignedContributionAndProof: &cltypes.SignedContributionAndProof{},
 		}
-		if err := obj.SignedContributionAndProof.DecodeSSZ(data.Data, int(version)); err != nil {
-			return err
-		}
-		return g.syncContributionService.ProcessMessage(ctx, data.SubnetId, obj)
+		return processGossip(ctx, g.syncContributionService, data, version, obj, obj.SignedContributionAndProof)
 	case gossip.TopicNameVoluntaryExit:
 		obj := &services.SignedVoluntaryExitForGossip{
 			Receiver:            copyOfPeerData(data),
 			SignedVoluntaryExit: &cltypes.SignedVoluntaryExit{},
 		}
-		if err := obj.SignedVoluntaryExit.DecodeSSZ(data.Data, int(version)); err != nil {
-			return err
-		}
-		return g.voluntaryExitService.ProcessMessage(ctx, data.SubnetId, obj)
+		return processGossip(ctx, g.voluntaryExitService, data, version, obj, obj.SignedVoluntaryExit)
 
 	case gossip.TopicNameProposerSlashing:
 		obj := &cltypes.ProposerSlashing{}
-		if err := obj.DecodeSSZ(data.Data, int(version)); err != nil {
-			return err
-		}
-		return g.proposerSlashingService.ProcessMessage(ctx, data.SubnetId, obj)
+		return processGossip(services.WithGossipPeer(ctx, data.Peer), g.proposerSlashingService, data, version, obj, obj)
 	case gossip.TopicNameAttesterSlashing:
 		attesterSlashing := cltypes.NewAttesterSlashing(version)
 		if err := attesterSlashing.DecodeSSZ(data.Data, int(version)); err != nil {
@@ -214,10 +209,7 @@ func (g *GossipManager) routeAndProcess(ctx context.Context, data *sentinel.Goss
 			Receiver:                   copyOfPeerData(data),
 			SignedBLSToExecutionChange: &cltypes.SignedBLSToExecutionChange{},
 		}
-		if err := obj.SignedBLSToExecutionChange.DecodeSSZ(data.Data, int(version)); err != nil {
-			return err
-		}
-		return g.blsToExecutionChangeService.ProcessMessage(ctx, data.SubnetId, obj)
+		return processGossip(ctx, g.blsToExecutionChangeService, data, version, obj, obj.SignedBLSToExecutionChange)
 	case gossip.TopicNameBeaconAggregateAndProof:
 		obj := &services.SignedAggregateAndProofForGossip{
 			Receiver:                copyOfPeerData(data),
@@ -279,6 +271,22 @@ func (g *GossipManager) routeAndProcess(ctx context.Context, data *sentinel.Goss
 	}
 }
 
+// processGossip decodes the gossip data into the payload of msg and hands msg to
+// the service of its topic.
+func processGossip[T any](
+	ctx context.Context,
+	service services.GossipService[T],
+	data *sentinel.GossipData,
+	version clparams.StateVersion,
+	msg T,
+	payload interface{ DecodeSSZ([]byte, int) error },
+) error {
+	if err := payload.DecodeSSZ(data.Data, int(version)); err != nil {
+		return err
+	}
+	return service.ProcessMessage(ctx, data.SubnetId, msg)
+}
+
 func (g *GossipManager) Start(ctx context.Context) {
 	attestationCh := make(chan *sentinel.GossipData, 1<<20) // large quantity of attestation messages from gossip
 	operationsCh := make(chan *sentinel.GossipData, 1<<16)
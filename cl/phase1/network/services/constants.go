@@ -18,12 +18,14 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
 const (
 	validatorAttestationCacheSize = 100_000
 	proposerSlashingCacheSize     = 100
+	seenProposerSlashingCacheSize = 1000
 	seenBlockCacheSize            = 1000 // SeenBlockCacheSize is the size of the cache for seen blocks.
 	blockJobsIntervalTick         = 50 * time.Millisecond
 	blobJobsIntervalTick          = 5 * time.Millisecond
@@ -42,4 +44,10 @@ var (
 	ErrCommitmentsInclusionProofFailed = errors.New("commitments inclusion proof failed")
 	ErrInvalidSidecarSlot              = errors.New("invalid sidecar slot")
 	ErrBlobIndexOutOfRange             = errors.New("blob index out of range")
+	ErrInvalidSlashing                 = errors.New("invalid slashing")                        // ErrInvalidSlashing is used to indicate that the slashing must be rejected.
+	ErrAlreadyKnown                    = fmt.Errorf("already known: %w", ErrIgnore)            // ErrAlreadyKnown is an ErrIgnore for messages that were already processed.
+	ErrAlreadySlashed                  = fmt.Errorf("%w: already slashed", ErrInvalidSlashing) // ErrAlreadySlashed is an ErrInvalidSlashing for validators slashed on chain.
+	ErrPendingSlashingsFull            = fmt.Errorf("slashing pool is full: %w", ErrIgnore)    // ErrPendingSlashingsFull is an ErrIgnore for slashings that do not fit in the pool.
+	ErrRateLimited                     = fmt.Errorf("rate limited: %w", ErrIgnore)             // ErrRateLimited is an ErrIgnore for peers sending more messages than allowed.
+	ErrBusy                            = fmt.Errorf("busy, try later: %w", ErrIgnore)          // ErrBusy is an ErrIgnore for messages that cannot be processed for now.
 )
@@ -0,0 +1,37 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"context"
+
+	sentinel "github.com/erigontech/erigon-lib/gointerfaces/sentinelproto"
+)
+
+type gossipPeerKey struct{}
+
+// WithGossipPeer returns a copy of ctx carrying the peer a gossip message was received
+// from, so that the services can account the message to it.
+func WithGossipPeer(ctx context.Context, peer *sentinel.Peer) context.Context {
+	return context.WithValue(ctx, gossipPeerKey{}, peer)
+}
+
+// GossipPeerFromContext returns the peer set by WithGossipPeer, if any.
+func GossipPeerFromContext(ctx context.Context) (*sentinel.Peer, bool) {
+	peer, ok := ctx.Value(gossipPeerKey{}).(*sentinel.Peer)
+	return peer, ok && peer != nil
+}
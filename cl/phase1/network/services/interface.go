@@ -19,38 +19,71 @@ package services
 import (
 	"context"
 
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon/cl/cltypes"
 )
 
 // Note: BlobSidecarService and BlockService are tested in spectests
 
-type Service[T any] interface {
+// GossipService processes the messages of a gossip topic, T being the decoded message.
+// The gossip manager dispatches every topic to one GossipService.
+type GossipService[T any] interface {
 	ProcessMessage(ctx context.Context, subnet *uint64, msg T) error
 }
 
 //go:generate mockgen -typed=true -destination=./mock_services/block_service_mock.go -package=mock_services . BlockService
-type BlockService Service[*cltypes.SignedBeaconBlock]
+type BlockService GossipService[*cltypes.SignedBeaconBlock]
 
 //go:generate mockgen -typed=true -destination=./mock_services/blob_sidecars_service_mock.go -package=mock_services . BlobSidecarsService
-type BlobSidecarsService Service[*cltypes.BlobSidecar]
+type BlobSidecarsService GossipService[*cltypes.BlobSidecar]
 
 //go:generate mockgen -typed=true -destination=./mock_services/sync_committee_messages_service_mock.go -package=mock_services . SyncCommitteeMessagesService
-type SyncCommitteeMessagesService Service[*SyncCommitteeMessageForGossip]
+type SyncCommitteeMessagesService GossipService[*SyncCommitteeMessageForGossip]
 
 //go:generate mockgen -typed=true -destination=./mock_services/sync_contribution_service_mock.go -package=mock_services . SyncContributionService
-type SyncContributionService Service[*SignedContributionAndProofForGossip]
+type SyncContributionService GossipService[*SignedContributionAndProofForGossip]
 
 //go:generate mockgen -typed=true -destination=./mock_services/aggregate_and_proof_service_mock.go -package=mock_services . AggregateAndProofService
-type AggregateAndProofService Service[*SignedAggregateAndProofForGossip]
+type AggregateAndProofService GossipService[*SignedAggregateAndProofForGossip]
 
 //go:generate mockgen -typed=true -destination=./mock_services/attestation_service_mock.go -package=mock_services . AttestationService
-type AttestationService Service[*AttestationForGossip]
+type AttestationService GossipService[*AttestationForGossip]
 
 //go:generate mockgen -typed=true -destination=./mock_services/voluntary_exit_service_mock.go -package=mock_services . VoluntaryExitService
-type VoluntaryExitService Service[*SignedVoluntaryExitForGossip]
+type VoluntaryExitService GossipService[*SignedVoluntaryExitForGossip]
 
 //go:generate mockgen -typed=true -destination=./mock_services/bls_to_execution_change_service_mock.go -package=mock_services . BLSToExecutionChangeService
-type BLSToExecutionChangeService Service[*SignedBLSToExecutionChangeForGossip]
+type BLSToExecutionChangeService GossipService[*SignedBLSToExecutionChangeForGossip]
+
+// ProposerSlashingPool holds the proposer slashings accepted by the ProposerSlashingService
+// until they are included in a block, see pool.OperationsPool.
+type ProposerSlashingPool interface {
+	Insert(key common.Bytes96, slashing *cltypes.ProposerSlashing)
+	Has(key common.Bytes96) bool
+	Raw() []*cltypes.ProposerSlashing
+	DeleteIfExist(key common.Bytes96) bool
+}
 
 //go:generate mockgen -typed=true -destination=./mock_services/proposer_slashing_service_mock.go -package=mock_services . ProposerSlashingService
-type ProposerSlashingService Service[*cltypes.ProposerSlashing]
+type ProposerSlashingService interface {
+	GossipService[*cltypes.ProposerSlashing]
+	// ProcessMessages processes a batch of proposer slashings, verifying their
+	// signatures at once. It returns the error of each slashing, in order.
+	ProcessMessages(ctx context.Context, subnet *uint64, msgs []*cltypes.ProposerSlashing) []error
+	// PendingSlashings returns the verified proposer slashings that are not included
+	// in a block yet.
+	PendingSlashings() []*cltypes.ProposerSlashing
+	// Validate checks a proposer slashing like ProcessMessage does, without adding it
+	// to the pending slashings.
+	Validate(ctx context.Context, msg *cltypes.ProposerSlashing) error
+	// Subscribe returns a channel receiving the proposer slashings added to the pending
+	// slashings, and a function to unsubscribe. Subscribers that do not keep up are dropped.
+	Subscribe() (<-chan ProposerSlashingEvent, func())
+	// Enqueue queues a proposer slashing for asynchronous processing, by priority.
+	// It returns ErrBusy if the queue is full. The outcome of the processing is not
+	// reported, so gossip goes through ProcessMessage for its peers to be scored.
+	Enqueue(ctx context.Context, subnet *uint64, msg *cltypes.ProposerSlashing) error
+	// Reports returns the number of slashings reported for a proposer since one was
+	// accepted for it, including the accepted one.
+	Reports(proposerIndex uint64) uint64
+}
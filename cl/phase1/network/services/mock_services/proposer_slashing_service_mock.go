@@ -14,6 +14,7 @@ import (
 	reflect "reflect"
 
 	cltypes "github.com/erigontech/erigon/cl/cltypes"
+	services "github.com/erigontech/erigon/cl/phase1/network/services"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,6 +42,82 @@ func (m *MockProposerSlashingService) EXPECT() *MockProposerSlashingServiceMockR
 	return m.recorder
 }
 
+// Enqueue mocks base method.
+func (m *MockProposerSlashingService) Enqueue(ctx context.Context, subnet *uint64, msg *cltypes.ProposerSlashing) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", ctx, subnet, msg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockProposerSlashingServiceMockRecorder) Enqueue(ctx, subnet, msg any) *MockProposerSlashingServiceEnqueueCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockProposerSlashingService)(nil).Enqueue), ctx, subnet, msg)
+	return &MockProposerSlashingServiceEnqueueCall{Call: call}
+}
+
+// MockProposerSlashingServiceEnqueueCall wrap *gomock.Call
+type MockProposerSlashingServiceEnqueueCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockProposerSlashingServiceEnqueueCall) Return(arg0 error) *MockProposerSlashingServiceEnqueueCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockProposerSlashingServiceEnqueueCall) Do(f func(context.Context, *uint64, *cltypes.ProposerSlashing) error) *MockProposerSlashingServiceEnqueueCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockProposerSlashingServiceEnqueueCall) DoAndReturn(f func(context.Context, *uint64, *cltypes.ProposerSlashing) error) *MockProposerSlashingServiceEnqueueCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// PendingSlashings mocks base method.
+func (m *MockProposerSlashingService) PendingSlashings() []*cltypes.ProposerSlashing {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingSlashings")
+	ret0, _ := ret[0].([]*cltypes.ProposerSlashing)
+	return ret0
+}
+
+// PendingSlashings indicates an expected call of PendingSlashings.
+func (mr *MockProposerSlashingServiceMockRecorder) PendingSlashings() *MockProposerSlashingServicePendingSlashingsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingSlashings", reflect.TypeOf((*MockProposerSlashingService)(nil).PendingSlashings))
+	return &MockProposerSlashingServicePendingSlashingsCall{Call: call}
+}
+
+// MockProposerSlashingServicePendingSlashingsCall wrap *gomock.Call
+type MockProposerSlashingServicePendingSlashingsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockProposerSlashingServicePendingSlashingsCall) Return(arg0 []*cltypes.ProposerSlashing) *MockProposerSlashingServicePendingSlashingsCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockProposerSlashingServicePendingSlashingsCall) Do(f func() []*cltypes.ProposerSlashing) *MockProposerSlashingServicePendingSlashingsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockProposerSlashingServicePendingSlashingsCall) DoAndReturn(f func() []*cltypes.ProposerSlashing) *MockProposerSlashingServicePendingSlashingsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // ProcessMessage mocks base method.
 func (m *MockProposerSlashingService) ProcessMessage(ctx context.Context, subnet *uint64, msg *cltypes.ProposerSlashing) error {
 	m.ctrl.T.Helper()
@@ -78,3 +155,156 @@ func (c *MockProposerSlashingServiceProcessMessageCall) DoAndReturn(f func(conte
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
+
+// ProcessMessages mocks base method.
+func (m *MockProposerSlashingService) ProcessMessages(ctx context.Context, subnet *uint64, msgs []*cltypes.ProposerSlashing) []error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessMessages", ctx, subnet, msgs)
+	ret0, _ := ret[0].([]error)
+	return ret0
+}
+
+// ProcessMessages indicates an expected call of ProcessMessages.
+func (mr *MockProposerSlashingServiceMockRecorder) ProcessMessages(ctx, subnet, msgs any) *MockProposerSlashingServiceProcessMessagesCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessMessages", reflect.TypeOf((*MockProposerSlashingService)(nil).ProcessMessages), ctx, subnet, msgs)
+	return &MockProposerSlashingServiceProcessMessagesCall{Call: call}
+}
+
+// MockProposerSlashingServiceProcessMessagesCall wrap *gomock.Call
+type MockProposerSlashingServiceProcessMessagesCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockProposerSlashingServiceProcessMessagesCall) Return(arg0 []error) *MockProposerSlashingServiceProcessMessagesCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockProposerSlashingServiceProcessMessagesCall) Do(f func(context.Context, *uint64, []*cltypes.ProposerSlashing) []error) *MockProposerSlashingServiceProcessMessagesCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockProposerSlashingServiceProcessMessagesCall) DoAndReturn(f func(context.Context, *uint64, []*cltypes.ProposerSlashing) []error) *MockProposerSlashingServiceProcessMessagesCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Reports mocks base method.
+func (m *MockProposerSlashingService) Reports(proposerIndex uint64) uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reports", proposerIndex)
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Reports indicates an expected call of Reports.
+func (mr *MockProposerSlashingServiceMockRecorder) Reports(proposerIndex any) *MockProposerSlashingServiceReportsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reports", reflect.TypeOf((*MockProposerSlashingService)(nil).Reports), proposerIndex)
+	return &MockProposerSlashingServiceReportsCall{Call: call}
+}
+
+// MockProposerSlashingServiceReportsCall wrap *gomock.Call
+type MockProposerSlashingServiceReportsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockProposerSlashingServiceReportsCall) Return(arg0 uint64) *MockProposerSlashingServiceReportsCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockProposerSlashingServiceReportsCall) Do(f func(uint64) uint64) *MockProposerSlashingServiceReportsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockProposerSlashingServiceReportsCall) DoAndReturn(f func(uint64) uint64) *MockProposerSlashingServiceReportsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Subscribe mocks base method.
+func (m *MockProposerSlashingService) Subscribe() (<-chan services.ProposerSlashingEvent, func()) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe")
+	ret0, _ := ret[0].(<-chan services.ProposerSlashingEvent)
+	ret1, _ := ret[1].(func())
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockProposerSlashingServiceMockRecorder) Subscribe() *MockProposerSlashingServiceSubscribeCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockProposerSlashingService)(nil).Subscribe))
+	return &MockProposerSlashingServiceSubscribeCall{Call: call}
+}
+
+// MockProposerSlashingServiceSubscribeCall wrap *gomock.Call
+type MockProposerSlashingServiceSubscribeCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockProposerSlashingServiceSubscribeCall) Return(arg0 <-chan services.ProposerSlashingEvent, arg1 func()) *MockProposerSlashingServiceSubscribeCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockProposerSlashingServiceSubscribeCall) Do(f func() (<-chan services.ProposerSlashingEvent, func())) *MockProposerSlashingServiceSubscribeCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockProposerSlashingServiceSubscribeCall) DoAndReturn(f func() (<-chan services.ProposerSlashingEvent, func())) *MockProposerSlashingServiceSubscribeCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Validate mocks base method.
+func (m *MockProposerSlashingService) Validate(ctx context.Context, msg *cltypes.ProposerSlashing) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", ctx, msg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockProposerSlashingServiceMockRecorder) Validate(ctx, msg any) *MockProposerSlashingServiceValidateCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockProposerSlashingService)(nil).Validate), ctx, msg)
+	return &MockProposerSlashingServiceValidateCall{Call: call}
+}
+
+// MockProposerSlashingServiceValidateCall wrap *gomock.Call
+type MockProposerSlashingServiceValidateCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockProposerSlashingServiceValidateCall) Return(arg0 error) *MockProposerSlashingServiceValidateCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockProposerSlashingServiceValidateCall) Do(f func(context.Context, *cltypes.ProposerSlashing) error) *MockProposerSlashingServiceValidateCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockProposerSlashingServiceValidateCall) DoAndReturn(f func(context.Context, *cltypes.ProposerSlashing) error) *MockProposerSlashingServiceValidateCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"sync"
+
+	"github.com/erigontech/erigon-lib/common"
+)
+
+// proposerSlashingSubscriberBuffer is the number of events a subscriber may lag behind
+// before it is dropped.
+const proposerSlashingSubscriberBuffer = 16
+
+// ProposerSlashingEvent is sent to the subscribers of a ProposerSlashingService when a
+// proposer slashing is added to the pending slashings.
+type ProposerSlashingEvent struct {
+	ProposerIndex uint64
+	// SigningRoots are the signing roots of both headers of the slashing.
+	SigningRoots [2]common.Hash
+}
+
+// proposerSlashingSubscribers fans out the events of the accepted slashings. A subscriber
+// that does not keep up is dropped, so that processing never waits for it.
+type proposerSlashingSubscribers struct {
+	mu   sync.Mutex
+	subs map[chan ProposerSlashingEvent]struct{}
+}
+
+func (p *proposerSlashingSubscribers) subscribe() (<-chan ProposerSlashingEvent, func()) {
+	ch := make(chan ProposerSlashingEvent, proposerSlashingSubscriberBuffer)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.subs == nil {
+		p.subs = make(map[chan ProposerSlashingEvent]struct{})
+	}
+	p.subs[ch] = struct{}{}
+	return ch, func() { p.drop(ch) }
+}
+
+// drop closes the channel of a subscriber, unless it was dropped already.
+func (p *proposerSlashingSubscribers) drop(ch chan ProposerSlashingEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.subs[ch]; ok {
+		delete(p.subs, ch)
+		close(ch)
+	}
+}
+
+func (p *proposerSlashingSubscribers) send(event ProposerSlashingEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ch := range p.subs {
+		select {
+		case ch <- event:
+		default:
+			delete(p.subs, ch)
+			close(ch)
+		}
+	}
+}
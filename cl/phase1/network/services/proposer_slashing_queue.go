@@ -0,0 +1,199 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon/cl/cltypes"
+)
+
+const (
+	proposerSlashingJobsIntervalTick = 100 * time.Millisecond
+	proposerSlashingQueueBatchSize   = 64
+)
+
+// ProposerSlashingPriority ranks the queued proposer slashings, the ones with the
+// highest priority are processed first.
+type ProposerSlashingPriority func(msg *cltypes.ProposerSlashing) int
+
+type queuedProposerSlashing struct {
+	msg      *cltypes.ProposerSlashing
+	priority int
+	seq      uint64 // arrival order, among slashings of the same priority
+}
+
+// proposerSlashingHeap is a max-heap of the queued slashings, see container/heap.
+type proposerSlashingHeap []queuedProposerSlashing
+
+func (h proposerSlashingHeap) Len() int { return len(h) }
+
+func (h proposerSlashingHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h proposerSlashingHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *proposerSlashingHeap) Push(x any) { *h = append(*h, x.(queuedProposerSlashing)) }
+
+func (h *proposerSlashingHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = queuedProposerSlashing{}
+	*h = old[:len(old)-1]
+	return item
+}
+
+// proposerSlashingQueue holds the proposer slashings waiting to be processed.
+type proposerSlashingQueue struct {
+	mu        sync.Mutex
+	depth     int // maximum number of queued slashings
+	items     proposerSlashingHeap
+	proposers map[uint64]int // number of queued slashings by proposer index
+	seq       uint64
+	priority  ProposerSlashingPriority
+}
+
+func (q *proposerSlashingQueue) setPriority(priority ProposerSlashingPriority) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.priority = priority
+}
+
+func (q *proposerSlashingQueue) priorityFunc() ProposerSlashingPriority {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.priority
+}
+
+// push queues msg, unless the queue is full in which case it returns false.
+func (q *proposerSlashingQueue) push(msg *cltypes.ProposerSlashing, priority int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.items.Len() >= q.depth {
+		return false
+	}
+	if q.proposers == nil {
+		q.proposers = make(map[uint64]int)
+	}
+	q.proposers[msg.Header1.Header.ProposerIndex]++
+	heap.Push(&q.items, queuedProposerSlashing{msg: msg, priority: priority, seq: q.seq})
+	q.seq++
+	return true
+}
+
+// pop removes up to n slashings from the queue, by priority.
+func (q *proposerSlashingQueue) pop(n int) []*cltypes.ProposerSlashing {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	msgs := make([]*cltypes.ProposerSlashing, 0, min(n, q.items.Len()))
+	for len(msgs) < n && q.items.Len() > 0 {
+		msg := heap.Pop(&q.items).(queuedProposerSlashing).msg
+		proposerIndex := msg.Header1.Header.ProposerIndex
+		if q.proposers[proposerIndex]--; q.proposers[proposerIndex] == 0 {
+			delete(q.proposers, proposerIndex)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func (q *proposerSlashingQueue) hasProposer(proposerIndex uint64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.proposers[proposerIndex] > 0
+}
+
+// SetPriority replaces the priority of the slashings queued from now on, which by
+// default puts the slashings of proposers that are neither known nor queued first.
+func (s *proposerSlashingService) SetPriority(priority ProposerSlashingPriority) {
+	s.queue.setPriority(priority)
+}
+
+// Enqueue queues a proposer slashing, to be processed by priority rather than in
+// arrival order. The queue is processed once Start is called. Like ProcessMessage,
+// it rejects a slashing with a subnet and rate limits the gossip peer of ctx, and it
+// returns ErrBusy if the queue is full.
+func (s *proposerSlashingService) Enqueue(ctx context.Context, subnet *uint64, msg *cltypes.ProposerSlashing) error {
+	err := checkProposerSlashingSubnet(subnet)
+	switch {
+	case err != nil:
+	case !s.allowPeer(ctx):
+		err = ErrRateLimited
+	default:
+		priority := s.queue.priorityFunc()
+		if priority == nil {
+			priority = s.defaultPriority
+		}
+		if s.queue.push(msg, priority(msg)) {
+			// the outcome is observed once processed
+			return nil
+		}
+		err = ErrBusy
+	}
+	observeProposerSlashing(err)
+	return err
+}
+
+// Start processes the queued proposer slashings until ctx is done.
+func (s *proposerSlashingService) Start(ctx context.Context) {
+	go s.loop(ctx)
+}
+
+func (s *proposerSlashingService) defaultPriority(msg *cltypes.ProposerSlashing) int {
+	proposerIndex := msg.Header1.Header.ProposerIndex
+	// Peek, so that ranking a slashing does not refresh its proposer in the cache
+	if _, ok := s.cache.Peek(proposerIndex); ok || s.queue.hasProposer(proposerIndex) {
+		return 0
+	}
+	return 1
+}
+
+func (s *proposerSlashingService) loop(ctx context.Context) {
+	ticker := time.NewTicker(proposerSlashingJobsIntervalTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.processQueue(ctx)
+	}
+}
+
+// processQueue processes the queued slashings, by batches of the highest priority ones.
+func (s *proposerSlashingService) processQueue(ctx context.Context) {
+	for ctx.Err() == nil {
+		msgs := s.queue.pop(proposerSlashingQueueBatchSize)
+		if len(msgs) == 0 {
+			return
+		}
+		for i, err := range s.ProcessMessages(ctx, nil, msgs) {
+			if err != nil {
+				log.Trace("Failed to process queued proposer slashing", "proposerIndex", msgs[i].Header1.Header.ProposerIndex, "err", err)
+			}
+		}
+	}
+}
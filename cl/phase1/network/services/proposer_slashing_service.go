@@ -20,11 +20,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
 
+	"golang.org/x/time/rate"
+
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon/cl/beacon/beaconevents"
 	"github.com/erigontech/erigon/cl/beacon/synced_data"
 	"github.com/erigontech/erigon/cl/clparams"
 	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/cl/monitor"
 	st "github.com/erigontech/erigon/cl/phase1/core/state"
 	"github.com/erigontech/erigon/cl/phase1/core/state/lru"
 	"github.com/erigontech/erigon/cl/pool"
@@ -32,97 +38,477 @@ import (
 )
 
 type proposerSlashingService struct {
-	operationsPool    pool.OperationsPool
+	slashingsPool     ProposerSlashingPool
 	syncedDataManager synced_data.SyncedData
 	beaconCfg         *clparams.BeaconChainConfig
 	ethClock          eth_clock.EthereumClock
 	emitters          *beaconevents.EventEmitter
-	cache             *lru.Cache[uint64, struct{}]
+	// cache holds, for each proposer with an accepted slashing, the number of
+	// slashings reported for it, the first valid one being the only kept.
+	cache *lru.Cache[uint64, *atomic.Uint64]
+	// seen holds the slashings whose signatures were verified, valid or not, so
+	// that re-gossiped copies are not verified again.
+	seen *lru.Cache[seenProposerSlashing, struct{}]
+	// maxPendingSlashings bounds the number of slashings waiting for inclusion
+	maxPendingSlashings int
+	// inflight holds a token for each slashing being processed by ProcessMessage
+	inflight    chan struct{}
+	subscribers proposerSlashingSubscribers
+	queue       proposerSlashingQueue
+	// peerLimiters holds the token bucket of each gossip peer
+	peerLimiters *lru.Cache[string, *rate.Limiter]
+}
+
+// invalidSlashingError is an ErrInvalidSlashing along with the reason of the rejection,
+// used to label the metrics.
+type invalidSlashingError struct {
+	reason string
+	err    error
 }
 
+func invalidSlashing(reason, format string, args ...any) error {
+	return &invalidSlashingError{
+		reason: reason,
+		err:    fmt.Errorf("%w: "+format, append([]any{ErrInvalidSlashing}, args...)...),
+	}
+}
+
+func (e *invalidSlashingError) Error() string { return e.err.Error() }
+
+func (e *invalidSlashingError) Unwrap() error { return e.err }
+
+// observeProposerSlashing counts the outcome of processing a proposer slashing.
+func observeProposerSlashing(err error) {
+	var invalid *invalidSlashingError
+	switch {
+	case err == nil:
+		monitor.ObserveProposerSlashingAccepted()
+	case errors.Is(err, ErrIgnore):
+		monitor.ObserveProposerSlashingIgnored()
+	case errors.As(err, &invalid):
+		monitor.ObserveProposerSlashingRejected(invalid.reason)
+	}
+}
+
+type seenProposerSlashing struct {
+	proposerIndex uint64
+	root          common.Hash // hash tree root of the slashing, signatures included
+}
+
+const (
+	// defaultMaxPendingProposerSlashings is the bound on the pending slashings if none is configured.
+	defaultMaxPendingProposerSlashings = 256
+	// defaultProposerSlashingQueueDepth is the bound on the slashings being processed,
+	// and on the queued ones, if none is configured.
+	defaultProposerSlashingQueueDepth = 1024
+
+	// a gossip peer may send proposerSlashingPeerBurst slashings at once, and then
+	// proposerSlashingPeerRate per second. A block holds at most 16 of them.
+	proposerSlashingPeerRate         = rate.Limit(2)
+	proposerSlashingPeerBurst        = 16
+	proposerSlashingPeerLimitersSize = 1000
+)
+
+var _ ProposerSlashingService = (*proposerSlashingService)(nil)
+
 func NewProposerSlashingService(
-	operationsPool pool.OperationsPool,
+	slashingsPool ProposerSlashingPool,
 	syncedDataManager synced_data.SyncedData,
 	beaconCfg *clparams.BeaconChainConfig,
 	ethClock eth_clock.EthereumClock,
 	emitters *beaconevents.EventEmitter,
+	maxPendingSlashings int,
+	queueDepth int,
 ) *proposerSlashingService {
-	cache, err := lru.New[uint64, struct{}]("proposer_slashing", proposerSlashingCacheSize)
+	if maxPendingSlashings <= 0 {
+		maxPendingSlashings = defaultMaxPendingProposerSlashings
+	}
+	if queueDepth <= 0 {
+		queueDepth = defaultProposerSlashingQueueDepth
+	}
+	cache, err := lru.New[uint64, *atomic.Uint64]("proposer_slashing", proposerSlashingCacheSize)
+	if err != nil {
+		panic(err)
+	}
+	seen, err := lru.New[seenProposerSlashing, struct{}]("seen_proposer_slashing", seenProposerSlashingCacheSize)
+	if err != nil {
+		panic(err)
+	}
+	peerLimiters, err := lru.New[string, *rate.Limiter]("proposer_slashing_peer_limiters", proposerSlashingPeerLimitersSize)
 	if err != nil {
 		panic(err)
 	}
 	return &proposerSlashingService{
-		operationsPool:    operationsPool,
+		slashingsPool:     slashingsPool,
 		syncedDataManager: syncedDataManager,
 		beaconCfg:         beaconCfg,
 		ethClock:          ethClock,
 		cache:             cache,
+		seen:              seen,
 		emitters:          emitters,
+
+		maxPendingSlashings: maxPendingSlashings,
+		inflight:            make(chan struct{}, queueDepth),
+		queue:               proposerSlashingQueue{depth: queueDepth},
+		peerLimiters:        peerLimiters,
 	}
 }
 
+// ProcessMessage validates a proposer slashing and adds it to the slashings pool.
+// It returns an error wrapping ErrIgnore (ErrAlreadyKnown for duplicates) if the
+// slashing should be ignored, and one wrapping ErrInvalidSlashing if it must be
+// rejected. Any other error is not the fault of the sender.
+//
+// Messages from a gossip peer, see WithGossipPeer, are rate limited per peer; the
+// ones over the limit are ignored with ErrRateLimited.
+//
+// Proposer slashings are gossiped on a global topic, so subnet must be nil: a
+// message with a subnet was misrouted and is rejected.
+//
+// At most the configured queue depth of slashings are processed at once, the ones
+// over it are ignored with ErrBusy rather than waiting.
 func (s *proposerSlashingService) ProcessMessage(ctx context.Context, subnet *uint64, msg *cltypes.ProposerSlashing) error {
-	// https://github.com/ethereum/consensus-specs/blob/dev/specs/phase0/p2p-interface.md#proposer_slashing
+	err := checkProposerSlashingSubnet(subnet)
+	switch {
+	case err != nil:
+	case !s.allowPeer(ctx):
+		err = ErrRateLimited
+	default:
+		select {
+		case s.inflight <- struct{}{}:
+			err = s.processMessage(ctx, msg, false)
+			<-s.inflight
+		default:
+			err = ErrBusy
+		}
+	}
+	observeProposerSlashing(err)
+	return err
+}
 
-	// [IGNORE] The proposer slashing is the first valid proposer slashing received for the proposer with index proposer_slashing.signed_header_1.message.proposer_index
-	pIndex := msg.Header1.Header.ProposerIndex
-	if _, ok := s.cache.Get(pIndex); ok {
-		return ErrIgnore
+// checkProposerSlashingSubnet rejects a proposer slashing received for a subnet, as
+// the proposer_slashing topic is not subnet scoped.
+func checkProposerSlashingSubnet(subnet *uint64) error {
+	if subnet == nil {
+		return nil
+	}
+	return invalidSlashing("unexpected_subnet", "proposer slashings have no subnet, got %d", *subnet)
+}
+
+// allowPeer reports whether the gossip peer of ctx, if any, did not exceed its rate
+// of proposer slashings.
+func (s *proposerSlashingService) allowPeer(ctx context.Context) bool {
+	peer, ok := GossipPeerFromContext(ctx)
+	if !ok || peer.Pid == "" {
+		return true
+	}
+	limiter := rate.NewLimiter(proposerSlashingPeerRate, proposerSlashingPeerBurst)
+	if previous, ok, _ := s.peerLimiters.PeekOrAdd(peer.Pid, limiter); ok {
+		limiter = previous
+	}
+	return limiter.Allow()
+}
+
+// Subscribe returns a channel receiving an event for each proposer slashing added to
+// the pending slashings, and a function to unsubscribe. The channel is closed when the
+// subscriber is dropped for not keeping up, or when it unsubscribes.
+func (s *proposerSlashingService) Subscribe() (<-chan ProposerSlashingEvent, func()) {
+	return s.subscribers.subscribe()
+}
+
+// Validate runs the checks of ProcessMessage on a proposer slashing and returns the
+// same errors, but neither adds the slashing to the operations pool nor records it
+// as seen or reported, nor updates the metrics.
+func (s *proposerSlashingService) Validate(ctx context.Context, msg *cltypes.ProposerSlashing) error {
+	return s.processMessage(ctx, msg, true)
+}
+
+func (s *proposerSlashingService) processMessage(ctx context.Context, msg *cltypes.ProposerSlashing, dryRun bool) error {
+	seenIndex, err := s.checkSlashing(msg, dryRun)
+	if err != nil {
+		return err
+	}
+
+	return s.syncedDataManager.ViewHeadState(func(state *st.CachingBeaconState) error {
+		// do not start the expensive verification if the caller gave up
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		verification, err := s.verificationData(state, msg)
+		if err != nil {
+			return err
+		}
+
+		// Verify signatures for both headers
+		if !dryRun {
+			defer monitor.ObserveProposerSlashingVerificationTime(time.Now())
+		}
+		for i := range verification.Signatures {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			valid, err := blsVerify(verification.Signatures[i], verification.SignRoots[i], verification.Pks[i])
+			if err != nil {
+				return fmt.Errorf("unable to verify signature: %v", err)
+			}
+			if !valid {
+				if !dryRun {
+					s.seen.Add(seenIndex, struct{}{})
+				}
+				return invalidSlashing("invalid_signature", "invalid signature: signature %v, root %v, pubkey %v", verification.Signatures[i], verification.SignRoots[i], verification.Pks[i])
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return s.insert(state, msg, seenIndex, verification)
+	})
+}
+
+// ProcessMessages processes the given proposer slashings like ProcessMessage, but
+// verifies all of their signatures at once. Only if the batch verification fails
+// are the slashings verified one by one, to find the invalid ones. It returns the
+// error of each slashing, in order. Unlike ProcessMessage, it is not rate limited.
+// Like ProcessMessage, all of the slashings are rejected if subnet is not nil.
+func (s *proposerSlashingService) ProcessMessages(ctx context.Context, subnet *uint64, msgs []*cltypes.ProposerSlashing) []error {
+	errs := make([]error, len(msgs))
+	if err := checkProposerSlashingSubnet(subnet); err != nil {
+		for i := range errs {
+			errs[i] = err
+			observeProposerSlashing(err)
+		}
+		return errs
+	}
+	if len(msgs) == 1 {
+		errs[0] = s.processMessage(ctx, msgs[0], false)
+		observeProposerSlashing(errs[0])
+		return errs
+	}
+
+	seenIndexes := make([]seenProposerSlashing, len(msgs))
+	for i, msg := range msgs {
+		seenIndexes[i], errs[i] = s.checkSlashing(msg, false)
 	}
 
-	if s.operationsPool.ProposerSlashingsPool.Has(pool.ComputeKeyForProposerSlashing(msg)) {
-		return ErrIgnore
+	// pending holds the verification data of the slashings that are not done yet
+	pending := make([]*AggregateVerificationData, len(msgs))
+	viewed := false
+	if err := s.syncedDataManager.ViewHeadState(func(state *st.CachingBeaconState) error {
+		viewed = true
+		var signatures, signingRoots, pks [][]byte
+		for i, msg := range msgs {
+			if errs[i] != nil {
+				continue
+			}
+			if pending[i], errs[i] = s.verificationData(state, msg); errs[i] != nil {
+				continue
+			}
+			signatures = append(signatures, pending[i].Signatures...)
+			signingRoots = append(signingRoots, pending[i].SignRoots...)
+			pks = append(pks, pending[i].Pks...)
+		}
+		if len(signatures) == 0 {
+			return nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		valid, err := blsVerifyMultipleSignatures(signatures, signingRoots, pks)
+		monitor.ObserveProposerSlashingVerificationTime(start)
+		if err != nil {
+			return fmt.Errorf("unable to verify signatures: %v", err)
+		}
+
+		for i, verification := range pending {
+			if verification == nil {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !valid {
+				valid, err := blsVerifyMultipleSignatures(verification.Signatures, verification.SignRoots, verification.Pks)
+				if err != nil {
+					errs[i] = fmt.Errorf("unable to verify signatures: %v", err)
+					pending[i] = nil
+					continue
+				}
+				if !valid {
+					s.seen.Add(seenIndexes[i], struct{}{})
+					errs[i] = invalidSlashing("invalid_signature", "invalid signature")
+					pending[i] = nil
+					continue
+				}
+			}
+			errs[i] = s.insert(state, msgs[i], seenIndexes[i], verification)
+			pending[i] = nil
+		}
+		return nil
+	}); err != nil {
+		for i := range errs {
+			if errs[i] == nil && (!viewed || pending[i] != nil) {
+				errs[i] = err
+			}
+		}
 	}
+	for _, err := range errs {
+		observeProposerSlashing(err)
+	}
+	return errs
+}
+
+// PendingSlashings returns the verified proposer slashings that were not included
+// in a block yet.
+func (s *proposerSlashingService) PendingSlashings() []*cltypes.ProposerSlashing {
+	return s.slashingsPool.Raw()
+}
+
+// checkSlashing runs the checks of a proposer slashing that do not need the head state.
+// A slashing passing the validity checks for a proposer with an accepted one is counted
+// as reported, unless on a dry run.
+func (s *proposerSlashingService) checkSlashing(msg *cltypes.ProposerSlashing, dryRun bool) (seenProposerSlashing, error) {
+	// https://github.com/ethereum/consensus-specs/blob/dev/specs/phase0/p2p-interface.md#proposer_slashing
 	h1 := msg.Header1.Header
 	h2 := msg.Header2.Header
 
 	// Verify header slots match
 	if h1.Slot != h2.Slot {
-		return fmt.Errorf("non-matching slots on proposer slashing: %d != %d", h1.Slot, h2.Slot)
+		return seenProposerSlashing{}, invalidSlashing("slot_mismatch", "non-matching slots on proposer slashing: %d != %d", h1.Slot, h2.Slot)
 	}
 
 	// Verify header proposer indices match
 	if h1.ProposerIndex != h2.ProposerIndex {
-		return fmt.Errorf("non-matching proposer indices proposer slashing: %d != %d", h1.ProposerIndex, h2.ProposerIndex)
+		return seenProposerSlashing{}, invalidSlashing("proposer_index_mismatch", "non-matching proposer indices proposer slashing: %d != %d", h1.ProposerIndex, h2.ProposerIndex)
 	}
 
 	// Verify the headers are different
 	if *h1 == *h2 {
-		return errors.New("proposee slashing headers are the same")
+		return seenProposerSlashing{}, invalidSlashing("same_headers", "proposer slashing headers are the same")
 	}
 
-	return s.syncedDataManager.ViewHeadState(func(state *st.CachingBeaconState) error {
-		proposer, err := state.ValidatorForValidatorIndex(int(h1.ProposerIndex))
+	// [IGNORE] The proposer slashing is the first valid proposer slashing received for the proposer with index proposer_slashing.signed_header_1.message.proposer_index
+	pIndex := h1.ProposerIndex
+	if dryRun {
+		if _, ok := s.cache.Peek(pIndex); ok {
+			return seenProposerSlashing{}, ErrAlreadyKnown
+		}
+	} else if s.report(pIndex) {
+		return seenProposerSlashing{}, ErrAlreadyKnown
+	}
+
+	if s.slashingsPool.Has(pool.ComputeKeyForProposerSlashing(msg)) {
+		return seenProposerSlashing{}, ErrAlreadyKnown
+	}
+
+	root, err := msg.HashSSZ()
+	if err != nil {
+		return seenProposerSlashing{}, err
+	}
+	seenIndex := seenProposerSlashing{proposerIndex: pIndex, root: root}
+	if _, ok := s.seen.Get(seenIndex); ok {
+		return seenProposerSlashing{}, ErrAlreadyKnown
+	}
+	return seenIndex, nil
+}
+
+// verificationData checks that the proposer of a slashing is slashable and collects
+// what is needed to verify the signatures of both headers.
+func (s *proposerSlashingService) verificationData(state *st.CachingBeaconState, msg *cltypes.ProposerSlashing) (*AggregateVerificationData, error) {
+	proposer, err := state.ValidatorForValidatorIndex(int(msg.Header1.Header.ProposerIndex))
+	if err != nil {
+		return nil, invalidSlashing("unknown_proposer", "unable to retrieve proposer: %v", err)
+	}
+	// a slashed validator cannot be slashed again, no need to check the signatures
+	if proposer.Slashed() {
+		return nil, &invalidSlashingError{
+			reason: "already_slashed",
+			err:    fmt.Errorf("%w: proposer %d", ErrAlreadySlashed, msg.Header1.Header.ProposerIndex),
+		}
+	}
+	if !proposer.IsSlashable(s.ethClock.GetCurrentEpoch()) {
+		return nil, invalidSlashing("not_slashable", "proposer is not slashable: %v", proposer)
+	}
+
+	pk := proposer.PublicKey()
+	verification := &AggregateVerificationData{}
+	for _, signedHeader := range []*cltypes.SignedBeaconBlockHeader{msg.Header1, msg.Header2} {
+		// the domain is the one of the header's epoch, so that a header signed before
+		// the last fork of the state is checked against the previous fork version.
+		domain, err := state.GetDomain(s.beaconCfg.DomainBeaconProposer, st.GetEpochAtSlot(s.beaconCfg, signedHeader.Header.Slot))
 		if err != nil {
-			return fmt.Errorf("unable to retrieve state: %v", err)
+			return nil, fmt.Errorf("unable to get domain: %v", err)
 		}
-		if !proposer.IsSlashable(s.ethClock.GetCurrentEpoch()) {
-			return fmt.Errorf("proposer is not slashable: %v", proposer)
+		signingRoot, err := computeSigningRoot(signedHeader.Header, domain)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute signing root: %v", err)
 		}
+		verification.Signatures = append(verification.Signatures, signedHeader.Signature[:])
+		verification.SignRoots = append(verification.SignRoots, signingRoot[:])
+		verification.Pks = append(verification.Pks, pk[:])
+	}
+	return verification, nil
+}
 
-		// Verify signatures for both headers
-		for _, signedHeader := range []*cltypes.SignedBeaconBlockHeader{msg.Header1, msg.Header2} {
-			domain, err := state.GetDomain(s.beaconCfg.DomainBeaconProposer, st.GetEpochAtSlot(s.beaconCfg, signedHeader.Header.Slot))
-			if err != nil {
-				return fmt.Errorf("unable to get domain: %v", err)
-			}
-			pk := proposer.PublicKey()
-			signingRoot, err := computeSigningRoot(signedHeader, domain)
-			if err != nil {
-				return fmt.Errorf("unable to compute signing root: %v", err)
-			}
-			valid, err := blsVerify(signedHeader.Signature[:], signingRoot[:], pk[:])
-			if err != nil {
-				return fmt.Errorf("unable to verify signature: %v", err)
+// report counts one more slashing reported for proposerIndex if one was already
+// accepted for it, in which case it returns true.
+func (s *proposerSlashingService) report(proposerIndex uint64) bool {
+	reports, ok := s.cache.Get(proposerIndex)
+	if !ok {
+		return false
+	}
+	reports.Add(1)
+	monitor.ObserveProposerSlashingDuplicate()
+	return true
+}
+
+// Reports returns the number of slashings reported for proposerIndex since one was
+// accepted, including the accepted one, or 0 if none was.
+func (s *proposerSlashingService) Reports(proposerIndex uint64) uint64 {
+	reports, ok := s.cache.Peek(proposerIndex)
+	if !ok {
+		return 0
+	}
+	return reports.Load()
+}
+
+// insert adds a verified proposer slashing to the operations pool, unless one for
+// the same proposer was added in the meantime. When the pool is full, the pending
+// slashings of proposers already slashed in state are evicted, and if none are,
+// the slashing is ignored for now.
+func (s *proposerSlashingService) insert(state *st.CachingBeaconState, msg *cltypes.ProposerSlashing, seenIndex seenProposerSlashing, verification *AggregateVerificationData) error {
+	if s.report(seenIndex.proposerIndex) {
+		return ErrAlreadyKnown
+	}
+	if pending := s.PendingSlashings(); len(pending) >= s.maxPendingSlashings {
+		evicted := 0
+		for _, slashing := range pending {
+			proposer, err := state.ValidatorForValidatorIndex(int(slashing.Header1.Header.ProposerIndex))
+			if err != nil || !proposer.Slashed() {
+				continue
 			}
-			if !valid {
-				return fmt.Errorf("invalid signature: signature %v, root %v, pubkey %v", signedHeader.Signature[:], signingRoot[:], pk)
+			if s.slashingsPool.DeleteIfExist(pool.ComputeKeyForProposerSlashing(slashing)) {
+				evicted++
 			}
 		}
-
-		s.operationsPool.ProposerSlashingsPool.Insert(pool.ComputeKeyForProposerSlashing(msg), msg)
-		s.cache.Add(pIndex, struct{}{})
-		s.emitters.Operation().SendProposerSlashing(msg)
-		return nil
+		if len(pending)-evicted >= s.maxPendingSlashings {
+			return ErrPendingSlashingsFull
+		}
+	}
+	s.slashingsPool.Insert(pool.ComputeKeyForProposerSlashing(msg), msg)
+	reports := &atomic.Uint64{}
+	reports.Store(1)
+	s.cache.Add(seenIndex.proposerIndex, reports)
+	s.seen.Add(seenIndex, struct{}{})
+	s.emitters.Operation().SendProposerSlashing(msg)
+	s.subscribers.send(ProposerSlashingEvent{
+		ProposerIndex: seenIndex.proposerIndex,
+		SigningRoots:  [2]common.Hash{common.BytesToHash(verification.SignRoots[0]), common.BytesToHash(verification.SignRoots[1])},
 	})
+	return nil
 }
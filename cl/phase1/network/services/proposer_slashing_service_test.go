@@ -19,16 +19,22 @@ package services
 import (
 	"context"
 	"log"
+	"sync/atomic"
 	"testing"
 
 	"github.com/erigontech/erigon-lib/common"
+	sentinel "github.com/erigontech/erigon-lib/gointerfaces/sentinelproto"
+	"github.com/erigontech/erigon-lib/metrics"
 	"github.com/erigontech/erigon/cl/antiquary/tests"
 	"github.com/erigontech/erigon/cl/beacon/beaconevents"
 	"github.com/erigontech/erigon/cl/beacon/synced_data"
 	"github.com/erigontech/erigon/cl/clparams"
 	"github.com/erigontech/erigon/cl/cltypes"
 	"github.com/erigontech/erigon/cl/cltypes/solid"
+	"github.com/erigontech/erigon/cl/fork"
+	"github.com/erigontech/erigon/cl/phase1/network/services/slashingtest"
 	"github.com/erigontech/erigon/cl/pool"
+	"github.com/erigontech/erigon/cl/utils/bls"
 	"github.com/erigontech/erigon/cl/utils/eth_clock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
@@ -58,7 +64,7 @@ func (t *proposerSlashingTestSuite) SetupTest() {
 		SlotsPerEpoch: 2,
 	}
 	emitters := beaconevents.NewEventEmitter()
-	t.proposerSlashingService = NewProposerSlashingService(*t.operationsPool, t.syncedData, t.beaconCfg, t.ethClock, emitters)
+	t.proposerSlashingService = NewProposerSlashingService(t.operationsPool.ProposerSlashingsPool, t.syncedData, t.beaconCfg, t.ethClock, emitters, 0, 0)
 	// mock global functions
 	t.mockFuncs = &mockFuncs{ctrl: t.gomockCtrl}
 	computeSigningRoot = t.mockFuncs.ComputeSigningRoot
@@ -117,11 +123,11 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 		{
 			name: "ignore proposer slashing",
 			mock: func() {
-				t.proposerSlashingService.cache.Add(mockProposerIndex, struct{}{})
+				t.proposerSlashingService.cache.Add(mockProposerIndex, &atomic.Uint64{})
 			},
 			msg:     mockMsg,
 			wantErr: true,
-			err:     ErrIgnore,
+			err:     ErrAlreadyKnown,
 		},
 		{
 			name: "ignore proposer slashing in pool",
@@ -130,7 +136,7 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 			},
 			msg:     mockMsg,
 			wantErr: true,
-			err:     ErrIgnore,
+			err:     ErrAlreadyKnown,
 		},
 		{
 			name: "non-matching slots",
@@ -150,6 +156,7 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 				},
 			},
 			wantErr: true,
+			err:     ErrInvalidSlashing,
 		},
 		{
 			name: "non-matching proposer indices",
@@ -169,6 +176,7 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 				},
 			},
 			wantErr: true,
+			err:     ErrInvalidSlashing,
 		},
 		{
 			name: "empty head state",
@@ -187,6 +195,29 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 			},
 			msg:     mockMsg2,
 			wantErr: true,
+			err:     ErrInvalidSlashing,
+		},
+		{
+			name: "proposer is already slashed",
+			mock: func() {
+				mockValidator := solid.NewValidatorFromParameters(
+					[48]byte{},
+					[32]byte{},
+					0,
+					true,
+					0,
+					0,
+					10,
+					10,
+				)
+				_, st, _ := tests.GetBellatrixRandom()
+				st.ValidatorSet().Set(int(mockProposerIndex), mockValidator)
+				t.syncedData.OnHeadState(st)
+				// neither the slashable epochs nor the signatures are checked
+			},
+			msg:     mockMsg,
+			wantErr: true,
+			err:     ErrAlreadySlashed,
 		},
 		{
 			name: "proposer is not slashable",
@@ -209,6 +240,7 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 			},
 			msg:     mockMsg,
 			wantErr: true,
+			err:     ErrInvalidSlashing,
 		},
 		{
 			name: "pass",
@@ -226,8 +258,8 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 				// )
 				t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).Times(1)
 
-				t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", mockMsg.Header1, gomock.Any()).Return([32]byte{}, nil).Times(1)
-				t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", mockMsg.Header2, gomock.Any()).Return([32]byte{}, nil).Times(1)
+				t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", mockMsg.Header1.Header, gomock.Any()).Return([32]byte{}, nil).Times(1)
+				t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", mockMsg.Header2.Header, gomock.Any()).Return([32]byte{}, nil).Times(1)
 				t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
 			},
 			msg:     mockMsg,
@@ -243,7 +275,7 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 		if tt.wantErr {
 			t.Error(err)
 			if tt.err != nil {
-				t.Equal(tt.err, err)
+				t.ErrorIs(err, tt.err)
 			}
 		} else {
 			t.NoError(err)
@@ -252,6 +284,580 @@ func (t *proposerSlashingTestSuite) TestProcessMessage() {
 	}
 }
 
+func (t *proposerSlashingTestSuite) TestProcessMessageSkipsSeen() {
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+
+	// a valid slashing is verified once
+	valid := mockMsgForProposer(123)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, valid))
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, valid), ErrAlreadyKnown)
+
+	// so is a slashing with an invalid signature
+	invalid := mockMsgForProposer(124)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, invalid), ErrInvalidSlashing)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, invalid), ErrAlreadyKnown)
+
+	// but a differently signed copy is verified again
+	resigned := mockMsgForProposer(124)
+	resigned.Header1.Signature = common.Bytes96{7, 8, 9}
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, resigned), ErrInvalidSlashing)
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageCancelled() {
+	msg := &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{1}},
+			Signature: common.Bytes96{1, 2, 3},
+		},
+		Header2: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{2}},
+			Signature: common.Bytes96{4, 5, 6},
+		},
+	}
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+
+	// no signature is verified for a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(ctx, nil, msg), context.Canceled)
+
+	// nor is the slashing added to the pool if the context is cancelled during verification
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_, _, _ []byte) (bool, error) {
+		cancel()
+		return true, nil
+	}).Times(1)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(ctx, nil, msg), context.Canceled)
+	t.False(t.operationsPool.ProposerSlashingsPool.Has(pool.ComputeKeyForProposerSlashing(msg)))
+
+	// and it can be processed again later
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg))
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessages() {
+	mockBlsVerifyMultipleSignatures := func(valid func(signatures [][]byte) bool) {
+		blsVerifyMultipleSignatures = func(signatures, signRoots, pks [][]byte) (bool, error) {
+			return valid(signatures), nil
+		}
+	}
+	defer func() { blsVerifyMultipleSignatures = bls.VerifyMultipleSignatures }()
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).AnyTimes()
+
+	// the signatures of proposer 2 are invalid
+	batches := 0
+	mockBlsVerifyMultipleSignatures(func(signatures [][]byte) bool {
+		batches++
+		for _, signature := range signatures {
+			if signature[0] == 2 {
+				return false
+			}
+		}
+		return true
+	})
+	msgs := []*cltypes.ProposerSlashing{mockMsgForProposer(1), mockMsgForProposer(2), mockMsgForProposer(3), mockMsgForProposer(1)}
+	msgs[1].Header1.Signature = common.Bytes96{2, 3}
+	errs := t.proposerSlashingService.ProcessMessages(context.Background(), nil, msgs)
+	t.Require().Len(errs, len(msgs))
+	t.NoError(errs[0])
+	t.ErrorIs(errs[1], ErrInvalidSlashing)
+	t.NoError(errs[2])
+	t.ErrorIs(errs[3], ErrAlreadyKnown)
+	// one batch, then one verification per slashing
+	t.Equal(1+len(msgs), batches)
+	for i, msg := range msgs {
+		t.Equal(i != 1, t.operationsPool.ProposerSlashingsPool.Has(pool.ComputeKeyForProposerSlashing(msg)))
+	}
+
+	// a valid batch is verified once
+	batches = 0
+	msgs = []*cltypes.ProposerSlashing{mockMsgForProposer(4), mockMsgForProposer(5), mockMsgForProposer(6)}
+	for _, err := range t.proposerSlashingService.ProcessMessages(context.Background(), nil, msgs) {
+		t.NoError(err)
+	}
+	t.Equal(1, batches)
+
+	// already processed slashings are not verified again
+	batches = 0
+	msgs = []*cltypes.ProposerSlashing{mockMsgForProposer(4), mockMsgForProposer(7)}
+	msgs[1].Header2.Header.Slot = 2
+	errs = t.proposerSlashingService.ProcessMessages(context.Background(), nil, msgs)
+	t.ErrorIs(errs[0], ErrAlreadyKnown)
+	t.ErrorIs(errs[1], ErrInvalidSlashing)
+	t.Equal(0, batches)
+
+	// the errors stay aligned with the slashings when some fail before verification
+	batches = 0
+	msgs = []*cltypes.ProposerSlashing{mockMsgForProposer(8), mockMsgForProposer(9), mockMsgForProposer(10), mockMsgForProposer(2), mockMsgForProposer(11)}
+	msgs[1].Header2.Header.ProposerIndex = 99
+	msgs[2].Header2.Signature = common.Bytes96{2}
+	errs = t.proposerSlashingService.ProcessMessages(context.Background(), nil, msgs)
+	t.Require().Len(errs, len(msgs))
+	t.NoError(errs[0])
+	t.ErrorIs(errs[1], ErrInvalidSlashing)
+	t.ErrorIs(errs[2], ErrInvalidSlashing)
+	t.ErrorIs(errs[3], ErrInvalidSlashing)
+	t.NoError(errs[4])
+	// one batch, then one verification per slashing that reached it
+	t.Equal(1+4, batches)
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageMetrics() {
+	msg := &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{1}},
+			Signature: common.Bytes96{1, 2, 3},
+		},
+		Header2: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{2}},
+			Signature: common.Bytes96{4, 5, 6},
+		},
+	}
+	accepted := metrics.GetOrCreateCounter("proposer_slashing_accepted")
+	ignored := metrics.GetOrCreateCounter("proposer_slashing_ignored")
+	slotMismatch := metrics.GetOrCreateCounter(`proposer_slashing_rejected{reason="slot_mismatch"}`)
+	acceptedBefore, ignoredBefore, slotMismatchBefore := accepted.GetValueUint64(), ignored.GetValueUint64(), slotMismatch.GetValueUint64()
+
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg))
+	t.Require().Error(t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg))
+	msg = &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{Header: &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 124}},
+		Header2: &cltypes.SignedBeaconBlockHeader{Header: &cltypes.BeaconBlockHeader{Slot: 2, ProposerIndex: 124}},
+	}
+	t.Require().Error(t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg))
+
+	t.Equal(acceptedBefore+1, accepted.GetValueUint64())
+	t.Equal(ignoredBefore+1, ignored.GetValueUint64())
+	t.Equal(slotMismatchBefore+1, slotMismatch.GetValueUint64())
+}
+
+func (t *proposerSlashingTestSuite) TestPendingSlashings() {
+	msg := &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{1}},
+			Signature: common.Bytes96{1, 2, 3},
+		},
+		Header2: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{2}},
+			Signature: common.Bytes96{4, 5, 6},
+		},
+	}
+	t.Empty(t.proposerSlashingService.PendingSlashings())
+
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg))
+	t.Equal([]*cltypes.ProposerSlashing{msg}, t.proposerSlashingService.PendingSlashings())
+
+	// an included slashing is removed from the pool, see OperationsPool.NotifyBlock
+	t.operationsPool.ProposerSlashingsPool.DeleteIfExist(pool.ComputeKeyForProposerSlashing(msg))
+	t.Empty(t.proposerSlashingService.PendingSlashings())
+}
+
+func (t *proposerSlashingTestSuite) TestMaxPendingSlashings() {
+	t.proposerSlashingService = NewProposerSlashingService(t.operationsPool.ProposerSlashingsPool, t.syncedData, t.beaconCfg, t.ethClock, beaconevents.NewEventEmitter(), 2, 0)
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
+	// fill the pool
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(1)))
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(2)))
+
+	// a slashing that does not fit is ignored, and can be sent again later
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(3)), ErrPendingSlashingsFull)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(3)), ErrPendingSlashingsFull)
+	t.Len(t.proposerSlashingService.PendingSlashings(), 2)
+
+	// once a pending slashing is applied on chain, it makes room for a new one
+	_, st, _ := tests.GetBellatrixRandom()
+	slashed, err := st.ValidatorForValidatorIndex(1)
+	t.Require().NoError(err)
+	slashed.SetSlashed(true)
+	st.ValidatorSet().Set(1, slashed)
+	t.syncedData.OnHeadState(st)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(3)))
+	t.ElementsMatch([]*cltypes.ProposerSlashing{mockMsgForProposer(2), mockMsgForProposer(3)}, t.proposerSlashingService.PendingSlashings())
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageForkDomain() {
+	const forkEpoch = 10
+	computeSigningRoot = fork.ComputeSigningRoot
+	blsVerify = bls.Verify
+	key, err := bls.GenerateKey()
+	t.Require().NoError(err)
+	_, st, _ := tests.GetBellatrixRandom()
+	st.SetFork(&cltypes.Fork{PreviousVersion: [4]byte{1}, CurrentVersion: [4]byte{2}, Epoch: forkEpoch})
+	for i := 123; i < 127; i++ {
+		st.ValidatorSet().Set(i, slashingtest.NewValidator(key))
+	}
+	t.syncedData.OnHeadState(st)
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+	sign := func(header *cltypes.BeaconBlockHeader, version [4]byte) *cltypes.SignedBeaconBlockHeader {
+		domain, err := fork.ComputeDomain(t.beaconCfg.DomainBeaconProposer[:], version, st.GenesisValidatorsRoot())
+		t.Require().NoError(err)
+		signingRoot, err := fork.ComputeSigningRoot(header, domain)
+		t.Require().NoError(err)
+		signed := &cltypes.SignedBeaconBlockHeader{Header: header}
+		copy(signed.Signature[:], key.Sign(signingRoot[:]).Bytes())
+		return signed
+	}
+
+	forkSlot := forkEpoch * t.beaconCfg.SlotsPerEpoch
+	for i, tc := range []struct {
+		slot    uint64
+		version [4]byte
+		err     error
+	}{
+		{slot: forkSlot - 1, version: [4]byte{1}},
+		{slot: forkSlot, version: [4]byte{2}},
+		// signed with the fork version of the other side of the fork
+		{slot: forkSlot - 1, version: [4]byte{2}, err: ErrInvalidSlashing},
+		{slot: forkSlot, version: [4]byte{1}, err: ErrInvalidSlashing},
+	} {
+		proposerIndex := uint64(123 + i)
+		msg := &cltypes.ProposerSlashing{
+			Header1: sign(&cltypes.BeaconBlockHeader{Slot: tc.slot, ProposerIndex: proposerIndex, Root: common.Hash{1}}, tc.version),
+			Header2: sign(&cltypes.BeaconBlockHeader{Slot: tc.slot, ProposerIndex: proposerIndex, Root: common.Hash{2}}, tc.version),
+		}
+		err := t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg)
+		if tc.err == nil {
+			t.Require().NoError(err, "slot %d", tc.slot)
+		} else {
+			t.Require().ErrorIs(err, tc.err, "slot %d", tc.slot)
+		}
+	}
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageSignedFixtures() {
+	computeSigningRoot = fork.ComputeSigningRoot
+	blsVerify = bls.Verify
+	key, err := bls.GenerateKey()
+	t.Require().NoError(err)
+	otherKey, err := bls.GenerateKey()
+	t.Require().NoError(err)
+	_, st, _ := tests.GetBellatrixRandom()
+	for i := 10; i < 13; i++ {
+		st.ValidatorSet().Set(i, slashingtest.NewValidator(key))
+	}
+	t.syncedData.OnHeadState(st)
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+
+	valid, err := slashingtest.NewProposerSlashing(st, t.beaconCfg, key, 10, 4, 4)
+	t.Require().NoError(err)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, valid))
+
+	slotMismatch, err := slashingtest.NewProposerSlashing(st, t.beaconCfg, key, 11, 4, 5)
+	t.Require().NoError(err)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, slotMismatch), ErrInvalidSlashing)
+
+	wrongKey, err := slashingtest.NewProposerSlashing(st, t.beaconCfg, otherKey, 12, 4, 4)
+	t.Require().NoError(err)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, wrongKey), ErrInvalidSlashing)
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageAggregatesReports() {
+	computeSigningRoot = fork.ComputeSigningRoot
+	blsVerify = bls.Verify
+	key, err := bls.GenerateKey()
+	t.Require().NoError(err)
+	_, st, _ := tests.GetBellatrixRandom()
+	st.ValidatorSet().Set(10, slashingtest.NewValidator(key))
+	t.syncedData.OnHeadState(st)
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+
+	first, err := slashingtest.NewProposerSlashing(st, t.beaconCfg, key, 10, 4, 4)
+	t.Require().NoError(err)
+	second, err := slashingtest.NewProposerSlashing(st, t.beaconCfg, key, 10, 6, 6)
+	t.Require().NoError(err)
+	t.Require().Zero(t.proposerSlashingService.Reports(10))
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, first))
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, second), ErrAlreadyKnown)
+	t.Require().Equal([]*cltypes.ProposerSlashing{first}, t.proposerSlashingService.PendingSlashings())
+	t.Require().Equal(uint64(2), t.proposerSlashingService.Reports(10))
+
+	// invalid, misrouted or throttled slashings are not counted
+	invalid, err := slashingtest.NewProposerSlashing(st, t.beaconCfg, key, 10, 6, 7)
+	t.Require().NoError(err)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, invalid), ErrInvalidSlashing)
+	subnet := uint64(0)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), &subnet, second), ErrInvalidSlashing)
+	t.proposerSlashingService.inflight = make(chan struct{})
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, second), ErrBusy)
+	t.Require().Equal(uint64(2), t.proposerSlashingService.Reports(10))
+}
+
+func (t *proposerSlashingTestSuite) TestValidate() {
+	msg := &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{1}},
+			Signature: common.Bytes96{1, 2, 3},
+		},
+		Header2: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{2}},
+			Signature: common.Bytes96{4, 5, 6},
+		},
+	}
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).AnyTimes()
+
+	// an invalid signature is reported, but not remembered
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	t.ErrorIs(t.proposerSlashingService.Validate(context.Background(), msg), ErrInvalidSlashing)
+
+	// a valid slashing is not added to the pending slashings
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(4)
+	t.NoError(t.proposerSlashingService.Validate(context.Background(), msg))
+	t.Empty(t.proposerSlashingService.PendingSlashings())
+
+	// so it can still be processed
+	t.NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg))
+	t.Len(t.proposerSlashingService.PendingSlashings(), 1)
+	t.ErrorIs(t.proposerSlashingService.Validate(context.Background(), msg), ErrAlreadyKnown)
+
+	// validating duplicates neither counts them as reported nor in the metrics
+	duplicate := metrics.GetOrCreateCounter("proposer_slashing_duplicate")
+	duplicateBefore := duplicate.GetValueUint64()
+	resigned := *msg
+	resigned.Header1 = &cltypes.SignedBeaconBlockHeader{Header: msg.Header1.Header, Signature: common.Bytes96{7, 8, 9}}
+	for i := 0; i < 3; i++ {
+		t.ErrorIs(t.proposerSlashingService.Validate(context.Background(), &resigned), ErrAlreadyKnown)
+	}
+	t.Equal(uint64(1), t.proposerSlashingService.Reports(123))
+	t.Equal(duplicateBefore, duplicate.GetValueUint64())
+}
+
+func (t *proposerSlashingTestSuite) TestSubscribe() {
+	msg := &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{1}},
+			Signature: common.Bytes96{1, 2, 3},
+		},
+		Header2: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: 123, Root: common.Hash{2}},
+			Signature: common.Bytes96{4, 5, 6},
+		},
+	}
+	events, unsubscribe := t.proposerSlashingService.Subscribe()
+	defer unsubscribe()
+
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", msg.Header1.Header, gomock.Any()).Return([32]byte{1}, nil).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", msg.Header2.Header, gomock.Any()).Return([32]byte{2}, nil).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, msg))
+	t.Require().Len(events, 1)
+	t.Equal(ProposerSlashingEvent{ProposerIndex: 123, SigningRoots: [2]common.Hash{{1}, {2}}}, <-events)
+
+	// a subscriber that does not keep up is dropped
+	slow, unsubscribeSlow := t.proposerSlashingService.Subscribe()
+	defer unsubscribeSlow()
+	for i := 0; i <= proposerSlashingSubscriberBuffer; i++ {
+		t.proposerSlashingService.subscribers.send(ProposerSlashingEvent{ProposerIndex: uint64(i)})
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	t.Equal(proposerSlashingSubscriberBuffer, received)
+}
+
+func (t *proposerSlashingTestSuite) TestEnqueue() {
+	blsVerifyMultipleSignatures = func(_, _, _ [][]byte) (bool, error) { return true, nil }
+	defer func() { blsVerifyMultipleSignatures = bls.VerifyMultipleSignatures }()
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).AnyTimes()
+	processed := func() []uint64 {
+		events, unsubscribe := t.proposerSlashingService.Subscribe()
+		defer unsubscribe()
+		t.proposerSlashingService.processQueue(context.Background())
+		var proposers []uint64
+		for len(events) > 0 {
+			proposers = append(proposers, (<-events).ProposerIndex)
+		}
+		return proposers
+	}
+
+	// a second slashing of a queued proposer comes last
+	first, duplicate, second := mockMsgForProposer(1), mockMsgForProposer(1), mockMsgForProposer(2)
+	duplicate.Header1.Signature = common.Bytes96{9}
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, first))
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, duplicate))
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, second))
+	t.Equal([]*cltypes.ProposerSlashing{first, second, duplicate}, t.proposerSlashingService.queue.pop(proposerSlashingQueueBatchSize))
+	for _, msg := range []*cltypes.ProposerSlashing{first, duplicate, second} {
+		t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, msg))
+	}
+	t.Equal([]uint64{1, 2}, processed())
+	t.Len(t.proposerSlashingService.PendingSlashings(), 2)
+
+	// a custom priority
+	t.proposerSlashingService.SetPriority(func(msg *cltypes.ProposerSlashing) int {
+		return int(msg.Header1.Header.ProposerIndex)
+	})
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, mockMsgForProposer(3)))
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, mockMsgForProposer(5)))
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, mockMsgForProposer(4)))
+	t.Equal([]uint64{5, 4, 3}, processed())
+
+	// a slashing with a subnet is rejected rather than queued
+	subnet := uint64(0)
+	t.Require().ErrorIs(t.proposerSlashingService.Enqueue(context.Background(), &subnet, mockMsgForProposer(6)), ErrInvalidSlashing)
+	t.Empty(processed())
+}
+
+func (t *proposerSlashingTestSuite) TestQueueDepth() {
+	t.proposerSlashingService = NewProposerSlashingService(t.operationsPool.ProposerSlashingsPool, t.syncedData, t.beaconCfg, t.ethClock, beaconevents.NewEventEmitter(), 0, 2)
+	blsVerifyMultipleSignatures = func(_, _, _ [][]byte) (bool, error) { return true, nil }
+	defer func() { blsVerifyMultipleSignatures = bls.VerifyMultipleSignatures }()
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
+	// a full queue refuses slashings until it is processed
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, mockMsgForProposer(1)))
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, mockMsgForProposer(2)))
+	t.Require().ErrorIs(t.proposerSlashingService.Enqueue(context.Background(), nil, mockMsgForProposer(3)), ErrBusy)
+	t.proposerSlashingService.processQueue(context.Background())
+	t.Require().Len(t.proposerSlashingService.PendingSlashings(), 2)
+	t.Require().NoError(t.proposerSlashingService.Enqueue(context.Background(), nil, mockMsgForProposer(3)))
+
+	// so does ProcessMessage while as many slashings are being processed, ignoring them
+	t.proposerSlashingService.inflight <- struct{}{}
+	t.proposerSlashingService.inflight <- struct{}{}
+	err := t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(4))
+	t.Require().ErrorIs(err, ErrBusy)
+	t.Require().ErrorIs(err, ErrIgnore)
+	<-t.proposerSlashingService.inflight
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(4)))
+	// and gives its token back once done
+	t.Require().Len(t.proposerSlashingService.inflight, 1)
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageRateLimited() {
+	bursting := WithGossipPeer(context.Background(), &sentinel.Peer{Pid: "bursting"})
+	other := WithGossipPeer(context.Background(), &sentinel.Peer{Pid: "other"})
+
+	// the bursting peer gets throttled, without the messages being verified
+	for i := 0; i < proposerSlashingPeerBurst; i++ {
+		t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(bursting, nil, mockMsgWithSlots(uint64(i))), ErrInvalidSlashing)
+	}
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(bursting, nil, mockMsgForProposer(1)), ErrRateLimited)
+
+	// while others proceed
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(other, nil, mockMsgForProposer(1)))
+	// and so do local messages
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgWithSlots(0)), ErrInvalidSlashing)
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageSubnet() {
+	subnet := uint64(0)
+
+	// a message with a subnet is rejected without being verified
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), &subnet, mockMsgForProposer(1)), ErrInvalidSlashing)
+	for _, err := range t.proposerSlashingService.ProcessMessages(context.Background(), &subnet, []*cltypes.ProposerSlashing{mockMsgForProposer(1), mockMsgForProposer(2)}) {
+		t.Require().ErrorIs(err, ErrInvalidSlashing)
+	}
+	t.Require().Empty(t.proposerSlashingService.PendingSlashings())
+
+	// while one without is processed
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).Times(1)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).Times(2)
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, mockMsgForProposer(1)))
+}
+
+// mockMsgForProposer returns a proposer slashing of proposerIndex, with signatures
+// distinct for each proposer.
+func mockMsgForProposer(proposerIndex uint64) *cltypes.ProposerSlashing {
+	return &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: proposerIndex, Root: common.Hash{1}},
+			Signature: common.Bytes96{byte(proposerIndex), 1},
+		},
+		Header2: &cltypes.SignedBeaconBlockHeader{
+			Header:    &cltypes.BeaconBlockHeader{Slot: 1, ProposerIndex: proposerIndex, Root: common.Hash{2}},
+			Signature: common.Bytes96{byte(proposerIndex), 2},
+		},
+	}
+}
+
+// mockMsgWithSlots returns a proposer slashing with non-matching slots.
+func mockMsgWithSlots(slot uint64) *cltypes.ProposerSlashing {
+	return &cltypes.ProposerSlashing{
+		Header1: &cltypes.SignedBeaconBlockHeader{Header: &cltypes.BeaconBlockHeader{Slot: slot, ProposerIndex: 2}},
+		Header2: &cltypes.SignedBeaconBlockHeader{Header: &cltypes.BeaconBlockHeader{Slot: slot + 1, ProposerIndex: 2}},
+	}
+}
+
+// mockProposerSlashingPool is a ProposerSlashingPool recording its calls, see mockFuncs.
+type mockProposerSlashingPool struct {
+	ctrl *gomock.Controller
+}
+
+func (m *mockProposerSlashingPool) Insert(key common.Bytes96, slashing *cltypes.ProposerSlashing) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Insert", key, slashing)
+}
+
+func (m *mockProposerSlashingPool) Has(key common.Bytes96) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Has", key)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+func (m *mockProposerSlashingPool) Raw() []*cltypes.ProposerSlashing {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Raw")
+	ret0, _ := ret[0].([]*cltypes.ProposerSlashing)
+	return ret0
+}
+
+func (m *mockProposerSlashingPool) DeleteIfExist(key common.Bytes96) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIfExist", key)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+func (t *proposerSlashingTestSuite) TestProcessMessageInsertsInPool() {
+	mockPool := &mockProposerSlashingPool{ctrl: t.gomockCtrl}
+	t.proposerSlashingService = NewProposerSlashingService(mockPool, t.syncedData, t.beaconCfg, t.ethClock, beaconevents.NewEventEmitter(), 0, 0)
+	t.ethClock.EXPECT().GetCurrentEpoch().Return(uint64(1)).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "ComputeSigningRoot", gomock.Any(), gomock.Any()).Return([32]byte{}, nil).AnyTimes()
+	t.mockFuncs.ctrl.RecordCall(t.mockFuncs, "BlsVerify", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+	t.gomockCtrl.RecordCall(mockPool, "Has", gomock.Any()).Return(false).AnyTimes()
+	t.gomockCtrl.RecordCall(mockPool, "Raw").Return(nil).AnyTimes()
+
+	accepted, duplicate, other := mockMsgForProposer(1), mockMsgForProposer(1), mockMsgForProposer(2)
+	duplicate.Header1.Signature = common.Bytes96{9}
+	t.gomockCtrl.RecordCall(mockPool, "Insert", pool.ComputeKeyForProposerSlashing(accepted), accepted).Times(1)
+	t.gomockCtrl.RecordCall(mockPool, "Insert", pool.ComputeKeyForProposerSlashing(other), other).Times(1)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, accepted))
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, accepted), ErrAlreadyKnown)
+	t.Require().ErrorIs(t.proposerSlashingService.ProcessMessage(context.Background(), nil, duplicate), ErrAlreadyKnown)
+	t.Require().NoError(t.proposerSlashingService.ProcessMessage(context.Background(), nil, other))
+}
+
 func TestProposerSlashing(t *testing.T) {
 	suite.Run(t, new(proposerSlashingTestSuite))
 }
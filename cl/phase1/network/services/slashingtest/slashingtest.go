@@ -0,0 +1,78 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package slashingtest provides builders for slashing fixtures signed with real BLS keys.
+package slashingtest
+
+import (
+	"math"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/cl/abstract"
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/cl/cltypes/solid"
+	"github.com/erigontech/erigon/cl/fork"
+	"github.com/erigontech/erigon/cl/utils/bls"
+)
+
+// NewValidator returns an active, slashable validator owning the public key of key.
+func NewValidator(key *bls.PrivateKey) solid.Validator {
+	var pk [48]byte
+	copy(pk[:], bls.CompressPublicKey(key.PublicKey()))
+	return solid.NewValidatorFromParameters(pk, [32]byte{}, 32_000_000_000, false, 0, 0, math.MaxUint64, math.MaxUint64)
+}
+
+// SignHeader signs header with key, using the DOMAIN_BEACON_PROPOSER of the header's epoch in s.
+func SignHeader(s abstract.BeaconStateReader, beaconCfg *clparams.BeaconChainConfig, key *bls.PrivateKey, header *cltypes.BeaconBlockHeader) (*cltypes.SignedBeaconBlockHeader, error) {
+	domain, err := s.GetDomain(beaconCfg.DomainBeaconProposer, header.Slot/beaconCfg.SlotsPerEpoch)
+	if err != nil {
+		return nil, err
+	}
+	signingRoot, err := fork.ComputeSigningRoot(header, domain)
+	if err != nil {
+		return nil, err
+	}
+	signed := &cltypes.SignedBeaconBlockHeader{Header: header}
+	copy(signed.Signature[:], key.Sign(signingRoot[:]).Bytes())
+	return signed, nil
+}
+
+// NewProposerSlashing builds a proposer slashing of the validator at proposerIndex, made
+// of two distinct headers at slot1 and slot2 signed with key. The evidence is only valid
+// when both slots are equal, distinct slots are meant for negative tests.
+func NewProposerSlashing(s abstract.BeaconStateReader, beaconCfg *clparams.BeaconChainConfig, key *bls.PrivateKey, proposerIndex, slot1, slot2 uint64) (*cltypes.ProposerSlashing, error) {
+	header1, err := SignHeader(s, beaconCfg, key, &cltypes.BeaconBlockHeader{
+		Slot:          slot1,
+		ProposerIndex: proposerIndex,
+		BodyRoot:      common.Hash{1},
+	})
+	if err != nil {
+		return nil, err
+	}
+	header2, err := SignHeader(s, beaconCfg, key, &cltypes.BeaconBlockHeader{
+		Slot:          slot2,
+		ProposerIndex: proposerIndex,
+		BodyRoot:      common.Hash{2},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cltypes.ProposerSlashing{
+		Header1: header1,
+		Header2: header2,
+	}, nil
+}
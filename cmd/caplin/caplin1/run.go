@@ -324,10 +324,11 @@ func RunCaplinService(ctx context.Context, engine execution_client.ExecutionEngi
 	aggregateAndProofService := services.NewAggregateAndProofService(ctx, syncedDataManager, forkChoice, beaconConfig, pool, false, batchSignatureVerifier)
 	voluntaryExitService := services.NewVoluntaryExitService(pool, emitters, syncedDataManager, beaconConfig, ethClock, batchSignatureVerifier)
 	blsToExecutionChangeService := services.NewBLSToExecutionChangeService(pool, emitters, syncedDataManager, beaconConfig, batchSignatureVerifier)
-	proposerSlashingService := services.NewProposerSlashingService(pool, syncedDataManager, beaconConfig, ethClock, emitters)
+	proposerSlashingService := services.NewProposerSlashingService(pool.ProposerSlashingsPool, syncedDataManager, beaconConfig, ethClock, emitters, int(config.MaxPendingProposerSlashings), int(config.ProposerSlashingQueueDepth))
 
 	{
 		go batchSignatureVerifier.Start()
+		proposerSlashingService.Start(ctx)
 	}
 
 	// Create the gossip manager
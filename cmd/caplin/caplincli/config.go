@@ -54,6 +54,9 @@ type CaplinCliCfg struct {
 	MaxPeerCount          uint64        `json:"max_peer_count"`
 	JwtSecret             []byte
 
+	MaxPendingProposerSlashings uint64 `json:"max_pending_proposer_slashings"`
+	ProposerSlashingQueueDepth  uint64 `json:"proposer_slashing_queue_depth"`
+
 	AllowedMethods   []string `json:"allowed_methods"`
 	AllowedOrigins   []string `json:"allowed_origins"`
 	AllowCredentials bool     `json:"allow_credentials"`
@@ -75,6 +78,8 @@ func SetupCaplinCli(ctx *cli.Context) (cfg *CaplinCliCfg, err error) {
 	cfg.BeaconApiReadTimeout = time.Duration(ctx.Uint64(caplinflags.BeaconApiReadTimeout.Name)) * time.Second
 	cfg.BeaconApiWriteTimeout = time.Duration(ctx.Uint(caplinflags.BeaconApiWriteTimeout.Name)) * time.Second
 	cfg.MaxPeerCount = ctx.Uint64(utils.CaplinMaxPeerCount.Name)
+	cfg.MaxPendingProposerSlashings = ctx.Uint64(utils.CaplinMaxPendingProposerSlashingsFlag.Name)
+	cfg.ProposerSlashingQueueDepth = ctx.Uint64(utils.CaplinProposerSlashingQueueDepthFlag.Name)
 	cfg.BeaconAddr = fmt.Sprintf("%s:%d", ctx.String(caplinflags.BeaconApiAddr.Name), ctx.Int(caplinflags.BeaconApiPort.Name))
 	cfg.AllowCredentials = ctx.Bool(utils.BeaconApiAllowCredentialsFlag.Name)
 	cfg.AllowedMethods = ctx.StringSlice(utils.BeaconApiAllowMethodsFlag.Name)
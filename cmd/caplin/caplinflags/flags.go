@@ -42,6 +42,8 @@ var CliFlags = []cli.Flag{
 	&utils.BeaconApiAllowOriginsFlag,
 	&utils.CaplinCheckpointSyncUrlFlag,
 	&utils.CaplinMaxPeerCount,
+	&utils.CaplinMaxPendingProposerSlashingsFlag,
+	&utils.CaplinProposerSlashingQueueDepthFlag,
 }
 
 var (
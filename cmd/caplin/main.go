@@ -117,5 +117,8 @@ func runCaplinNode(cliCtx *cli.Context) error {
 		MaxPeerCount:              cfg.MaxPeerCount,
 		MaxInboundTrafficPerPeer:  datasize.MB,
 		MaxOutboundTrafficPerPeer: datasize.MB,
+
+		MaxPendingProposerSlashings: cfg.MaxPendingProposerSlashings,
+		ProposerSlashingQueueDepth:  cfg.ProposerSlashingQueueDepth,
 	}, cfg.Dirs, nil, nil, nil, blockSnapBuildSema)
 }
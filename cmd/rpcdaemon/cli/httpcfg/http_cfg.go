@@ -22,6 +22,7 @@ import (
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/eth/gasprice/gaspricecfg"
 	"github.com/erigontech/erigon/rpc/rpccfg"
 	"github.com/erigontech/erigon/rpc/rpchelper"
 )
@@ -98,10 +99,13 @@ type HttpCfg struct {
 	LogDirVerbosity string
 	LogDirPath      string
 
-	BatchLimit                  int  // Maximum number of requests in a batch
-	ReturnDataLimit             int  // Maximum number of bytes returned from calls (like eth_call)
-	AllowUnprotectedTxs         bool // Whether to allow non EIP-155 protected transactions  txs over RPC
-	MaxGetProofRewindBlockCount int  //Max GetProof rewind block count
+	BatchLimit                  int                 // Maximum number of requests in a batch
+	ReturnDataLimit             int                 // Maximum number of bytes returned from calls (like eth_call)
+	AllowUnprotectedTxs         bool                // Whether to allow non EIP-155 protected transactions  txs over RPC
+	MaxGetProofRewindBlockCount int                 //Max GetProof rewind block count
+	CallManyMaxBundles          int                 // Maximum number of bundles in an eth_callMany request
+	CallManyMaxTxPerBundle      int                 // Maximum number of transactions in an eth_callMany bundle
+	GPO                         *gaspricecfg.Config // Settings of the gas price oracle, ethconfig.Defaults.GPO if nil
 	// Ots API
 	OtsMaxPageSize uint64
 
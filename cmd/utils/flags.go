@@ -389,6 +389,16 @@ var (
 		Usage: "Maximum number of bytes returned from eth_call or similar invocations",
 		Value: 100_000,
 	}
+	RpcCallManyMaxBundlesFlag = cli.IntFlag{
+		Name:  "rpc.callmany.maxbundles",
+		Usage: "Maximum number of bundles in an eth_callMany request",
+		Value: 100,
+	}
+	RpcCallManyMaxTxPerBundleFlag = cli.IntFlag{
+		Name:  "rpc.callmany.maxtxperbundle",
+		Usage: "Maximum number of transactions in a bundle of an eth_callMany request",
+		Value: 1000,
+	}
 	HTTPTraceFlag = cli.BoolFlag{
 		Name:  "http.trace",
 		Usage: "Print all HTTP requests to logs with INFO level",
@@ -619,6 +629,24 @@ var (
 		Usage: "Maximum gas price will be recommended by gpo",
 		Value: ethconfig.Defaults.GPO.MaxPrice.Int64(),
 	}
+	GpoMinSuggestedTipFlag = cli.Int64Flag{
+		Name:  "gpo.mintip",
+		Usage: "Minimum tip will be recommended by gpo, in wei (0 means no minimum)",
+	}
+	GpoMaxSuggestedPriceFlag = cli.Int64Flag{
+		Name:  "gpo.maxsuggestedprice",
+		Usage: "Maximum tip will be recommended by gpo, in wei (0 means no maximum other than --gpo.maxprice)",
+	}
+	GpoBlobFeeHeadroomFlag = cli.IntFlag{
+		Name:  "gpo.blobfeeheadroom",
+		Usage: "Percentage added on top of the recent blob base fees when suggesting a max fee per blob gas",
+		Value: gaspricecfg.DefaultBlobFeeHeadroom,
+	}
+	GpoIgnoreTxTypesFlag = cli.StringFlag{
+		Name:  "gpo.ignoretxtypes",
+		Usage: "Comma separated list of transaction types left out of the gas price samples, e.g. \"0x7e\"",
+		Value: "",
+	}
 
 	// Metrics flags
 	MetricsEnabledFlag = cli.BoolFlag{
@@ -879,6 +907,16 @@ var (
 		Usage: "Max number of peers to connect",
 		Value: 128,
 	}
+	CaplinMaxPendingProposerSlashingsFlag = cli.Uint64Flag{
+		Name:  "caplin.max-pending-proposer-slashings",
+		Usage: "Max number of verified proposer slashings waiting for inclusion in a block",
+		Value: 256,
+	}
+	CaplinProposerSlashingQueueDepthFlag = cli.Uint64Flag{
+		Name:  "caplin.proposer-slashing-queue-depth",
+		Usage: "Max number of proposer slashings queued or being processed, the ones over it are ignored",
+		Value: 1024,
+	}
 	CaplinUseEngineApiFlag = cli.BoolFlag{
 		Name:  "caplin.use-engine-api",
 		Usage: "Use engine API for internal Caplin. useful for testing and if CL network is degraded",
@@ -1532,6 +1570,24 @@ func setGPO(ctx *cli.Context, cfg *gaspricecfg.Config) {
 	if ctx.IsSet(GpoMaxGasPriceFlag.Name) {
 		cfg.MaxPrice = big.NewInt(ctx.Int64(GpoMaxGasPriceFlag.Name))
 	}
+	if ctx.IsSet(GpoMinSuggestedTipFlag.Name) {
+		cfg.MinSuggestedTip = big.NewInt(ctx.Int64(GpoMinSuggestedTipFlag.Name))
+	}
+	if v := ctx.Int64(GpoMaxSuggestedPriceFlag.Name); v > 0 {
+		cfg.MaxSuggestedPrice = big.NewInt(v)
+	}
+	if ctx.IsSet(GpoBlobFeeHeadroomFlag.Name) {
+		cfg.BlobFeeHeadroom = ctx.Int(GpoBlobFeeHeadroomFlag.Name)
+	}
+	if ctx.IsSet(GpoIgnoreTxTypesFlag.Name) {
+		for _, s := range common.CliString2Array(ctx.String(GpoIgnoreTxTypesFlag.Name)) {
+			txType, err := strconv.ParseUint(s, 0, 8)
+			if err != nil {
+				Fatalf("Option %s: %v", GpoIgnoreTxTypesFlag.Name, err)
+			}
+			cfg.IgnoreTxTypes = append(cfg.IgnoreTxTypes, byte(txType))
+		}
+	}
 }
 
 // nolint
@@ -1939,6 +1995,8 @@ func SetEthConfig(ctx *cli.Context, nodeConfig *nodecfg.Config, cfg *ethconfig.C
 
 	cfg.CaplinConfig.SubscribeAllTopics = ctx.Bool(CaplinSubscribeAllTopicsFlag.Name)
 	cfg.CaplinConfig.MaxPeerCount = ctx.Uint64(CaplinMaxPeerCount.Name)
+	cfg.CaplinConfig.MaxPendingProposerSlashings = ctx.Uint64(CaplinMaxPendingProposerSlashingsFlag.Name)
+	cfg.CaplinConfig.ProposerSlashingQueueDepth = ctx.Uint64(CaplinProposerSlashingQueueDepthFlag.Name)
 
 	cfg.CaplinConfig.SentinelAddr = ctx.String(SentinelAddrFlag.Name)
 	cfg.CaplinConfig.SentinelPort = ctx.Uint64(SentinelPortFlag.Name)
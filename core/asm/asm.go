@@ -137,3 +137,39 @@ func Disassemble(script []byte) ([]string, error) {
 	}
 	return instrs, nil
 }
+
+// DisassembleJumpdests returns all disassembled EVM instructions like Disassemble,
+// annotating each JUMPDEST with whether it is a valid jump destination. The PUSH
+// instructions whose data holds JUMPDEST bytes, which are not valid destinations,
+// are annotated with their positions.
+func DisassembleJumpdests(script []byte) ([]string, error) {
+	instrs := make([]string, 0)
+	analysis := vm.AnalyzeJumpdests(script)
+
+	it := NewInstructionIterator(script)
+	for it.Next() {
+		var instr string
+		if it.Arg() != nil && 0 < len(it.Arg()) {
+			instr = fmt.Sprintf("%05x: %v 0x%x", it.PC(), it.Op(), it.Arg())
+			for i, b := range it.Arg() {
+				if vm.OpCode(b) == vm.JUMPDEST {
+					instr += fmt.Sprintf(" ; invalid JUMPDEST at %05x", it.PC()+1+uint64(i))
+				}
+			}
+		} else {
+			instr = fmt.Sprintf("%05x: %v", it.PC(), it.Op())
+			if it.Op() == vm.JUMPDEST {
+				if analysis.ValidJumpdest(it.PC()) {
+					instr += " ; valid"
+				} else {
+					instr += " ; invalid"
+				}
+			}
+		}
+		instrs = append(instrs, instr+"\n")
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return instrs, nil
+}
@@ -21,6 +21,7 @@ package asm
 
 import (
 	"encoding/hex"
+	"reflect"
 	"testing"
 )
 
@@ -77,3 +78,24 @@ func TestInstructionIteratorEmpty(t *testing.T) {
 		t.Errorf("Expected 0, but got %v instead.", cnt)
 	}
 }
+
+// Tests annotating the JUMPDESTs of evm code
+func TestDisassembleJumpdests(t *testing.T) {
+	t.Parallel()
+	// PUSH2 0x5b5b, JUMPDEST, PUSH1 0x03, JUMP
+	script, _ := hex.DecodeString("615b5b5b600356")
+
+	instrs, err := DisassembleJumpdests(script)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v instead.", err)
+	}
+	expected := []string{
+		"00000: PUSH2 0x5b5b ; invalid JUMPDEST at 00001 ; invalid JUMPDEST at 00002\n",
+		"00003: JUMPDEST ; valid\n",
+		"00004: PUSH1 0x03\n",
+		"00006: JUMP\n",
+	}
+	if !reflect.DeepEqual(instrs, expected) {
+		t.Errorf("Expected %q, but got %q instead.", expected, instrs)
+	}
+}
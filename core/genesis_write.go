@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"math/big"
 	"slices"
+	"time"
 
 	"github.com/c2h5oh/datasize"
 	"github.com/holiman/uint256"
@@ -421,6 +422,14 @@ func DeveloperGenesisBlock(period uint64, faucet common.Address) *types.Genesis
 	}
 }
 
+const (
+	// genesisLogInterval is how often the progress of writing a big alloc is reported.
+	genesisLogInterval = 20 * time.Second
+	// genesisLogMinAccounts is the alloc size from which the stages of the genesis
+	// computation are logged, small (e.g. test) genesis stay quiet.
+	genesisLogMinAccounts = 100_000
+)
+
 // GenesisToBlock creates the genesis block and writes state of a genesis specification
 // to the given database (or discards it if nil).
 func GenesisToBlock(g *types.Genesis, dirs datadir.Dirs, logger log.Logger) (*types.Block, *state.IntraBlockState, error) {
@@ -496,7 +505,16 @@ func GenesisToBlock(g *types.Genesis, dirs datadir.Dirs, logger log.Logger) (*ty
 		}
 
 		keys := sortedAllocKeys(g.Alloc)
-		for _, key := range keys {
+		logEvery := time.NewTicker(genesisLogInterval)
+		defer logEvery.Stop()
+		start := time.Now()
+		for i, key := range keys {
+			select {
+			case <-logEvery.C:
+				rate := float64(i) / time.Since(start).Seconds()
+				logger.Info("[genesis] Writing alloc", "accounts", fmt.Sprintf("%s/%s", common.PrettyCounter(i), common.PrettyCounter(len(keys))), "accounts/s", fmt.Sprintf("%.0f", rate))
+			default:
+			}
 			addr := common.BytesToAddress([]byte(key))
 			account := g.Alloc[addr]
 
@@ -527,6 +545,9 @@ func GenesisToBlock(g *types.Genesis, dirs datadir.Dirs, logger log.Logger) (*ty
 			return err
 		}
 
+		if len(keys) >= genesisLogMinAccounts {
+			logger.Info("[genesis] Computing state root", "accounts", common.PrettyCounter(len(keys)), "took", time.Since(start))
+		}
 		rh, err := sd.ComputeCommitment(context.Background(), true, blockNum, txNum, "genesis")
 		if err != nil {
 			return err
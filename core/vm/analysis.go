@@ -19,28 +19,131 @@
 
 package vm
 
+import (
+	"runtime"
+	"sync"
+)
+
+// codeBitmapParallelThreshold is the code size from which codeBitmap splits
+// the scan between goroutines.
+const codeBitmapParallelThreshold = 128 * 1024
+
+// JumpdestAnalysis tells the valid jump destinations of a piece of code, see
+// AnalyzeJumpdests.
+type JumpdestAnalysis struct {
+	code []byte
+	bits bitvec
+}
+
+// AnalyzeJumpdests runs the jump destination analysis of code, the one the
+// interpreter runs before jumping.
+func AnalyzeJumpdests(code []byte) *JumpdestAnalysis {
+	return &JumpdestAnalysis{code: code, bits: codeBitmap(code)}
+}
+
+// ValidJumpdest reports whether pc is a valid jump destination, that is a JUMPDEST
+// opcode which is not part of PUSH data.
+func (a *JumpdestAnalysis) ValidJumpdest(pc uint64) bool {
+	return pc < uint64(len(a.code)) && OpCode(a.code[pc]) == JUMPDEST && a.bits.codeSegment(pc)
+}
+
 // codeBitmap collects data locations in code.
 func codeBitmap(code []byte) bitvec {
+	if workers := runtime.GOMAXPROCS(0); workers > 1 && len(code) >= codeBitmapParallelThreshold {
+		return codeBitmapParallel(code, workers)
+	}
 	// The bitmap is 4 bytes longer than necessary, in case the code
 	// ends with a PUSH32, the algorithm will push zeroes onto the
 	// bitvector outside the bounds of the actual code.
 	bits := make(bitvec, (len(code)+32+63)/64)
-	for pc := uint64(0); pc < uint64(len(code)); {
+	scanCode(code, bits, 0, 0, uint64(len(code)))
+	return bits
+}
+
+// scanCode marks the PUSH data of the instructions starting in code[from:to].
+// Bit 0 of bits corresponds to code[base]. It returns the position of the
+// first instruction at or after to.
+func scanCode(code []byte, bits bitvec, base, from, to uint64) uint64 {
+	pc := from
+	for pc < to {
 		op := OpCode(code[pc])
 		pc++
 		if int8(op) < int8(PUSH1) { // If not PUSH (the int8(op) > int(PUSH32) is always false).
 			continue
 		}
 		if op == PUSH1 {
-			bits.set1(pc)
+			bits.set1(pc - base)
 			pc += 1
 			continue
 		}
 
 		numbits := uint64(op - PUSH1 + 1)
-		bits.setN(uint64(1)<<numbits-1, pc)
+		bits.setN(uint64(1)<<numbits-1, pc-base)
 		pc += numbits
 	}
+	return pc
+}
+
+// codeBitmapParallel is codeBitmap for large code. Every chunk is scanned
+// speculatively, assuming an instruction starts at its first byte. Chunks are
+// then stitched in order: when PUSH data of the previous chunk straddles into
+// the current one, the overlap is rescanned from the real instruction boundary
+// until it lines up with the speculative scan again.
+func codeBitmapParallel(code []byte, workers int) bitvec {
+	size := uint64(len(code))
+	// Chunks are 64-byte aligned, so that every chunk maps onto whole words.
+	chunk := (size/uint64(workers) + 63) &^ 63
+	n := int((size + chunk - 1) / chunk)
+
+	locals := make([]bitvec, n)
+	ends := make([]uint64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		from, to := uint64(i)*chunk, min(uint64(i+1)*chunk, size)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			locals[i] = make(bitvec, (to-from+32+63)/64)
+			ends[i] = scanCode(code, locals[i], from, from, to)
+		}()
+	}
+	wg.Wait()
+
+	bits := make(bitvec, (size+32+63)/64)
+	pc := uint64(0)
+	for i, local := range locals {
+		from, to := uint64(i)*chunk, min(uint64(i+1)*chunk, size)
+		if pc > from {
+			// Walk the real instructions until one of them is also an instruction
+			// in the speculative scan, from there on both scans agree.
+			resync := pc
+			for resync < to && !local.codeSegment(resync-from) {
+				if op := OpCode(code[resync]); op >= PUSH1 && op <= PUSH32 {
+					resync += uint64(op - PUSH1 + 1)
+				}
+				resync++
+			}
+			end := resync
+			if resync >= to {
+				end = max(resync, ends[i])
+				ends[i] = resync
+			}
+			for p := from; p < end; p++ {
+				local.clear1(p - from)
+			}
+			// setN overwrites the word following the PUSH data, so rescan into
+			// a scratch bitmap rather than on top of the speculative one.
+			fixed := make(bitvec, min(len(local), int(resync-from+32+63)/64))
+			scanCode(code, fixed, from, pc, resync)
+			for j, w := range fixed {
+				local[j] |= w
+			}
+		}
+		for j, w := range local {
+			bits[from/64+uint64(j)] |= w
+		}
+		pc = ends[i]
+	}
 	return bits
 }
 
@@ -53,6 +156,10 @@ func (bits bitvec) set1(pos uint64) {
 	bits[pos/64] |= 1 << (pos % 64)
 }
 
+func (bits bitvec) clear1(pos uint64) {
+	bits[pos/64] &^= 1 << (pos % 64)
+}
+
 func (bits bitvec) setN(flag uint64, pc uint64) {
 	shift := pc % 64
 	bits[pc/64] |= flag << shift
@@ -0,0 +1,189 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import "encoding/binary"
+
+// EOF (EIP-3540) container layout.
+const (
+	eofFormatByte = 0xef
+	eofMagicByte  = 0x00
+	eof1Version   = 0x01
+
+	eofKindTypes     = 0x01
+	eofKindCode      = 0x02
+	eofKindContainer = 0x03
+	eofKindData      = 0xff
+)
+
+// EOF opcodes which carry immediate arguments (EIP-4200, EIP-4750, EIP-6206,
+// EIP-663, EIP-7480, EIP-7620).
+const (
+	eofDATALOADN      OpCode = 0xd1
+	eofRJUMP          OpCode = 0xe0
+	eofRJUMPI         OpCode = 0xe1
+	eofRJUMPV         OpCode = 0xe2
+	eofCALLF          OpCode = 0xe3
+	eofJUMPF          OpCode = 0xe5
+	eofDUPN           OpCode = 0xe6
+	eofSWAPN          OpCode = 0xe7
+	eofEXCHANGE       OpCode = 0xe8
+	eofEOFCREATE      OpCode = 0xec
+	eofRETURNCONTRACT OpCode = 0xee
+)
+
+// eofImmediates is the number of immediate bytes following an opcode in EOF
+// code. RJUMPV is variable-sized, the table holds its fixed part only.
+var eofImmediates = func() (imm [256]uint8) {
+	for op := PUSH1; op <= PUSH32; op++ {
+		imm[op] = uint8(op - PUSH1 + 1)
+	}
+	imm[eofDATALOADN] = 2
+	imm[eofRJUMP] = 2
+	imm[eofRJUMPI] = 2
+	imm[eofRJUMPV] = 1
+	imm[eofCALLF] = 2
+	imm[eofJUMPF] = 2
+	imm[eofDUPN] = 1
+	imm[eofSWAPN] = 1
+	imm[eofEXCHANGE] = 1
+	imm[eofEOFCREATE] = 1
+	imm[eofRETURNCONTRACT] = 1
+	return imm
+}()
+
+// isEOFContainer reports whether code starts with the EOF magic.
+func isEOFContainer(code []byte) bool {
+	return len(code) >= 2 && code[0] == eofFormatByte && code[1] == eofMagicByte
+}
+
+// eofCodeSections parses the header of an EOF container and returns the
+// [start, end) offsets of its code sections. ok is false if the header is
+// malformed or the sections don't fit into the container.
+func eofCodeSections(code []byte) (sections [][2]int, ok bool) {
+	if !isEOFContainer(code) || len(code) < 3 || code[2] != eof1Version {
+		return nil, false
+	}
+	pos := 3
+	readKind := func(kind byte) bool {
+		if pos >= len(code) || code[pos] != kind {
+			return false
+		}
+		pos++
+		return true
+	}
+	readUint16 := func() (int, bool) {
+		if pos+2 > len(code) {
+			return 0, false
+		}
+		v := int(binary.BigEndian.Uint16(code[pos:]))
+		pos += 2
+		return v, true
+	}
+
+	if !readKind(eofKindTypes) {
+		return nil, false
+	}
+	typesSize, ok := readUint16()
+	if !ok {
+		return nil, false
+	}
+	if !readKind(eofKindCode) {
+		return nil, false
+	}
+	numCode, ok := readUint16()
+	if !ok || numCode == 0 || typesSize != numCode*4 {
+		return nil, false
+	}
+	codeSizes := make([]int, numCode)
+	for i := range codeSizes {
+		if codeSizes[i], ok = readUint16(); !ok || codeSizes[i] == 0 {
+			return nil, false
+		}
+	}
+	containersSize := 0
+	if readKind(eofKindContainer) {
+		numContainers, ok := readUint16()
+		if !ok || numContainers == 0 {
+			return nil, false
+		}
+		for i := 0; i < numContainers; i++ {
+			size, ok := readUint16()
+			if !ok || size == 0 {
+				return nil, false
+			}
+			containersSize += size
+		}
+	}
+	if !readKind(eofKindData) {
+		return nil, false
+	}
+	if _, ok = readUint16(); !ok {
+		return nil, false
+	}
+	if !readKind(0x00) { // header terminator
+		return nil, false
+	}
+
+	start := pos + typesSize
+	sections = make([][2]int, numCode)
+	for i, size := range codeSizes {
+		sections[i] = [2]int{start, start + size}
+		start += size
+	}
+	// The data section may be truncated in initcode, but code sections and
+	// subcontainers must be complete.
+	if start+containersSize > len(code) {
+		return nil, false
+	}
+	return sections, true
+}
+
+// eofAwareCodeBitmap is the analysis the interpreter runs once EOF is active:
+// well-formed EOF containers get the EOF analysis, any other code the legacy
+// one. Before EOF, code starting with the magic is legacy code, so codeBitmap
+// must be used instead.
+func eofAwareCodeBitmap(code []byte) bitvec {
+	if sections, ok := eofCodeSections(code); ok {
+		return eofCodeBitmap(code, sections)
+	}
+	return codeBitmap(code)
+}
+
+// eofCodeBitmap is codeBitmap for an EOF container. Only instruction starts
+// within code sections are marked as code, everything else (header, types,
+// subcontainers, data and all immediates) is data.
+func eofCodeBitmap(code []byte, sections [][2]int) bitvec {
+	bits := make(bitvec, (len(code)+32+63)/64)
+	for i := range bits {
+		bits[i] = ^uint64(0)
+	}
+	for _, section := range sections {
+		for pc, end := uint64(section[0]), uint64(section[1]); pc < end; {
+			op := OpCode(code[pc])
+			bits.clear1(pc)
+			pc++
+			imm := uint64(eofImmediates[op])
+			if op == eofRJUMPV && pc < end {
+				// max_index followed by max_index+1 relative offsets
+				imm += (uint64(code[pc]) + 1) * 2
+			}
+			pc += imm
+		}
+	}
+	return bits
+}
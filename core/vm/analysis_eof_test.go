@@ -0,0 +1,151 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+// makeEOF builds an EOF container with the given code sections and data.
+func makeEOF(data []byte, sections ...[]byte) []byte {
+	u16 := func(v int) []byte { return binary.BigEndian.AppendUint16(nil, uint16(v)) }
+	c := []byte{eofFormatByte, eofMagicByte, eof1Version, eofKindTypes}
+	c = append(c, u16(len(sections)*4)...)
+	c = append(c, eofKindCode)
+	c = append(c, u16(len(sections))...)
+	for _, s := range sections {
+		c = append(c, u16(len(s))...)
+	}
+	c = append(c, eofKindData)
+	c = append(c, u16(len(data))...)
+	c = append(c, 0x00)
+	for range sections {
+		c = append(c, 0x00, 0x80, 0x00, 0x00) // types: inputs, outputs (non-returning), max stack height
+	}
+	for _, s := range sections {
+		c = append(c, s...)
+	}
+	return append(c, data...)
+}
+
+func TestEOFCodeBitmap(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		op   OpCode
+		imm  []byte
+	}{
+		{"PUSH1", PUSH1, []byte{0x5b}},
+		{"PUSH32", PUSH32, make([]byte, 32)},
+		{"DATALOADN", eofDATALOADN, []byte{0x00, 0x00}},
+		{"RJUMP", eofRJUMP, []byte{0x00, 0x00}},
+		{"RJUMPI", eofRJUMPI, []byte{0x00, 0x00}},
+		{"RJUMPV", eofRJUMPV, []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"CALLF", eofCALLF, []byte{0x00, 0x01}},
+		{"JUMPF", eofJUMPF, []byte{0x00, 0x01}},
+		{"DUPN", eofDUPN, []byte{0x00}},
+		{"SWAPN", eofSWAPN, []byte{0x00}},
+		{"EXCHANGE", eofEXCHANGE, []byte{0x00}},
+		{"EOFCREATE", eofEOFCREATE, []byte{0x00}},
+		{"RETURNCONTRACT", eofRETURNCONTRACT, []byte{0x00}},
+		{"ADD", ADD, nil},
+		{"RETF", 0xe4, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			section := append(append([]byte{byte(test.op)}, test.imm...), byte(STOP))
+			code := makeEOF([]byte{0xaa, 0xbb}, []byte{byte(JUMPDEST), byte(STOP)}, section)
+
+			sections, ok := eofCodeSections(code)
+			if !ok {
+				t.Fatal("failed to parse EOF header")
+			}
+			bits := eofAwareCodeBitmap(code)
+			start := uint64(sections[1][0])
+			if !bits.codeSegment(start) {
+				t.Fatalf("opcode at %d should be code", start)
+			}
+			for i := range test.imm {
+				if pos := start + 1 + uint64(i); bits.codeSegment(pos) {
+					t.Fatalf("immediate at %d should be data", pos)
+				}
+			}
+			if pos := start + 1 + uint64(len(test.imm)); !bits.codeSegment(pos) {
+				t.Fatalf("opcode after immediates at %d should be code", pos)
+			}
+			// Header, types and data are never valid destinations.
+			for pos := uint64(0); pos < uint64(sections[0][0]); pos++ {
+				if bits.codeSegment(pos) {
+					t.Fatalf("header byte at %d should be data", pos)
+				}
+			}
+			for pos := uint64(sections[1][1]); pos < uint64(len(code)); pos++ {
+				if bits.codeSegment(pos) {
+					t.Fatalf("data byte at %d should be data", pos)
+				}
+			}
+		})
+	}
+}
+
+func TestEOFCodeSectionsMalformed(t *testing.T) {
+	t.Parallel()
+	valid := makeEOF(nil, []byte{byte(STOP)})
+	if _, ok := eofCodeSections(valid); !ok {
+		t.Fatal("expected valid container")
+	}
+	for _, code := range [][]byte{
+		{eofFormatByte, eofMagicByte},
+		{eofFormatByte, eofMagicByte, 0x02},
+		valid[:len(valid)-1], // truncated code section
+		append([]byte{eofFormatByte, eofMagicByte, eof1Version, eofKindCode}, valid[4:]...),
+	} {
+		if _, ok := eofCodeSections(code); ok {
+			t.Fatalf("expected malformed container: %x", code)
+		}
+	}
+	// Legacy code which only starts with the magic falls back to the legacy scan.
+	code := []byte{eofFormatByte, eofMagicByte, byte(PUSH1), byte(JUMPDEST)}
+	if bits := eofAwareCodeBitmap(code); !bits.codeSegment(2) || bits.codeSegment(3) {
+		t.Fatal("expected legacy analysis")
+	}
+}
+
+func TestCodeBitmapLegacyEOFPrefix(t *testing.T) {
+	t.Parallel()
+	// A JUMPDEST in the data section is data in EOF, but a valid destination
+	// in legacy code which happens to be a well-formed container.
+	code := makeEOF([]byte{byte(JUMPDEST)}, []byte{byte(STOP)})
+	pos := uint64(len(code) - 1)
+
+	legacy := make(bitvec, (len(code)+32+63)/64)
+	scanCode(code, legacy, 0, 0, uint64(len(code)))
+	if bits := codeBitmap(code); !slices.Equal(bits, legacy) {
+		t.Fatalf("expected the legacy bitmap %x, got %x", legacy, bits)
+	}
+	if eofAwareCodeBitmap(code).codeSegment(pos) {
+		t.Fatalf("data byte at %d should be data", pos)
+	}
+	for _, eof := range []bool{false, true} {
+		contract := &Contract{Code: code, eof: eof}
+		if contract.isCode(pos) == eof {
+			t.Fatalf("eof=%v: unexpected analysis of the byte at %d", eof, pos)
+		}
+	}
+}
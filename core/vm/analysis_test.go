@@ -20,6 +20,11 @@
 package vm
 
 import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"runtime"
+	"slices"
 	"testing"
 
 	"github.com/holiman/uint256"
@@ -57,6 +62,45 @@ func TestJumpDestAnalysis(t *testing.T) {
 	}
 }
 
+func codeBitmapSequential(code []byte) bitvec {
+	bits := make(bitvec, (len(code)+32+63)/64)
+	scanCode(code, bits, 0, 0, uint64(len(code)))
+	return bits
+}
+
+// pushHeavyCode returns random code where roughly half of the bytes are PUSH
+// opcodes, so that PUSH data frequently straddles chunk boundaries.
+func pushHeavyCode(rng *rand.Rand, size int) []byte {
+	code := make([]byte, size)
+	rng.Read(code)
+	for i := range code {
+		if rng.Intn(2) == 0 {
+			code[i] = byte(PUSH1) + byte(rng.Intn(32))
+		}
+	}
+	return code
+}
+
+func TestCodeBitmapParallel(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{64, 1000, 4096, 100_003} {
+		for _, workers := range []int{2, 3, 7, 16} {
+			for i := 0; i < 20; i++ {
+				code := pushHeavyCode(rng, size)
+				if exp, got := codeBitmapSequential(code), codeBitmapParallel(code, workers); !slices.Equal(exp, got) {
+					t.Fatalf("bitmap mismatch: size %d, workers %d", size, workers)
+				}
+			}
+		}
+	}
+	// Every chunk boundary falls into the data of a PUSH32.
+	code := bytes.Repeat([]byte{byte(PUSH32)}, 64*1024)
+	if !slices.Equal(codeBitmapSequential(code), codeBitmapParallel(code, 8)) {
+		t.Fatal("bitmap mismatch for PUSH32 code")
+	}
+}
+
 func BenchmarkJumpdestAnalysisEmpty_1200k(bench *testing.B) {
 	// 1.4 ms
 	code := make([]byte, 1200000)
@@ -76,6 +120,23 @@ func BenchmarkJumpdestAnalysis_1200k(bench *testing.B) {
 	bench.StopTimer()
 }
 
+func BenchmarkJumpdestAnalysisSequential_1200k(bench *testing.B) {
+	code := pushHeavyCode(rand.New(rand.NewSource(1)), 1200000)
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		codeBitmapSequential(code)
+	}
+}
+
+func BenchmarkJumpdestAnalysisParallel_1200k(bench *testing.B) {
+	code := pushHeavyCode(rand.New(rand.NewSource(1)), 1200000)
+	workers := max(runtime.GOMAXPROCS(0), 2)
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		codeBitmapParallel(code, workers)
+	}
+}
+
 func BenchmarkJumpdestHashing_1200k(bench *testing.B) {
 	// 4 ms
 	code := make([]byte, 1200000)
@@ -107,3 +168,52 @@ func BenchmarkJumpDest(b *testing.B) {
 		b.StopTimer()
 	}
 }
+
+func BenchmarkJumpDestCacheClones(b *testing.B) {
+	// EIP-1167 minimal proxy: the same code deployed at many addresses.
+	code := common.Hex2Bytes("363d3d373d3d3d363d73bebebebebebebebebebebebebebebebebebebebe5af43d82803e903d91602b57fd5bf3")
+	hash := crypto.Keccak256Hash(code)
+	dest := new(uint256.Int).SetUint64(43) // JUMPDEST
+
+	contractRef := dummyContractRef{}
+
+	c := NewJumpDestCache(16)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		contract := NewContract(contractRef, common.BigToAddress(new(big.Int).SetInt64(int64(n))), nil, 0, false /* skipAnalysis */, c)
+		contract.Code = code
+		contract.CodeHash = hash
+		if valid, _ := contract.validJumpdest(dest); !valid {
+			b.Fatal("expected valid jumpdest")
+		}
+	}
+	b.StopTimer()
+	hits, misses, _ := c.Stats()
+	if misses != 1 || hits != uint64(b.N-1) {
+		b.Fatalf("expected %d cache hits and 1 miss, got %d hits and %d misses", b.N-1, hits, misses)
+	}
+	b.ReportMetric(float64(hits)/float64(hits+misses), "hit/op")
+}
+
+func TestJumpDestCacheStats(t *testing.T) {
+	t.Parallel()
+	c := NewJumpDestCache(2)
+	code := []byte{byte(JUMPDEST)}
+	for i, hash := range []common.Hash{{1}, {1}, {2}, {3}, {1}} {
+		contract := NewContract(dummyContractRef{}, common.Address{byte(i)}, nil, 0, false /* skipAnalysis */, c)
+		contract.Code = code
+		contract.CodeHash = hash
+		contract.validJumpdest(new(uint256.Int))
+	}
+	hits, misses, evictions := c.Stats()
+	if hits != 1 || misses != 4 || evictions != 2 {
+		t.Fatalf("unexpected stats: hits %d, misses %d, evictions %d", hits, misses, evictions)
+	}
+
+	// removed entries are not evictions
+	c.Remove(common.Hash{1})
+	c.Purge()
+	if _, _, evictions := c.Stats(); evictions != 2 {
+		t.Fatalf("unexpected evictions after removal: %d", evictions)
+	}
+}
@@ -21,11 +21,13 @@ package vm
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/dbg"
 	"github.com/erigontech/erigon-lib/log/v3"
-	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/erigontech/erigon-lib/metrics"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/holiman/uint256"
 
 	"github.com/erigontech/erigon/core/tracing"
@@ -60,6 +62,7 @@ type Contract struct {
 	jumpdests     *JumpDestCache // Aggregated result of JUMPDEST analysis.
 	analysis      bitvec         // Locally cached result of JUMPDEST analysis
 	skipAnalysis  bool
+	eof           bool // EOF is active, EOF containers get the EOF analysis
 
 	Code     []byte
 	CodeHash common.Hash
@@ -70,30 +73,66 @@ type Contract struct {
 	value *uint256.Int
 }
 
+// JumpDestCache holds JUMPDEST analysis results keyed by code hash. It is safe
+// for concurrent use, so a single instance can be shared between EVMs: identical
+// code deployed at many addresses (proxies, clones) is analysed only once.
 type JumpDestCache struct {
-	*simplelru.LRU[common.Hash, bitvec]
-	hit, total int
-	trace      bool
+	*lru.Cache[common.Hash, bitvec]
+	hits, misses, evictions atomic.Uint64
+	trace                   bool
 }
 
 var (
 	JumpDestCacheLimit = dbg.EnvInt("JD_LRU", 128)
 	jumpDestCacheTrace = dbg.EnvBool("JD_LRU_TRACE", false)
+
+	// sharedJumpDestCache is used by EVMs which were not given a cache explicitly.
+	sharedJumpDestCache = NewJumpDestCache(JumpDestCacheLimit)
+
+	mxJumpDestCacheHit  = metrics.GetOrCreateCounter("jumpdest_cache_hit")
+	mxJumpDestCacheMiss = metrics.GetOrCreateCounter("jumpdest_cache_miss")
 )
 
 func NewJumpDestCache(limit int) *JumpDestCache {
-	c, err := simplelru.NewLRU[common.Hash, bitvec](limit, nil)
+	c, err := lru.New[common.Hash, bitvec](limit)
 	if err != nil {
 		panic(err)
 	}
-	return &JumpDestCache{LRU: c, trace: jumpDestCacheTrace}
+	return &JumpDestCache{Cache: c, trace: jumpDestCacheTrace}
+}
+
+// analysis returns the JUMPDEST analysis of code, computing and caching it
+// under codeHash on a miss.
+func (c *JumpDestCache) analysis(codeHash common.Hash, code []byte) bitvec {
+	if analysis, ok := c.Get(codeHash); ok {
+		c.hits.Add(1)
+		mxJumpDestCacheHit.Inc()
+		return analysis
+	}
+	c.misses.Add(1)
+	mxJumpDestCacheMiss.Inc()
+	analysis := codeBitmap(code)
+	// explicit removals are not evictions, only count the ones making room
+	if c.Add(codeHash, analysis) {
+		c.evictions.Add(1)
+	}
+	return analysis
+}
+
+// Stats returns the number of analysis lookups served from the cache, the
+// number of lookups which had to analyse the code, and the number of entries
+// evicted to make room for new ones.
+func (c *JumpDestCache) Stats() (hits, misses, evictions uint64) {
+	return c.hits.Load(), c.misses.Load(), c.evictions.Load()
 }
 
 func (c *JumpDestCache) LogStats() {
 	if c == nil || !c.trace {
 		return
 	}
-	log.Warn("[dbg] JumpDestCache", "hit", c.hit, "total", c.total, "limit", JumpDestCacheLimit, "ratio", fmt.Sprintf("%.2f", float64(c.hit)/float64(c.total)))
+	hits, misses, evictions := c.Stats()
+	total := hits + misses
+	log.Warn("[dbg] JumpDestCache", "hit", hits, "total", total, "evictions", evictions, "limit", JumpDestCacheLimit, "ratio", fmt.Sprintf("%.2f", float64(hits)/float64(total)))
 }
 
 // NewContract returns a new contract environment for the execution of EVM.
@@ -131,23 +170,25 @@ func (c *Contract) validJumpdest(dest *uint256.Int) (bool, bool) {
 // isCode returns true if the provided PC location is an actual opcode, as
 // opposed to a data-segment following a PUSHN operation.
 func (c *Contract) isCode(udest uint64) bool {
+	// The shared cache is keyed by code hash only and holds legacy analyses,
+	// so the analysis of an EOF container is only kept locally.
+	if c.eof && isEOFContainer(c.Code) {
+		if c.analysis == nil {
+			c.analysis = eofAwareCodeBitmap(c.Code)
+		}
+		return c.analysis.codeSegment(udest)
+	}
+
 	// Do we have a contract hash already?
 	// If we do have a hash, that means it's a 'regular' contract. For regular
 	// contracts ( not temporary initcode), we store the analysis in a map
 	if c.CodeHash != (common.Hash{}) {
-		// Does parent context have the analysis?
-		c.jumpdests.total++
-		analysis, exist := c.jumpdests.Get(c.CodeHash)
-		if !exist {
-			// Do the analysis and save in parent context
-			// We do not need to store it in c.analysis
-			analysis = codeBitmap(c.Code)
-			c.jumpdests.Add(c.CodeHash, analysis)
-		} else {
-			c.jumpdests.hit++
+		// Does parent context have the analysis? The cache is keyed by code
+		// hash only, so clones of the same code at other addresses share it.
+		if c.analysis == nil {
+			// Also stash it in current contract for faster access
+			c.analysis = c.jumpdests.analysis(c.CodeHash, c.Code)
 		}
-		// Also stash it in current contract for faster access
-		c.analysis = analysis
 		return c.analysis.codeSegment(udest)
 	}
 
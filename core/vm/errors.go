@@ -46,6 +46,7 @@ var (
 	ErrReturnStackExceeded      = errors.New("return stack limit reached")
 	ErrInvalidCode              = errors.New("invalid code")
 	ErrNonceUintOverflow        = errors.New("nonce uint64 overflow")
+	ErrMaxStepsExceeded         = errors.New("max steps exceeded")
 
 	// errStopToken is an internal token indicating interpreter loop termination,
 	// never returned to outside callers.
@@ -160,6 +161,7 @@ const (
 	VMErrorInvalidSubroutineEntry
 	VMErrorInvalidRetsub
 	VMErrorReturnStackExceeded
+	VMErrorCodeMaxStepsExceeded
 
 	// VMErrorCodeUnknown explicitly marks an error as unknown, this is useful when error is converted
 	// from an actual `error` in which case if the mapping is not known, we can use this value to indicate that.
@@ -200,6 +202,8 @@ func vmErrorCodeFromErr(err error) int {
 		return VMErrorInvalidRetsub
 	case errors.Is(err, ErrReturnStackExceeded):
 		return VMErrorReturnStackExceeded
+	case errors.Is(err, ErrMaxStepsExceeded):
+		return VMErrorCodeMaxStepsExceeded
 
 	default:
 		// Dynamic errors
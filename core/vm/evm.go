@@ -117,7 +117,7 @@ func NewEVM(blockCtx evmtypes.BlockContext, txCtx evmtypes.TxContext, ibs *state
 		chainRules:      chainConfig.Rules(blockCtx.BlockNumber, blockCtx.Time),
 	}
 	if evm.config.JumpDestCache == nil {
-		evm.config.JumpDestCache = NewJumpDestCache(JumpDestCacheLimit)
+		evm.config.JumpDestCache = sharedJumpDestCache
 	}
 
 	evm.interpreter = NewEVMInterpreter(evm, vmConfig)
@@ -47,6 +47,9 @@ type Config struct {
 	ReadOnly      bool // Do no perform any block finalisation
 	StatelessExec bool // true is certain conditions (like state trie root hash matching) need to be relaxed for stateless EVM execution
 	RestoreState  bool // Revert all changes made to the state (useful for constant system calls)
+	// MaxSteps, if not zero, aborts a call with ErrMaxStepsExceeded once it and its
+	// sub-calls executed that many opcodes, whatever the gas left (useful for fuzzing)
+	MaxSteps uint64
 
 	ExtraEips []int // Additional EIPS that are to be enabled
 
@@ -137,6 +140,7 @@ type EVMInterpreter struct {
 	*VM
 	jt    *JumpTable // EVM instruction table
 	depth int
+	steps uint64 // opcodes executed since the outermost call, for Config.MaxSteps
 }
 
 // structcheck doesn't see embedding
@@ -258,6 +262,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	)
 
 	contract.Input = input
+	contract.eof = in.evm.chainRules.IsOsaka
 
 	// Make sure the readOnly is only set if we aren't in readOnly yet.
 	// This makes also sure that the readOnly flag isn't removed for child calls.
@@ -265,6 +270,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	if restoreReadonly {
 		in.readOnly = true
 	}
+	// The step budget is shared by the outermost call and its sub-calls
+	if in.depth == 0 {
+		in.steps = 0
+	}
 	// Increment the call depth which is restricted to 1024
 	in.depth++
 	defer func() {
@@ -297,6 +306,12 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		if steps%5000 == 0 && in.evm.Cancelled() {
 			break
 		}
+		if in.cfg.MaxSteps != 0 {
+			if in.steps == in.cfg.MaxSteps {
+				return nil, ErrMaxStepsExceeded
+			}
+			in.steps++
+		}
 		if debug {
 			// Capture pre-execution values for tracing.
 			logged, pcCopy, gasCopy = false, _pc, contract.Gas
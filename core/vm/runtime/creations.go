@@ -0,0 +1,88 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/core/tracing"
+	"github.com/erigontech/erigon/core/vm"
+)
+
+// Creation is a contract deployed by a CREATE or CREATE2 during a call.
+type Creation struct {
+	Address common.Address
+	Code    []byte // runtime code of the contract
+}
+
+// CreationCollector records the contracts deployed by the EVM it traces, see Hooks.
+// Contracts deployed by frames that are eventually reverted are not recorded.
+type CreationCollector struct {
+	// frames holds the call frames being executed, with the contracts deployed
+	// during each one so far
+	frames    []creationFrame
+	creations []Creation
+}
+
+type creationFrame struct {
+	create    *Creation // the contract deployed by the frame, if a CREATE or CREATE2
+	creations []Creation
+}
+
+// Hooks returns the tracing hooks recording the deployed contracts, to be set as
+// the tracer of the EVM config.
+func (c *CreationCollector) Hooks() *tracing.Hooks {
+	return &tracing.Hooks{
+		OnEnter: c.onEnter,
+		OnExit:  c.onExit,
+	}
+}
+
+// Creations returns the recorded contracts, in the order their deployment ended.
+func (c *CreationCollector) Creations() []Creation {
+	return c.creations
+}
+
+func (c *CreationCollector) onEnter(depth int, typ byte, from common.Address, to common.Address, precompile bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+	var frame creationFrame
+	if op := vm.OpCode(typ); op == vm.CREATE || op == vm.CREATE2 {
+		frame.create = &Creation{Address: to}
+	}
+	c.frames = append(c.frames, frame)
+}
+
+func (c *CreationCollector) onExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(c.frames) == 0 {
+		return
+	}
+	frame := c.frames[len(c.frames)-1]
+	c.frames = c.frames[:len(c.frames)-1]
+	if reverted {
+		return
+	}
+	if frame.create != nil {
+		frame.create.Code = common.CopyBytes(output)
+		frame.creations = append(frame.creations, *frame.create)
+	}
+	if len(c.frames) == 0 {
+		c.creations = append(c.creations, frame.creations...)
+		return
+	}
+	parent := &c.frames[len(c.frames)-1]
+	parent.creations = append(parent.creations, frame.creations...)
+}
@@ -20,18 +20,42 @@
 package runtime
 
 import (
+	"fmt"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/core/vm"
 	"github.com/erigontech/erigon/core/vm/evmtypes"
 	"github.com/erigontech/erigon/execution/consensus"
+	"github.com/erigontech/erigon/execution/consensus/merge"
+	"github.com/erigontech/erigon/execution/consensus/misc"
 )
 
-func NewEnv(cfg *Config) *vm.EVM {
+// NewEnv returns the EVM the config describes. It fails if the blob base fee
+// can't be derived from ExcessBlobGas.
+func NewEnv(cfg *Config) (*vm.EVM, error) {
 	txContext := evmtypes.TxContext{
 		Origin:   cfg.Origin,
 		GasPrice: cfg.GasPrice,
 	}
 
+	var prevRandDao *common.Hash
+	if cfg.Random != nil && (cfg.Difficulty == nil || cfg.Difficulty.Cmp(merge.ProofOfStakeDifficulty) == 0) {
+		// EIP-4399, like for the blocks whose difficulty tells they are Proof-of-Stake ones
+		prevRandDao = cfg.Random
+	}
+
+	var blobBaseFee *uint256.Int
+	if cfg.ExcessBlobGas != nil {
+		var err error
+		blobBaseFee, err = misc.GetBlobGasPrice(cfg.ChainConfig, *cfg.ExcessBlobGas, cfg.Time.Uint64())
+		if err != nil {
+			return nil, fmt.Errorf("excess blob gas %d: %w", *cfg.ExcessBlobGas, err)
+		}
+	}
+
 	blockContext := evmtypes.BlockContext{
 		CanTransfer: core.CanTransfer,
 		Transfer:    consensus.Transfer,
@@ -42,7 +66,9 @@ func NewEnv(cfg *Config) *vm.EVM {
 		Difficulty:  cfg.Difficulty,
 		GasLimit:    cfg.GasLimit,
 		BaseFee:     cfg.BaseFee,
+		PrevRanDao:  prevRandDao,
+		BlobBaseFee: blobBaseFee,
 	}
 
-	return vm.NewEVM(blockContext, txContext, cfg.State, cfg.ChainConfig, cfg.EVMConfig)
+	return vm.NewEVM(blockContext, txContext, cfg.State, cfg.ChainConfig, cfg.EVMConfig), nil
 }
@@ -30,6 +30,7 @@ import (
 	"github.com/holiman/uint256"
 
 	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/chain/params"
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/config3"
@@ -49,6 +50,9 @@ import (
 type Config struct {
 	ChainConfig *chain.Config
 	Difficulty  *big.Int
+	// Random is the PREVRANDAO of the block (its mix digest), returned by the
+	// DIFFICULTY opcode of post-Merge blocks, that is when Difficulty is zero
+	Random      *common.Hash
 	Origin      common.Address
 	Coinbase    common.Address
 	BlockNumber *big.Int
@@ -58,6 +62,11 @@ type Config struct {
 	Value       *uint256.Int
 	EVMConfig   vm.Config
 	BaseFee     *uint256.Int
+	// AccessList is warmed before the execution, like the one of an EIP-2930 transaction
+	AccessList types.AccessList
+	// ExcessBlobGas of the block, from which the blob base fee returned by the
+	// BLOBBASEFEE opcode is derived (EIP-4844)
+	ExcessBlobGas *uint64
 
 	State *state.IntraBlockState
 
@@ -114,12 +123,47 @@ func setDefaults(cfg *Config) {
 	}
 }
 
+// Usage is the outcome of an execution along with its gas accounting, see
+// ExecuteWithUsage.
+type Usage struct {
+	Return []byte
+	// GasUsed is the gas spent by the execution once refunded, that is the gas
+	// limit minus GasRefunded and GasLeft.
+	GasUsed uint64
+	// GasRefunded is the gas refunded at the end of the execution, capped to a
+	// fraction of the gas spent (one fifth since EIP-3529, one half before).
+	GasRefunded uint64
+	// GasLeft is the gas left unspent by the execution, before refunds.
+	GasLeft uint64
+	Err     error
+}
+
 // Execute executes the code using the input as call data during the execution.
 // It returns the EVM's return value, the new state and an error if it failed.
 //
 // Execute sets up an in-memory, temporary, environment for the execution of
 // the given code. It makes sure that it's restored to its original state afterwards.
 func Execute(code, input []byte, cfg *Config, tempdir string) ([]byte, *state.IntraBlockState, error) {
+	usage, state, err := execute(code, input, cfg, tempdir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return usage.Return, state, usage.Err
+}
+
+// ExecuteWithUsage executes the code like Execute does, and returns the outcome of
+// the execution along with its gas accounting. Unlike Execute, it sets the defaults
+// of a given config.
+func ExecuteWithUsage(code, input []byte, cfg *Config, tempdir string) (*Usage, *state.IntraBlockState, error) {
+	if cfg != nil {
+		setDefaults(cfg)
+	}
+	return execute(code, input, cfg, tempdir)
+}
+
+// execute runs the code for Execute and ExecuteWithUsage, the error it returns
+// being one of the environment setup, rather than of the execution.
+func execute(code, input []byte, cfg *Config, tempdir string) (*Usage, *state.IntraBlockState, error) {
 	if cfg == nil {
 		cfg = new(Config)
 		setDefaults(cfg)
@@ -157,13 +201,16 @@ func Execute(code, input []byte, cfg *Config, tempdir string) ([]byte, *state.In
 		//cfg.w = state.NewWriter(sd, nil)
 		cfg.State = state.New(state.NewReaderV3(sd.AsGetter(tx)))
 	}
+	vmenv, err := NewEnv(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 	var (
 		address = common.BytesToAddress([]byte("contract"))
-		vmenv   = NewEnv(cfg)
 		sender  = vm.AccountRef(cfg.Origin)
 		rules   = vmenv.ChainRules()
 	)
-	cfg.State.Prepare(rules, cfg.Origin, cfg.Coinbase, &address, vm.ActivePrecompiles(rules), nil, nil)
+	cfg.State.Prepare(rules, cfg.Origin, cfg.Coinbase, &address, vm.ActivePrecompiles(rules), cfg.AccessList, nil)
 	cfg.State.CreateAccount(address, true)
 	// set the receiver's (the executing contract) code for execution.
 	cfg.State.SetCode(address, code)
@@ -171,7 +218,7 @@ func Execute(code, input []byte, cfg *Config, tempdir string) ([]byte, *state.In
 	if cfg.EVMConfig.Tracer != nil && cfg.EVMConfig.Tracer.OnTxStart != nil {
 		cfg.EVMConfig.Tracer.OnTxStart(&tracing.VMContext{IntraBlockState: cfg.State}, nil, common.Address{})
 	}
-	ret, _, err := vmenv.Call(
+	ret, leftOverGas, err := vmenv.Call(
 		sender,
 		common.BytesToAddress([]byte("contract")),
 		input,
@@ -183,7 +230,19 @@ func Execute(code, input []byte, cfg *Config, tempdir string) ([]byte, *state.In
 		cfg.EVMConfig.Tracer.OnTxEnd(nil, err)
 	}
 
-	return ret, cfg.State, err
+	refundQuotient := params.RefundQuotient
+	if rules.IsLondon {
+		refundQuotient = params.RefundQuotientEIP3529
+	}
+	spent := cfg.GasLimit - leftOverGas
+	refund := min(spent/refundQuotient, cfg.State.GetRefund())
+	return &Usage{
+		Return:      ret,
+		GasUsed:     spent - refund,
+		GasRefunded: refund,
+		GasLeft:     leftOverGas,
+		Err:         err,
+	}, cfg.State, nil
 }
 
 // Create executes the code using the EVM create method
@@ -222,12 +281,15 @@ func Create(input []byte, cfg *Config, blockNr uint64) ([]byte, common.Address,
 		//cfg.w = state.NewWriter(sd, nil)
 		cfg.State = state.New(state.NewReaderV3(sd.AsGetter(tx)))
 	}
+	vmenv, err := NewEnv(cfg)
+	if err != nil {
+		return nil, common.Address{}, 0, err
+	}
 	var (
-		vmenv  = NewEnv(cfg)
 		sender = vm.AccountRef(cfg.Origin)
 		rules  = vmenv.ChainRules()
 	)
-	cfg.State.Prepare(rules, cfg.Origin, cfg.Coinbase, nil, vm.ActivePrecompiles(rules), nil, nil)
+	cfg.State.Prepare(rules, cfg.Origin, cfg.Coinbase, nil, vm.ActivePrecompiles(rules), cfg.AccessList, nil)
 
 	// Call the code with the given configuration.
 	code, address, leftOverGas, err := vmenv.Create(
@@ -240,6 +302,41 @@ func Create(input []byte, cfg *Config, blockNr uint64) ([]byte, common.Address,
 	return code, address, leftOverGas, err
 }
 
+// Create2 executes the code using the EVM create2 method, deploying the contract
+// at an address depending only on the origin, the salt and the code, regardless
+// of the origin's nonce (see Create2Address).
+//
+// Create2, like Call, requires the State field of the config to be set.
+func Create2(input []byte, salt *uint256.Int, cfg *Config) ([]byte, common.Address, uint64, error) {
+	setDefaults(cfg)
+
+	vmenv, err := NewEnv(cfg)
+	if err != nil {
+		return nil, common.Address{}, 0, err
+	}
+	var (
+		sender = vm.AccountRef(cfg.Origin)
+		rules  = vmenv.ChainRules()
+	)
+	cfg.State.Prepare(rules, cfg.Origin, cfg.Coinbase, nil, vm.ActivePrecompiles(rules), cfg.AccessList, nil)
+
+	// Call the code with the given configuration.
+	return vmenv.Create2(
+		sender,
+		input,
+		cfg.GasLimit,
+		cfg.Value,
+		salt,
+		false,
+	)
+}
+
+// Create2Address returns the address Create2 deploys the code at, derived like the
+// CREATE2 opcode does: keccak256(0xff ++ origin ++ salt ++ keccak256(code))[12:].
+func Create2Address(origin common.Address, salt *uint256.Int, code []byte) common.Address {
+	return crypto.CreateAddress2(origin, salt.Bytes32(), crypto.Keccak256(code))
+}
+
 // Call executes the code given by the contract's address. It will return the
 // EVM's return value or an error if it failed.
 //
@@ -248,7 +345,10 @@ func Create(input []byte, cfg *Config, blockNr uint64) ([]byte, common.Address,
 func Call(address common.Address, input []byte, cfg *Config) ([]byte, uint64, error) {
 	setDefaults(cfg)
 
-	vmenv := NewEnv(cfg)
+	vmenv, err := NewEnv(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	sender, err := cfg.State.GetOrNewStateObject(cfg.Origin)
 	if err != nil {
@@ -256,7 +356,7 @@ func Call(address common.Address, input []byte, cfg *Config) ([]byte, uint64, er
 	}
 	statedb := cfg.State
 	rules := vmenv.ChainRules()
-	statedb.Prepare(rules, cfg.Origin, cfg.Coinbase, &address, vm.ActivePrecompiles(rules), nil, nil)
+	statedb.Prepare(rules, cfg.Origin, cfg.Coinbase, &address, vm.ActivePrecompiles(rules), cfg.AccessList, nil)
 
 	if cfg.EVMConfig.Tracer != nil && cfg.EVMConfig.Tracer.OnTxStart != nil {
 		cfg.EVMConfig.Tracer.OnTxStart(&tracing.VMContext{IntraBlockState: cfg.State}, nil, common.Address{})
@@ -23,6 +23,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"math/big"
 	"os"
 	"strconv"
@@ -34,6 +35,7 @@ import (
 
 	"github.com/erigontech/erigon-lib/abi"
 	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/chain/params"
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/kv"
@@ -47,6 +49,7 @@ import (
 	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/core/asm"
 	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/tracing"
 	"github.com/erigontech/erigon/core/vm"
 	"github.com/erigontech/erigon/core/vm/program"
 	"github.com/erigontech/erigon/eth/tracers/logger"
@@ -152,6 +155,133 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+func TestExecuteWithUsage(t *testing.T) {
+	t.Parallel()
+	// sets then clears a slot, which refunds more than the EIP-3529 cap
+	code := []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+	}
+	const gasLimit = 100_000
+	usage, _, err := ExecuteWithUsage(code, nil, &Config{GasLimit: gasLimit}, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, usage.Err)
+
+	spent := gasLimit - usage.GasLeft
+	require.Equal(t, uint64(3*4+params.ColdSloadCostEIP2929+params.SstoreSetGasEIP2200+params.WarmStorageReadCostEIP2929), spent)
+	require.Equal(t, spent/params.RefundQuotientEIP3529, usage.GasRefunded)
+	require.Equal(t, spent-usage.GasRefunded, usage.GasUsed)
+	require.Equal(t, uint64(gasLimit), usage.GasUsed+usage.GasRefunded+usage.GasLeft)
+}
+
+func TestExecuteMaxSteps(t *testing.T) {
+	t.Parallel()
+	// an infinite loop, which only stops when out of gas
+	code := []byte{
+		byte(vm.JUMPDEST),
+		byte(vm.PUSH1), 0,
+		byte(vm.JUMP),
+	}
+	var steps uint64
+	tracer := &tracing.Hooks{
+		OnOpcode: func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+			steps++
+		},
+	}
+	usage, _, err := ExecuteWithUsage(code, nil, &Config{
+		GasLimit:  math.MaxUint64,
+		EVMConfig: vm.Config{MaxSteps: 1000, Tracer: tracer},
+	}, t.TempDir())
+	require.NoError(t, err)
+	require.ErrorIs(t, usage.Err, vm.ErrMaxStepsExceeded)
+	require.Equal(t, uint64(1000), steps)
+}
+
+func TestExecuteAccessList(t *testing.T) {
+	t.Parallel()
+	code := []byte{
+		byte(vm.PUSH1), 0,
+		byte(vm.SLOAD),
+	}
+	sload := func(accessList types.AccessList) uint64 {
+		usage, _, err := ExecuteWithUsage(code, nil, &Config{AccessList: accessList}, t.TempDir())
+		require.NoError(t, err)
+		require.NoError(t, usage.Err)
+		return usage.GasUsed - 3 // PUSH1
+	}
+
+	require.Equal(t, params.ColdSloadCostEIP2929, sload(nil))
+	// the executed contract itself is always warm, not its storage
+	contract := common.BytesToAddress([]byte("contract"))
+	require.Equal(t, params.ColdSloadCostEIP2929, sload(types.AccessList{{Address: contract}}))
+	require.Equal(t, params.WarmStorageReadCostEIP2929, sload(types.AccessList{{Address: contract, StorageKeys: []common.Hash{{}}}}))
+}
+
+func TestExecuteRandom(t *testing.T) {
+	t.Parallel()
+	code := []byte{
+		byte(vm.DIFFICULTY),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	random := common.HexToHash("0x0102030405060708091011121314151617181920212223242526272829303132")
+
+	usage, _, err := ExecuteWithUsage(code, nil, &Config{Random: &random}, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, usage.Err)
+	require.Equal(t, random.Bytes(), usage.Return)
+
+	// before the Merge, the opcode returns the difficulty
+	usage, _, err = ExecuteWithUsage(code, nil, &Config{Random: &random, Difficulty: big.NewInt(0x200000)}, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, usage.Err)
+	require.Equal(t, common.BigToHash(big.NewInt(0x200000)).Bytes(), usage.Return)
+}
+
+func TestExecuteExcessBlobGas(t *testing.T) {
+	t.Parallel()
+	code := []byte{
+		byte(vm.BLOBBASEFEE),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	// fake_exponential of EIP-4844
+	fakeExponential := func(factor, numerator, denominator uint64) *big.Int {
+		output := new(big.Int)
+		accum := new(big.Int).Mul(new(big.Int).SetUint64(factor), new(big.Int).SetUint64(denominator))
+		for i := int64(1); accum.Sign() > 0; i++ {
+			output.Add(output, accum)
+			accum.Mul(accum, new(big.Int).SetUint64(numerator))
+			accum.Div(accum, new(big.Int).Mul(new(big.Int).SetUint64(denominator), big.NewInt(i)))
+		}
+		return output.Div(output, new(big.Int).SetUint64(denominator))
+	}
+
+	excessBlobGas := uint64(10_000_000)
+	cfg := &Config{ExcessBlobGas: &excessBlobGas, Time: new(big.Int)}
+	usage, _, err := ExecuteWithUsage(code, nil, cfg, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, usage.Err)
+	expected := fakeExponential(1, excessBlobGas, cfg.ChainConfig.GetBlobGasPriceUpdateFraction(0))
+	require.Equal(t, 1, expected.Cmp(big.NewInt(1)))
+	require.Equal(t, common.BigToHash(expected).Bytes(), usage.Return)
+
+	// a blob base fee overflowing 256 bits is an error rather than a panic
+	excessBlobGas = math.MaxUint64
+	_, _, err = ExecuteWithUsage(code, nil, &Config{ExcessBlobGas: &excessBlobGas, Time: new(big.Int)}, t.TempDir())
+	require.ErrorContains(t, err, "excess blob gas")
+}
+
 func TestCall(t *testing.T) {
 	t.Parallel()
 	_, tx, _ := NewTestTemporalDb(t)
@@ -180,6 +310,133 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestCreate2(t *testing.T) {
+	t.Parallel()
+	var (
+		origin = common.HexToAddress("0xaa")
+		salt   = uint256.NewInt(42)
+		// returns the single byte code 0x2a
+		initCode = []byte{
+			byte(vm.PUSH1), 0x2a,
+			byte(vm.PUSH1), 0,
+			byte(vm.MSTORE8),
+			byte(vm.PUSH1), 1,
+			byte(vm.PUSH1), 0,
+			byte(vm.RETURN),
+		}
+	)
+	deploy := func(nonce uint64) (*state.IntraBlockState, common.Address) {
+		_, tx, _ := NewTestTemporalDb(t)
+		domains, err := stateLib.NewSharedDomains(tx, log.New())
+		require.NoError(t, err)
+		t.Cleanup(domains.Close)
+		statedb := state.New(state.NewReaderV3(domains.AsGetter(tx)))
+		statedb.SetNonce(origin, nonce)
+
+		code, address, _, err := Create2(initCode, salt, &Config{Origin: origin, State: statedb})
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x2a}, code)
+		return statedb, address
+	}
+
+	// the address does not depend on the origin's nonce
+	statedb, address := deploy(0)
+	_, again := deploy(7)
+	require.Equal(t, address, again)
+	require.Equal(t, Create2Address(origin, salt, initCode), address)
+	code, err := statedb.GetCode(address)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x2a}, code)
+
+	// so deploying it twice in the same state collides
+	_, _, _, err = Create2(initCode, salt, &Config{Origin: origin, State: statedb})
+	require.ErrorIs(t, err, vm.ErrContractAddressCollision)
+}
+
+func TestSelfdestructCollector(t *testing.T) {
+	t.Parallel()
+	var (
+		address     = common.HexToAddress("0xaa")
+		beneficiary = common.HexToAddress("0xbb")
+		code        = []byte{
+			byte(vm.PUSH1), 0xbb,
+			byte(vm.SELFDESTRUCT),
+		}
+		shanghai = &chain.Config{
+			ChainID:               big.NewInt(1),
+			HomesteadBlock:        new(big.Int),
+			TangerineWhistleBlock: new(big.Int),
+			SpuriousDragonBlock:   new(big.Int),
+			ByzantiumBlock:        new(big.Int),
+			ConstantinopleBlock:   new(big.Int),
+			PetersburgBlock:       new(big.Int),
+			IstanbulBlock:         new(big.Int),
+			BerlinBlock:           new(big.Int),
+			LondonBlock:           new(big.Int),
+			ShanghaiTime:          new(big.Int),
+		}
+	)
+	newState := func() *state.IntraBlockState {
+		_, tx, _ := NewTestTemporalDb(t)
+		domains, err := stateLib.NewSharedDomains(tx, log.New())
+		require.NoError(t, err)
+		t.Cleanup(domains.Close)
+		return state.New(state.NewReaderV3(domains.AsGetter(tx)))
+	}
+	call := func(chainConfig *chain.Config) []Selfdestruct {
+		statedb := newState()
+		statedb.SetCode(address, code)
+		// the contract is deployed by a previous transaction
+		require.NoError(t, statedb.FinalizeTx(&chain.Rules{}, state.NewNoopWriter()))
+		collector := &SelfdestructCollector{}
+		_, _, err := Call(address, nil, &Config{State: statedb, ChainConfig: chainConfig, EVMConfig: vm.Config{Tracer: collector.Hooks()}})
+		require.NoError(t, err)
+		selfdestructs, err := collector.Selfdestructs(statedb)
+		require.NoError(t, err)
+		return selfdestructs
+	}
+
+	require.Equal(t, []Selfdestruct{{Address: address, Beneficiary: beneficiary, Destroyed: true}}, call(shanghai))
+	// since EIP-6780, the account persists unless created in the same transaction
+	require.Equal(t, []Selfdestruct{{Address: address, Beneficiary: beneficiary, Destroyed: false}}, call(nil))
+
+	statedb := newState()
+	collector := &SelfdestructCollector{}
+	_, created, _, err := Create(code, &Config{State: statedb, EVMConfig: vm.Config{Tracer: collector.Hooks()}}, 0)
+	require.NoError(t, err)
+	selfdestructs, err := collector.Selfdestructs(statedb)
+	require.NoError(t, err)
+	require.Equal(t, []Selfdestruct{{Address: created, Beneficiary: beneficiary, Destroyed: true}}, selfdestructs)
+}
+
+func TestCreationCollector(t *testing.T) {
+	t.Parallel()
+	_, tx, _ := NewTestTemporalDb(t)
+	domains, err := stateLib.NewSharedDomains(tx, log.New())
+	require.NoError(t, err)
+	defer domains.Close()
+	statedb := state.New(state.NewReaderV3(domains.AsGetter(tx)))
+
+	var (
+		factory   = common.HexToAddress("0xfac")
+		child     = program.New().ReturnData([]byte{0x2a}).Bytes()
+		reverting = program.New().Push(0).Push(0).Op(vm.REVERT).Bytes()
+	)
+	statedb.SetCode(factory, program.New().
+		Create2(child, 1).Op(vm.POP).
+		Create2(reverting, 2).Op(vm.POP).
+		Create2(child, 3).Op(vm.POP).
+		Bytes())
+
+	collector := &CreationCollector{}
+	_, _, err = Call(factory, nil, &Config{State: statedb, EVMConfig: vm.Config{Tracer: collector.Hooks()}})
+	require.NoError(t, err)
+	require.Equal(t, []Creation{
+		{Address: Create2Address(factory, uint256.NewInt(1), child), Code: []byte{0x2a}},
+		{Address: Create2Address(factory, uint256.NewInt(3), child), Code: []byte{0x2a}},
+	}, collector.Creations())
+}
+
 func testTemporalDB(t testing.TB) *temporal.DB {
 	db := memdb.NewStateDB(t.TempDir())
 
@@ -544,9 +801,10 @@ func benchmarkNonModifyingCode(gas uint64, code []byte, name string, tracerCode
 	//	}
 	//}
 
+	vmenv, err := NewEnv(cfg)
+	require.NoError(b, err)
 	var (
 		destination = common.BytesToAddress([]byte("contract"))
-		vmenv       = NewEnv(cfg)
 		sender      = vm.AccountRef(cfg.Origin)
 	)
 	cfg.State.CreateAccount(destination, true)
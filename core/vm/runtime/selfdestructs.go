@@ -0,0 +1,69 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/tracing"
+	"github.com/erigontech/erigon/core/vm"
+)
+
+// Selfdestruct is a SELFDESTRUCT executed during a call.
+type Selfdestruct struct {
+	Address     common.Address
+	Beneficiary common.Address
+	// Destroyed is whether the account is marked for destruction at the end of the
+	// transaction, which since EIP-6780 only happens if it was created in the same
+	// transaction, and never if the SELFDESTRUCT was reverted.
+	Destroyed bool
+}
+
+// SelfdestructCollector records the SELFDESTRUCTs executed by the EVM it traces,
+// see Hooks.
+type SelfdestructCollector struct {
+	selfdestructs []Selfdestruct
+}
+
+// Hooks returns the tracing hooks recording the SELFDESTRUCTs, to be set as the
+// tracer of the EVM config.
+func (c *SelfdestructCollector) Hooks() *tracing.Hooks {
+	return &tracing.Hooks{
+		OnEnter: func(depth int, typ byte, from common.Address, to common.Address, precompile bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+			if vm.OpCode(typ) == vm.SELFDESTRUCT {
+				c.selfdestructs = append(c.selfdestructs, Selfdestruct{Address: from, Beneficiary: to})
+			}
+		},
+	}
+}
+
+// Selfdestructs returns the recorded SELFDESTRUCTs, in execution order, telling
+// whether their accounts are marked for destruction in ibs.
+func (c *SelfdestructCollector) Selfdestructs(ibs *state.IntraBlockState) ([]Selfdestruct, error) {
+	selfdestructs := make([]Selfdestruct, len(c.selfdestructs))
+	for i, selfdestruct := range c.selfdestructs {
+		destroyed, err := ibs.HasSelfdestructed(selfdestruct.Address)
+		if err != nil {
+			return nil, err
+		}
+		selfdestruct.Destroyed = destroyed
+		selfdestructs[i] = selfdestruct
+	}
+	return selfdestructs, nil
+}
@@ -809,6 +809,8 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			httpRpcCfg.AllowUnprotectedTxs,
 			httpRpcCfg.MaxGetProofRewindBlockCount,
 			httpRpcCfg.WebsocketSubscribeLogsChannelSize,
+			httpRpcCfg.CallManyMaxBundles,
+			httpRpcCfg.CallManyMaxTxPerBundle,
 			logger,
 		)
 		contractBackend := contracts.NewDirectBackend(ethApi)
@@ -1176,6 +1178,9 @@ func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig
 	}
 	// start HTTP API
 	httpRpcCfg := stack.Config().Http
+	// the RPC oracle keeps the configured default tip, not the miner's gas price
+	rpcGPO := config.GPO
+	httpRpcCfg.GPO = &rpcGPO
 	//eth.APIBackend.gpo = gasprice.NewOracle(eth.APIBackend, gpoParams)
 	if config.Ethstats != "" {
 		var headCh chan [][]byte
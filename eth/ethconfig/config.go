@@ -62,6 +62,7 @@ var FullNodeGPO = gaspricecfg.Config{
 	MaxBlockHistory:  0,
 	MaxPrice:         gaspricecfg.DefaultMaxPrice,
 	IgnorePrice:      gaspricecfg.DefaultIgnorePrice,
+	BlobFeeHeadroom:  gaspricecfg.DefaultBlobFeeHeadroom,
 }
 
 // LightClientGPO contains default gasprice oracle settings for light client.
@@ -72,6 +73,7 @@ var LightClientGPO = gaspricecfg.Config{
 	MaxBlockHistory:  5,
 	MaxPrice:         gaspricecfg.DefaultMaxPrice,
 	IgnorePrice:      gaspricecfg.DefaultIgnorePrice,
+	BlobFeeHeadroom:  gaspricecfg.DefaultBlobFeeHeadroom,
 }
 
 // Defaults contains default settings for use on the Ethereum main net.
@@ -1,4 +1,4 @@
-// Copyright 2024 The Erigon Authors
+// Copyright 2025 The Erigon Authors
 // This file is part of Erigon.
 //
 // Erigon is free software: you can redistribute it and/or modify
@@ -14,8 +14,10 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with Erigon. If not, see <http://www.gnu.org/licenses/>.
 
-package merkle_tree
+package gasprice
 
-func init() {
-	globalHasher = newMerkleHasher()
-}
+var (
+	FeeHistoryCacheHits   = feeHistoryCacheHits
+	FeeHistoryCacheMisses = feeHistoryCacheMisses
+	FeeHistoryBlocks      = feeHistoryBlocks
+)
@@ -24,11 +24,14 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
 	"sort"
-	"sync/atomic"
+	"time"
 
 	"github.com/holiman/uint256"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/erigontech/erigon-lib/chain"
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon/execution/consensus/misc"
@@ -64,6 +67,28 @@ type blockFees struct {
 	err                          error
 }
 
+// FeeHistoryKey identifies a FeeHistory request once its block range is resolved.
+type FeeHistoryKey struct {
+	LastBlock   common.Hash
+	Blocks      int
+	Percentiles string
+}
+
+func newFeeHistoryKey(lastBlock common.Hash, blocks int, percentiles []float64) *FeeHistoryKey {
+	return &FeeHistoryKey{LastBlock: lastBlock, Blocks: blocks, Percentiles: fmt.Sprint(percentiles)}
+}
+
+// FeeHistoryResult holds the arrays returned by FeeHistory. Cached results are
+// shared between callers and must not be modified.
+type FeeHistoryResult struct {
+	OldestBlock      *big.Int
+	Reward           [][]*big.Int
+	BaseFee          []*big.Int
+	GasUsedRatio     []float64
+	BlobBaseFee      []*big.Int
+	BlobGasUsedRatio []float64
+}
+
 // txGasAndReward is sorted in ascending order based on reward
 type (
 	txGasAndReward struct {
@@ -84,8 +109,8 @@ func (s sortGasAndReward) Less(i, j int) bool {
 // processBlock takes a blockFees structure with the blockNumber, the header and optionally
 // the block field filled in, retrieves the block from the backend if not present yet and
 // fills in the rest of the fields.
-func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
-	chainconfig := oracle.backend.ChainConfig()
+// It only reads from bf, so the blocks of a range can be processed concurrently.
+func (oracle *Oracle) processBlock(chainconfig *chain.Config, bf *blockFees, percentiles []float64) {
 	if bf.baseFee = bf.header.BaseFee; bf.baseFee == nil {
 		bf.baseFee = new(big.Int)
 	}
@@ -115,7 +140,11 @@ func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
 		bf.blobBaseFee = new(big.Int)
 		bf.nextBlobBaseFee = new(big.Int)
 	}
-	bf.gasUsedRatio = float64(bf.header.GasUsed) / float64(bf.header.GasLimit)
+	// Gas amounts are below 2^53 so they convert to float64 exactly, and the ratio is
+	// only rounded once, to the nearest float64, as done by the other clients.
+	if bf.header.GasLimit != 0 {
+		bf.gasUsedRatio = float64(bf.header.GasUsed) / float64(bf.header.GasLimit)
+	}
 
 	if blobGasUsed := bf.header.BlobGasUsed; blobGasUsed != nil && chainconfig.GetMaxBlobGasPerBlock(bf.header.Time) != 0 {
 		bf.blobGasUsedRatio = float64(*blobGasUsed) / float64(chainconfig.GetMaxBlobGasPerBlock(bf.header.Time))
@@ -126,37 +155,46 @@ func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
 		return
 	}
 
+	bf.reward = make([]*big.Int, len(percentiles))
+	var (
+		sorter  sortGasAndReward
+		gasUsed uint64
+	)
 	if bf.block == nil || (bf.receipts == nil && len(bf.block.Transactions()) != 0) {
 		oracle.log.Error("Block or receipts are missing while reward percentiles are requested")
-		return
+	} else {
+		sorter = make(sortGasAndReward, 0, len(bf.block.Transactions()))
+		baseFee := uint256.NewInt(0)
+		if bf.block.BaseFee() != nil {
+			baseFee.SetFromBig(bf.block.BaseFee())
+		}
+		gasUsed = bf.block.GasUsed()
+		for i, txn := range bf.block.Transactions() {
+			if oracle.ignoreTxType(txn) {
+				// the gas of ignored transactions doesn't weigh in the percentiles either
+				gasUsed -= min(gasUsed, bf.receipts[i].GasUsed)
+				continue
+			}
+			reward := txn.GetEffectiveGasTip(baseFee)
+			sorter = append(sorter, txGasAndReward{gasUsed: bf.receipts[i].GasUsed, reward: reward.ToBig()})
+		}
 	}
-
-	bf.reward = make([]*big.Int, len(percentiles))
-	if len(bf.block.Transactions()) == 0 {
-		// return an all zero row if there are no transactions to gather data from
+	if len(sorter) == 0 {
+		// return an all zero row if there are no transactions to gather data from,
+		// so that every row has a reward per percentile
 		for i := range bf.reward {
 			bf.reward[i] = new(big.Int)
 		}
 		return
 	}
-
-	sorter := make(sortGasAndReward, len(bf.block.Transactions()))
-	baseFee := uint256.NewInt(0)
-	if bf.block.BaseFee() != nil {
-		baseFee.SetFromBig(bf.block.BaseFee())
-	}
-	for i, txn := range bf.block.Transactions() {
-		reward := txn.GetEffectiveGasTip(baseFee)
-		sorter[i] = txGasAndReward{gasUsed: bf.receipts[i].GasUsed, reward: reward.ToBig()}
-	}
 	sort.Sort(sorter)
 
 	var txIndex int
 	sumGasUsed := sorter[0].gasUsed
 
 	for i, p := range percentiles {
-		thresholdGasUsed := uint64(float64(bf.block.GasUsed()) * p / 100)
-		for sumGasUsed < thresholdGasUsed && txIndex < len(bf.block.Transactions())-1 {
+		thresholdGasUsed := uint64(float64(gasUsed) * p / 100)
+		for sumGasUsed < thresholdGasUsed && txIndex < len(sorter)-1 {
 			txIndex++
 			sumGasUsed += sorter[txIndex].gasUsed
 		}
@@ -164,6 +202,34 @@ func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
 	}
 }
 
+// pendingBlockAndReceipts returns the pending block of the backend. Backends
+// without one get an empty block on top of the head instead, which carries the
+// projected base fees of the next block.
+func (oracle *Oracle) pendingBlockAndReceipts(ctx context.Context) (*types.Block, types.Receipts, error) {
+	if block, receipts := oracle.backend.PendingBlockAndReceipts(); block != nil {
+		return block, receipts, nil
+	}
+	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil || head == nil {
+		return nil, nil, err
+	}
+	chainconfig := oracle.backend.ChainConfig()
+	header := &types.Header{
+		ParentHash: head.Hash(),
+		Number:     new(big.Int).Add(head.Number, common.Big1),
+		GasLimit:   head.GasLimit,
+		Time:       head.Time + chainconfig.SecondsPerSlot(),
+	}
+	if chainconfig.IsLondon(header.Number.Uint64()) {
+		header.BaseFee = misc.CalcBaseFee(chainconfig, head)
+	}
+	if head.ExcessBlobGas != nil {
+		excessBlobGas, blobGasUsed := misc.CalcExcessBlobGas(chainconfig, head, header.Time), uint64(0)
+		header.ExcessBlobGas, header.BlobGasUsed = &excessBlobGas, &blobGasUsed
+	}
+	return types.NewBlockWithHeader(header), nil, nil
+}
+
 // resolveBlockRange resolves the specified block range to absolute block numbers while also
 // enforcing backend specific limitations. The pending block and corresponding receipts are
 // also returned if requested and available.
@@ -177,7 +243,11 @@ func (oracle *Oracle) resolveBlockRange(ctx context.Context, lastBlock rpc.Block
 	)
 	// query either pending block or head header and set headBlock
 	if lastBlock == rpc.PendingBlockNumber {
-		if pendingBlock, pendingReceipts = oracle.backend.PendingBlockAndReceipts(); pendingBlock != nil {
+		var err error
+		if pendingBlock, pendingReceipts, err = oracle.pendingBlockAndReceipts(ctx); err != nil {
+			return nil, nil, 0, 0, err
+		}
+		if pendingBlock != nil {
 			lastBlock = rpc.BlockNumber(pendingBlock.NumberU64())
 			headBlock = lastBlock - 1
 		} else {
@@ -230,27 +300,46 @@ func (oracle *Oracle) resolveBlockRange(ctx context.Context, lastBlock rpc.Block
 //   - reward: the requested percentiles of effective priority fees per gas of transactions in each
 //     block, sorted in ascending order and weighted by gas used.
 //   - baseFee: base fee per gas in the given block
-//   - gasUsedRatio: gasUsed/gasLimit in the given block
+//   - gasUsedRatio: gasUsed/gasLimit in the given block, rounded to the nearest float64
+//     (0 for a block without gas limit)
 //
 // Note: baseFee includes the next block after the newest of the returned range, because this
 // value can be derived from the newest block.
+//
+// The requested number of blocks is truncated to maxFeeHistory and to the available blocks
+// before anything is allocated, so that a huge count costs no more than the actual range.
+//
+// Results are cached by the hash of the last block of the range, so that identical
+// requests (typically from dashboards polling) are served without reprocessing the
+// blocks, while a reorged range is recomputed. Requests for the pending block bypass
+// the cache.
 func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, []*big.Int, []float64, error) {
+	res, err := oracle.feeHistory(ctx, blocks, unresolvedLastBlock, rewardPercentiles, newBlockFetcher(oracle.backend))
+	if err != nil {
+		return common.Big0, nil, nil, nil, nil, nil, err
+	}
+	return res.OldestBlock, res.Reward, res.BaseFee, res.GasUsedRatio, res.BlobBaseFee, res.BlobGasUsedRatio, nil
+}
+
+// feeHistory computes the fee history of a range, see FeeHistory, fetching its blocks
+// through fetcher.
+func (oracle *Oracle) feeHistory(ctx context.Context, blocks int, unresolvedLastBlock rpc.BlockNumber, rewardPercentiles []float64, fetcher *blockFetcher) (*FeeHistoryResult, error) {
 	if blocks < 1 {
-		return common.Big0, nil, nil, nil, nil, nil, nil // returning with no data and no error means there are no retrievable blocks
+		return &FeeHistoryResult{OldestBlock: common.Big0}, nil // returning with no data and no error means there are no retrievable blocks
 	}
 	if blocks > maxFeeHistory {
 		oracle.log.Warn("Sanitizing fee history length", "requested", blocks, "truncated", maxFeeHistory)
 		blocks = maxFeeHistory
 	}
 	if len(rewardPercentiles) > maxQueryLimit {
-		return common.Big0, nil, nil, nil, nil, nil, fmt.Errorf("%w: over the query limit %d", ErrInvalidPercentile, maxQueryLimit)
+		return nil, fmt.Errorf("%w: over the query limit %d", ErrInvalidPercentile, maxQueryLimit)
 	}
 	for i, p := range rewardPercentiles {
 		if p < 0 || p > 100 {
-			return common.Big0, nil, nil, nil, nil, nil, fmt.Errorf("%w: %f", ErrInvalidPercentile, p)
+			return nil, fmt.Errorf("%w: %f", ErrInvalidPercentile, p)
 		}
 		if i > 0 && p <= rewardPercentiles[i-1] {
-			return common.Big0, nil, nil, nil, nil, nil, fmt.Errorf("%w: #%d:%f >= #%d:%f", ErrInvalidPercentile, i-1, rewardPercentiles[i-1], i, p)
+			return nil, fmt.Errorf("%w: #%d:%f >= #%d:%f", ErrInvalidPercentile, i-1, rewardPercentiles[i-1], i, p)
 		}
 	}
 	// Only process blocks if reward percentiles were requested
@@ -264,14 +353,32 @@ func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLast
 		err             error
 	)
 	pendingBlock, pendingReceipts, lastBlock, blocks, err := oracle.resolveBlockRange(ctx, unresolvedLastBlock, blocks, maxHistory)
-	if err != nil || blocks == 0 {
-		return common.Big0, nil, nil, nil, nil, nil, err
+	if err != nil {
+		return nil, err
+	}
+	if blocks == 0 {
+		return &FeeHistoryResult{OldestBlock: common.Big0}, nil
+	}
+	var cacheKey *FeeHistoryKey
+	if oracle.cache != nil && unresolvedLastBlock != rpc.PendingBlockNumber {
+		// The last block identifies the whole range, keying on its hash makes a
+		// range changed by a reorg miss.
+		lastHeader, err := oracle.backend.HeaderByNumber(ctx, rpc.BlockNumber(lastBlock))
+		if err != nil {
+			return nil, err
+		}
+		if lastHeader != nil {
+			cacheKey = newFeeHistoryKey(lastHeader.Hash(), blocks, rewardPercentiles)
+			if res, ok := oracle.cache.GetFeeHistory(*cacheKey); ok {
+				feeHistoryCacheHits.Inc()
+				return res, nil
+			}
+			feeHistoryCacheMisses.Inc()
+		}
 	}
+	start := time.Now()
 	oldestBlock := lastBlock + 1 - uint64(blocks)
 
-	var (
-		next = oldestBlock
-	)
 	var (
 		reward           = make([][]*big.Int, blocks)
 		baseFee          = make([]*big.Int, blocks+1)
@@ -279,44 +386,56 @@ func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLast
 		blobGasUsedRatio = make([]float64, blocks)
 		blobBaseFee      = make([]*big.Int, blocks+1)
 		firstMissing     = blocks
+		fees             = make([]*blockFees, blocks)
+		chainconfig      = oracle.backend.ChainConfig()
 	)
-	for ; blocks > 0; blocks-- {
-		if err = common.Stopped(ctx.Done()); err != nil {
-			return common.Big0, nil, nil, nil, nil, nil, err
+	// The backend reads from a single database transaction, so the blocks are
+	// fetched one by one, but processing them (sorting the rewards of every
+	// transaction) is spread over a bounded pool of workers.
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(runtime.GOMAXPROCS(0))
+	for i := range fees {
+		if err = gCtx.Err(); err != nil {
+			break
 		}
-		// Retrieve the next block number to fetch with this goroutine
-		blockNumber := atomic.AddUint64(&next, 1) - 1
-		if blockNumber > lastBlock {
-			continue
-		}
-
-		fees := &blockFees{blockNumber: blockNumber}
+		blockNumber := oldestBlock + uint64(i)
+		bf := &blockFees{blockNumber: blockNumber}
 		if pendingBlock != nil && blockNumber >= pendingBlock.NumberU64() {
-			fees.block, fees.receipts = pendingBlock, pendingReceipts
+			bf.block, bf.receipts = pendingBlock, pendingReceipts
 		} else {
-			if len(rewardPercentiles) != 0 {
-				fees.block, fees.err = oracle.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNumber))
-				if fees.block != nil && fees.err == nil {
-					fees.receipts, fees.err = oracle.backend.GetReceiptsGasUsed(ctx, fees.block)
-				}
-			} else {
-				fees.header, fees.err = oracle.backend.HeaderByNumber(ctx, rpc.BlockNumber(blockNumber))
-			}
+			bf.header, bf.block, bf.receipts, bf.err = fetcher.fetch(gCtx, blockNumber, len(rewardPercentiles) != 0)
 		}
-		if fees.block != nil {
-			fees.header = fees.block.Header()
+		if bf.err != nil {
+			err = bf.err
+			break
 		}
-		if fees.header != nil {
-			oracle.processBlock(fees, rewardPercentiles)
+		if bf.block != nil {
+			bf.header = bf.block.Header()
 		}
-
-		if fees.err != nil {
-			return common.Big0, nil, nil, nil, nil, nil, fees.err
+		fees[i] = bf
+		if bf.header != nil {
+			g.Go(func() error {
+				// queued blocks are dropped once the request is cancelled
+				if err := gCtx.Err(); err != nil {
+					return err
+				}
+				oracle.processBlock(chainconfig, bf, rewardPercentiles)
+				return bf.err
+			})
 		}
-		i := int(fees.blockNumber - oldestBlock)
-		if fees.header != nil {
-			reward[i], baseFee[i], baseFee[i+1], gasUsedRatio[i] = fees.reward, fees.baseFee, fees.nextBaseFee, fees.gasUsedRatio
-			blobGasUsedRatio[i], blobBaseFee[i], blobBaseFee[i+1] = fees.blobGasUsedRatio, fees.blobBaseFee, fees.nextBlobBaseFee
+	}
+	// a worker failure cancels gCtx, report the failure rather than the cancellation
+	if waitErr := g.Wait(); waitErr != nil {
+		err = waitErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, bf := range fees {
+		if bf.header != nil {
+			reward[i], baseFee[i], baseFee[i+1], gasUsedRatio[i] = bf.reward, bf.baseFee, bf.nextBaseFee, bf.gasUsedRatio
+			blobGasUsedRatio[i], blobBaseFee[i], blobBaseFee[i+1] = bf.blobGasUsedRatio, bf.blobBaseFee, bf.nextBlobBaseFee
 		} else {
 			// getting no block and no error means we are requesting into the future (might happen because of a reorg)
 			if i < firstMissing {
@@ -325,7 +444,7 @@ func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLast
 		}
 	}
 	if firstMissing == 0 {
-		return common.Big0, nil, nil, nil, nil, nil, nil
+		return &FeeHistoryResult{OldestBlock: common.Big0}, nil
 	}
 	if len(rewardPercentiles) != 0 {
 		reward = reward[:firstMissing]
@@ -333,5 +452,87 @@ func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLast
 		reward = nil
 	}
 	baseFee, gasUsedRatio = baseFee[:firstMissing+1], gasUsedRatio[:firstMissing]
-	return new(big.Int).SetUint64(oldestBlock), reward, baseFee, gasUsedRatio, blobBaseFee, blobGasUsedRatio, nil
+	res := &FeeHistoryResult{
+		OldestBlock:      new(big.Int).SetUint64(oldestBlock),
+		Reward:           reward,
+		BaseFee:          baseFee,
+		GasUsedRatio:     gasUsedRatio,
+		BlobBaseFee:      blobBaseFee,
+		BlobGasUsedRatio: blobGasUsedRatio,
+	}
+	// a missing block means the head moved during processing, don't cache a partial range
+	feeHistoryTook.ObserveDuration(start)
+	feeHistoryBlocks.AddInt(firstMissing)
+	if cacheKey != nil && firstMissing == blocks {
+		oracle.cache.SetFeeHistory(*cacheKey, res)
+	}
+	return res, nil
+}
+
+// FeeHistoryQuery is a fee history request, see FeeHistory.
+type FeeHistoryQuery struct {
+	Blocks            int
+	LastBlock         rpc.BlockNumber
+	RewardPercentiles []float64
+}
+
+// FeeHistoryBatch runs several fee history queries, like FeeHistory, and returns their
+// results and errors in the order of the queries. The blocks of overlapping ranges are
+// fetched once for all of them, and cached results are reused.
+func (oracle *Oracle) FeeHistoryBatch(ctx context.Context, queries []FeeHistoryQuery) ([]*FeeHistoryResult, []error) {
+	var (
+		results = make([]*FeeHistoryResult, len(queries))
+		errs    = make([]error, len(queries))
+		fetcher = newBlockFetcher(oracle.backend)
+	)
+	for i, q := range queries {
+		results[i], errs[i] = oracle.feeHistory(ctx, q.Blocks, q.LastBlock, q.RewardPercentiles, fetcher)
+	}
+	return results, errs
+}
+
+// fetchedBlock is a block, or only its header, fetched by a blockFetcher.
+type fetchedBlock struct {
+	header   *types.Header
+	block    *types.Block // only set if the receipts were fetched too
+	receipts types.Receipts
+}
+
+// blockFetcher fetches the blocks of fee history ranges from the backend, each once.
+type blockFetcher struct {
+	backend OracleBackend
+	fetched map[uint64]*fetchedBlock
+}
+
+func newBlockFetcher(backend OracleBackend) *blockFetcher {
+	return &blockFetcher{backend: backend, fetched: map[uint64]*fetchedBlock{}}
+}
+
+// fetch returns the header of a block and, if withBlock is set, the block and its
+// receipts. A block beyond the head is returned as nil, without error.
+func (f *blockFetcher) fetch(ctx context.Context, number uint64, withBlock bool) (*types.Header, *types.Block, types.Receipts, error) {
+	fb, ok := f.fetched[number]
+	if ok && (fb.block != nil || !withBlock) {
+		return fb.header, fb.block, fb.receipts, nil
+	}
+	fb = &fetchedBlock{}
+	if withBlock {
+		block, err := f.backend.BlockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil || block == nil {
+			return nil, nil, nil, err
+		}
+		receipts, err := f.backend.GetReceiptsGasUsed(ctx, block)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fb.header, fb.block, fb.receipts = block.Header(), block, receipts
+	} else {
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil || header == nil {
+			return nil, nil, nil, err
+		}
+		fb.header = header
+	}
+	f.fetched[number] = fb
+	return fb.header, fb.block, fb.receipts, nil
 }
@@ -22,10 +22,20 @@ package gasprice_test
 import (
 	"context"
 	"errors"
+	"math"
+	"math/big"
+	"runtime"
 	"testing"
 
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/types"
+	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/eth/gasprice"
 	"github.com/erigontech/erigon/eth/gasprice/gaspricecfg"
 	"github.com/erigontech/erigon/rpc"
@@ -44,7 +54,6 @@ func TestFeeHistory(t *testing.T) {
 	}
 
 	var cases = []struct {
-		pending             bool
 		maxHeader, maxBlock int
 		count               int
 		last                rpc.BlockNumber
@@ -53,21 +62,19 @@ func TestFeeHistory(t *testing.T) {
 		expCount            int
 		expErr              error
 	}{
-		{false, 0, 0, 10, 30, nil, 21, 10, nil},
-		{false, 0, 0, 10, 30, []float64{0, 10}, 21, 10, nil},
-		{false, 0, 0, 10, 30, []float64{20, 10}, 0, 0, gasprice.ErrInvalidPercentile},
-		{false, 0, 0, 1000000000, 30, nil, 0, 31, nil},
-		{false, 0, 0, 1000000000, rpc.LatestBlockNumber, nil, 0, 33, nil},
-		{false, 0, 0, 10, 40, nil, 0, 0, gasprice.ErrRequestBeyondHead},
-		//{true, 0, 0, 10, 40, nil, 0, 0, gasprice.ErrRequestBeyondHead},
-		{false, 20, 2, 100, rpc.LatestBlockNumber, nil, 13, 20, nil},
-		{false, 20, 2, 100, rpc.LatestBlockNumber, []float64{0, 10}, 31, 2, nil},
-		{false, 20, 2, 100, 32, []float64{0, 10}, 31, 2, nil},
-		{false, 0, 0, 1, rpc.PendingBlockNumber, nil, 0, 0, nil},
-		{false, 0, 0, 2, rpc.PendingBlockNumber, nil, 32, 1, nil},
-		{false, 0, 0, 10, 30, overMaxQuery, 0, 0, gasprice.ErrInvalidPercentile},
-		//{true, 0, 0, 2, rpc.PendingBlockNumber, nil, 32, 2, nil},
-		//{true, 0, 0, 2, rpc.PendingBlockNumber, []float64{0, 10}, 32, 2, nil},
+		{0, 0, 10, 30, nil, 21, 10, nil},
+		{0, 0, 10, 30, []float64{0, 10}, 21, 10, nil},
+		{0, 0, 10, 30, []float64{20, 10}, 0, 0, gasprice.ErrInvalidPercentile},
+		{0, 0, 1000000000, 30, nil, 0, 31, nil},
+		{0, 0, 1000000000, rpc.LatestBlockNumber, nil, 0, 33, nil},
+		{0, 0, 10, 40, nil, 0, 0, gasprice.ErrRequestBeyondHead},
+		{20, 2, 100, rpc.LatestBlockNumber, nil, 13, 20, nil},
+		{20, 2, 100, rpc.LatestBlockNumber, []float64{0, 10}, 31, 2, nil},
+		{20, 2, 100, 32, []float64{0, 10}, 31, 2, nil},
+		{0, 0, 1, rpc.PendingBlockNumber, nil, 33, 1, nil},
+		{0, 0, 2, rpc.PendingBlockNumber, nil, 32, 2, nil},
+		{0, 0, 10, 30, overMaxQuery, 0, 0, gasprice.ErrInvalidPercentile},
+		{0, 0, 2, rpc.PendingBlockNumber, []float64{0, 10}, 32, 2, nil},
 	}
 	for i, c := range cases {
 		config := gaspricecfg.Config{
@@ -76,7 +83,7 @@ func TestFeeHistory(t *testing.T) {
 		}
 
 		func() {
-			m := newTestBackend(t) //, big.NewInt(16), c.pending)
+			m := newTestBackend(t)
 			defer m.Close()
 
 			baseApi := jsonrpc.NewBaseApi(nil, kvcache.NewDummy(), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil)
@@ -84,7 +91,8 @@ func TestFeeHistory(t *testing.T) {
 			defer tx.Rollback()
 
 			cache := jsonrpc.NewGasPriceCache()
-			oracle := gasprice.NewOracle(jsonrpc.NewGasPriceOracleBackend(tx, baseApi), config, cache, log.New())
+			backend := jsonrpc.NewGasPriceOracleBackend(tx, baseApi)
+			oracle := gasprice.NewOracle(backend, config, cache, log.New())
 
 			first, reward, baseFee, ratio, blobBaseFee, blobBaseFeeRatio, err := oracle.FeeHistory(context.Background(), c.count, c.last, c.percent)
 
@@ -118,6 +126,430 @@ func TestFeeHistory(t *testing.T) {
 			if err != c.expErr && !errors.Is(err, c.expErr) {
 				t.Fatalf("Test case %d: error mismatch, want %v, got %v", i, c.expErr, err)
 			}
+			for j := range ratio {
+				if c.last == rpc.PendingBlockNumber && j == len(ratio)-1 {
+					break // the pending block is built by the oracle
+				}
+				header, err := backend.HeaderByNumber(context.Background(), rpc.BlockNumber(first.Uint64()+uint64(j)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := float64(header.GasUsed) / float64(header.GasLimit); ratio[j] != want {
+					t.Fatalf("Test case %d: gasUsedRatio %d mismatch, want %v, got %v", i, j, want, ratio[j])
+				}
+			}
 		}()
 	}
 }
+
+func TestFeeHistoryPending(t *testing.T) {
+	m := newTestBackend(t)
+	defer m.Close()
+
+	baseApi := jsonrpc.NewBaseApi(nil, kvcache.NewDummy(), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil)
+	tx, err := m.DB.BeginTemporalRo(m.Ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	oracle := gasprice.NewOracle(jsonrpc.NewGasPriceOracleBackend(tx, baseApi), gaspricecfg.Config{}, jsonrpc.NewGasPriceCache(), log.New())
+	first, reward, baseFee, ratio, _, _, err := oracle.FeeHistory(context.Background(), 2, rpc.PendingBlockNumber, []float64{50})
+	require.NoError(t, err)
+	require.Equal(t, uint64(32), first.Uint64())
+	require.Len(t, baseFee, 3)
+	// the latest block pays a tip, the pending block is empty
+	require.Positive(t, reward[0][0].Sign())
+	require.Equal(t, [][]*big.Int{{reward[0][0]}, {new(big.Int)}}, reward)
+	require.Equal(t, float64(0), ratio[1])
+}
+
+// blockBackend serves a single block with its receipts.
+type blockBackend struct {
+	gasprice.OracleBackend
+	block    *types.Block
+	receipts types.Receipts
+}
+
+func (b *blockBackend) HeaderByNumber(_ context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber || uint64(number) == b.block.NumberU64() {
+		return b.block.Header(), nil
+	}
+	return nil, nil
+}
+
+func (b *blockBackend) BlockByNumber(_ context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if uint64(number) == b.block.NumberU64() {
+		return b.block, nil
+	}
+	return nil, nil
+}
+
+func (b *blockBackend) GetReceiptsGasUsed(context.Context, *types.Block) (types.Receipts, error) {
+	return b.receipts, nil
+}
+
+func (b *blockBackend) ChainConfig() *chain.Config { return chain.TestChainConfig }
+
+func (b *blockBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) { return nil, nil }
+
+func TestFeeHistoryGasWeightedReward(t *testing.T) {
+	// one large transaction with a low tip and many small ones with a high tip
+	var (
+		txs      []types.Transaction
+		receipts types.Receipts
+		gasUsed  uint64
+	)
+	addTx := func(gas uint64, tip int64) {
+		txs = append(txs, types.NewTransaction(uint64(len(txs)), common.Address{}, uint256.NewInt(0), gas, uint256.NewInt(uint64(tip)), nil))
+		receipts = append(receipts, &types.Receipt{GasUsed: gas})
+		gasUsed += gas
+	}
+	addTx(900_000, common.GWei)
+	for i := 0; i < 9; i++ {
+		addTx(21_000, 10*common.GWei)
+	}
+	header := &types.Header{Number: big.NewInt(1), GasLimit: 30_000_000, GasUsed: gasUsed}
+	backend := &blockBackend{block: types.NewBlock(header, txs, nil, receipts, nil), receipts: receipts}
+
+	oracle := gasprice.NewOracle(backend, gaspricecfg.Config{}, jsonrpc.NewGasPriceCache(), log.New())
+	_, reward, _, _, _, _, err := oracle.FeeHistory(context.Background(), 1, 1, []float64{50, 95})
+	require.NoError(t, err)
+
+	// the median transaction pays 10 gwei, but most of the gas is paid 1 gwei
+	require.Equal(t, big.NewInt(common.GWei), reward[0][0])
+	require.Equal(t, big.NewInt(10*common.GWei), reward[0][1])
+}
+
+func TestFeeHistoryGasUsedRatio(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(1), GasLimit: 30_000_000, GasUsed: 10_000_000}
+	backend := &blockBackend{block: types.NewBlockWithHeader(header)}
+
+	oracle := gasprice.NewOracle(backend, gaspricecfg.Config{}, jsonrpc.NewGasPriceCache(), log.New())
+	_, _, _, ratio, _, _, err := oracle.FeeHistory(context.Background(), 1, 1, nil)
+	require.NoError(t, err)
+	// not truncated to a few decimals
+	require.Equal(t, []float64{0.3333333333333333}, ratio)
+}
+
+// chainBackend serves consecutive blocks, starting at genesis, with their receipts.
+type chainBackend struct {
+	gasprice.OracleBackend
+	blocks   []*types.Block
+	receipts []types.Receipts
+}
+
+func (b *chainBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if block, _ := b.BlockByNumber(ctx, number); block != nil {
+		return block.Header(), nil
+	}
+	return nil, nil
+}
+
+func (b *chainBackend) BlockByNumber(_ context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if number < 0 || int(number) >= len(b.blocks) {
+		return nil, nil
+	}
+	return b.blocks[number], nil
+}
+
+func (b *chainBackend) GetReceiptsGasUsed(_ context.Context, block *types.Block) (types.Receipts, error) {
+	return b.receipts[block.NumberU64()], nil
+}
+
+func (b *chainBackend) ChainConfig() *chain.Config { return chain.TestChainConfig }
+
+func (b *chainBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) { return nil, nil }
+
+// addBlock appends a block with a transaction paying each of the given tips.
+func (b *chainBackend) addBlock(tips ...int64) {
+	var txs []types.Transaction
+	for _, tip := range tips {
+		txs = append(txs, types.NewTransaction(uint64(len(txs)), common.Address{}, uint256.NewInt(0), 21_000, uint256.NewInt(uint64(tip)), nil))
+	}
+	b.addBlockWithTxs(txs...)
+}
+
+// addBlockWithTxs appends a block with the given transactions, each using 21000 gas.
+func (b *chainBackend) addBlockWithTxs(txs ...types.Transaction) {
+	receipts := make(types.Receipts, len(txs))
+	for i := range receipts {
+		receipts[i] = &types.Receipt{GasUsed: 21_000}
+	}
+	header := &types.Header{Number: big.NewInt(int64(len(b.blocks))), Coinbase: common.Address{1}, GasLimit: 30_000_000, GasUsed: uint64(len(txs)) * 21_000}
+	b.blocks = append(b.blocks, types.NewBlock(header, txs, nil, receipts, nil))
+	b.receipts = append(b.receipts, receipts)
+}
+
+func TestFeeHistoryEmptyBlock(t *testing.T) {
+	backend := &chainBackend{}
+	backend.addBlock(common.GWei, 2*common.GWei)
+	backend.addBlock()
+	backend.addBlock(3 * common.GWei)
+
+	oracle := gasprice.NewOracle(backend, gaspricecfg.Config{}, jsonrpc.NewGasPriceCache(), log.New())
+	first, reward, _, _, _, _, err := oracle.FeeHistory(context.Background(), 3, rpc.LatestBlockNumber, []float64{10, 50, 90})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), first.Uint64())
+	// the empty block has a zero reward for every percentile
+	require.Equal(t, [][]*big.Int{
+		{big.NewInt(common.GWei), big.NewInt(common.GWei), big.NewInt(2 * common.GWei)},
+		{new(big.Int), new(big.Int), new(big.Int)},
+		{big.NewInt(3 * common.GWei), big.NewInt(3 * common.GWei), big.NewInt(3 * common.GWei)},
+	}, reward)
+}
+
+// This test checks that the allocations of FeeHistory depend on the number of
+// blocks available, not on the number of blocks requested.
+func TestFeeHistoryHugeCount(t *testing.T) {
+	backend := &chainBackend{}
+	for i := 0; i < 3; i++ {
+		backend.addBlock(common.GWei)
+	}
+	oracle := gasprice.NewOracle(backend, gaspricecfg.Config{}, nil, log.New())
+	allocated := func(count int) uint64 {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, reward, _, _, _, _, err := oracle.FeeHistory(context.Background(), count, rpc.LatestBlockNumber, []float64{50})
+		runtime.ReadMemStats(&after)
+		require.NoError(t, err)
+		require.Len(t, reward, 3)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+	allocated(3) // warm up
+	small, huge := allocated(3), allocated(math.MaxInt)
+	t.Logf("allocated %d bytes for 3 blocks, %d bytes for %d blocks", small, huge, math.MaxInt)
+	require.Less(t, huge, 2*small)
+}
+
+func TestFeeHistoryIgnoreTxTypes(t *testing.T) {
+	// a system transaction, of a type to ignore, using most of the gas with a high tip
+	system := &types.AccessListTx{LegacyTx: types.LegacyTx{
+		CommonTx: types.CommonTx{Nonce: 2, GasLimit: 21_000, Value: uint256.NewInt(0)},
+		GasPrice: uint256.NewInt(100 * common.GWei),
+	}, ChainID: uint256.NewInt(1)}
+	backend := &chainBackend{}
+	backend.addBlock()
+	backend.addBlockWithTxs(
+		types.NewTransaction(0, common.Address{}, uint256.NewInt(0), 21_000, uint256.NewInt(common.GWei), nil),
+		types.NewTransaction(1, common.Address{}, uint256.NewInt(0), 21_000, uint256.NewInt(2*common.GWei), nil),
+		system, system, system,
+	)
+	backend.addBlockWithTxs(system)
+
+	config := gaspricecfg.Config{IgnoreTxTypes: []byte{types.AccessListTxType}}
+	oracle := gasprice.NewOracle(backend, config, nil, log.New())
+	_, reward, _, _, _, _, err := oracle.FeeHistory(context.Background(), 2, rpc.LatestBlockNumber, []float64{10, 50, 90})
+	require.NoError(t, err)
+	require.Equal(t, [][]*big.Int{
+		{big.NewInt(common.GWei), big.NewInt(common.GWei), big.NewInt(2 * common.GWei)},
+		// a block with only ignored transactions has no rewards
+		{new(big.Int), new(big.Int), new(big.Int)},
+	}, reward)
+
+	// the tip suggestion doesn't sample them either
+	config = gaspricecfg.Config{Blocks: 2, Percentile: 100, IgnoreTxTypes: []byte{types.AccessListTxType}}
+	tip, err := gasprice.NewOracle(backend, config, jsonrpc.NewGasPriceCache(), log.New()).SuggestTipCap(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2*common.GWei), tip)
+}
+
+// fetchCountingBackend counts the fetches of each block.
+type fetchCountingBackend struct {
+	*chainBackend
+	fetches map[rpc.BlockNumber]int
+}
+
+func (b *fetchCountingBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	b.fetches[number]++
+	return b.chainBackend.BlockByNumber(ctx, number)
+}
+
+func TestFeeHistoryBatch(t *testing.T) {
+	chain := &chainBackend{}
+	for i := 0; i < 6; i++ {
+		chain.addBlock(int64(i+1)*common.GWei, int64(i+2)*common.GWei)
+	}
+	queries := []gasprice.FeeHistoryQuery{
+		{Blocks: 3, LastBlock: 4, RewardPercentiles: []float64{50}},
+		{Blocks: 3, LastBlock: rpc.LatestBlockNumber, RewardPercentiles: []float64{10, 90}},
+		{Blocks: 2, LastBlock: 2, RewardPercentiles: []float64{50}},
+		{Blocks: 2, LastBlock: 10, RewardPercentiles: []float64{50}},
+	}
+
+	backend := &fetchCountingBackend{chainBackend: chain, fetches: map[rpc.BlockNumber]int{}}
+	oracle := gasprice.NewOracle(backend, gaspricecfg.Config{}, nil, log.New())
+	results, errs := oracle.FeeHistoryBatch(context.Background(), queries)
+	require.Len(t, results, len(queries))
+	require.Len(t, errs, len(queries))
+
+	// each result is the one of the query on its own
+	for i, q := range queries {
+		first, reward, baseFee, ratio, blobBaseFee, blobGasUsedRatio, err := gasprice.NewOracle(chain, gaspricecfg.Config{}, nil, log.New()).FeeHistory(context.Background(), q.Blocks, q.LastBlock, q.RewardPercentiles)
+		if err != nil {
+			require.EqualError(t, errs[i], err.Error(), "query %d", i)
+			continue
+		}
+		require.NoError(t, errs[i], "query %d", i)
+		require.Equal(t, &gasprice.FeeHistoryResult{
+			OldestBlock:      first,
+			Reward:           reward,
+			BaseFee:          baseFee,
+			GasUsedRatio:     ratio,
+			BlobBaseFee:      blobBaseFee,
+			BlobGasUsedRatio: blobGasUsedRatio,
+		}, results[i], "query %d", i)
+	}
+	require.ErrorIs(t, errs[3], gasprice.ErrRequestBeyondHead)
+
+	// the overlapping blocks were fetched once
+	require.Equal(t, map[rpc.BlockNumber]int{1: 1, 2: 1, 3: 1, 4: 1, 5: 1}, backend.fetches)
+}
+
+// countingCache counts the fee history cache hits.
+type countingCache struct {
+	*jsonrpc.GasPriceCache
+	hits int
+}
+
+func (c *countingCache) GetFeeHistory(key gasprice.FeeHistoryKey) (*gasprice.FeeHistoryResult, bool) {
+	res, ok := c.GasPriceCache.GetFeeHistory(key)
+	if ok {
+		c.hits++
+	}
+	return res, ok
+}
+
+func TestFeeHistoryCache(t *testing.T) {
+	m := newTestBackend(t)
+	defer m.Close()
+
+	baseApi := jsonrpc.NewBaseApi(nil, kvcache.NewDummy(), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil)
+	tx, err := m.DB.BeginTemporalRo(m.Ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	cache := &countingCache{GasPriceCache: jsonrpc.NewGasPriceCache()}
+	oracle := gasprice.NewOracle(jsonrpc.NewGasPriceOracleBackend(tx, baseApi), gaspricecfg.Config{}, cache, log.New())
+	feeHistory := func(last rpc.BlockNumber, percentiles []float64) []any {
+		first, reward, baseFee, ratio, blobBaseFee, blobGasUsedRatio, err := oracle.FeeHistory(context.Background(), 10, last, percentiles)
+		require.NoError(t, err)
+		return []any{first, reward, baseFee, ratio, blobBaseFee, blobGasUsedRatio}
+	}
+
+	hits, misses, blocks := gasprice.FeeHistoryCacheHits.GetValueUint64(), gasprice.FeeHistoryCacheMisses.GetValueUint64(), gasprice.FeeHistoryBlocks.GetValueUint64()
+	first := feeHistory(30, []float64{0, 50})
+	require.Equal(t, 0, cache.hits)
+	require.Equal(t, first, feeHistory(30, []float64{0, 50}))
+	require.Equal(t, 1, cache.hits)
+	require.Equal(t, hits+1, gasprice.FeeHistoryCacheHits.GetValueUint64())
+	require.Equal(t, misses+1, gasprice.FeeHistoryCacheMisses.GetValueUint64())
+	require.Equal(t, blocks+10, gasprice.FeeHistoryBlocks.GetValueUint64())
+
+	// other percentiles are a different request
+	feeHistory(30, []float64{10})
+	require.Equal(t, 1, cache.hits)
+
+	// the pending block is never cached
+	feeHistory(rpc.PendingBlockNumber, nil)
+	feeHistory(rpc.PendingBlockNumber, nil)
+	require.Equal(t, 1, cache.hits)
+}
+
+func TestFeeHistoryCacheReorg(t *testing.T) {
+	m := newTestMock(t)
+	defer m.Close()
+	baseApi := jsonrpc.NewBaseApi(nil, kvcache.NewDummy(), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil)
+
+	// the competing chain is longer and pays twice the tips, both are generated
+	// before inserting anything as generation reads the current state
+	signer := types.LatestSigner(m.ChainConfig)
+	generate := func(blocks int, coinbase common.Address, tipFactor int64) *core.ChainPack {
+		chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, blocks, func(i int, b *core.BlockGen) {
+			b.SetCoinbase(coinbase)
+			txn, err := types.SignTx(types.NewTransaction(b.TxNonce(m.Address), common.HexToAddress("deadbeef"), uint256.NewInt(100), 21000, uint256.NewInt(uint64(tipFactor*int64(i+1)*common.GWei)), nil), *signer, m.Key)
+			require.NoError(t, err)
+			b.AddTx(txn)
+		})
+		require.NoError(t, err)
+		return chain
+	}
+	original := generate(32, common.Address{1}, 1)
+	fork := generate(34, common.Address{2}, 2)
+
+	cache := &countingCache{GasPriceCache: jsonrpc.NewGasPriceCache()}
+	reward := func() *big.Int {
+		tx, err := m.DB.BeginTemporalRo(m.Ctx)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		oracle := gasprice.NewOracle(jsonrpc.NewGasPriceOracleBackend(tx, baseApi), gaspricecfg.Config{}, cache, log.New())
+		_, reward, _, _, _, _, err := oracle.FeeHistory(context.Background(), 1, 10, []float64{50})
+		require.NoError(t, err)
+		return reward[0][0]
+	}
+
+	require.NoError(t, m.InsertChain(original))
+	require.Equal(t, big.NewInt(10*common.GWei), reward())
+	require.Equal(t, big.NewInt(10*common.GWei), reward())
+	require.Equal(t, 1, cache.hits)
+
+	require.NoError(t, m.InsertChain(fork))
+	require.Equal(t, big.NewInt(20*common.GWei), reward())
+	require.Equal(t, 1, cache.hits)
+}
+
+// cancellingBackend cancels the request after serving a number of blocks.
+type cancellingBackend struct {
+	gasprice.OracleBackend
+	cancel      context.CancelFunc
+	cancelAfter int
+	served      int
+}
+
+func (b *cancellingBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	b.served++
+	if b.served == b.cancelAfter {
+		b.cancel()
+	}
+	return b.OracleBackend.BlockByNumber(ctx, number)
+}
+
+func TestFeeHistoryCancel(t *testing.T) {
+	m := newTestBackend(t)
+	defer m.Close()
+
+	baseApi := jsonrpc.NewBaseApi(nil, kvcache.NewDummy(), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil)
+	tx, err := m.DB.BeginTemporalRo(m.Ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	backend := &cancellingBackend{OracleBackend: jsonrpc.NewGasPriceOracleBackend(tx, baseApi), cancel: cancel, cancelAfter: 5}
+	oracle := gasprice.NewOracle(backend, gaspricecfg.Config{}, nil, log.New())
+
+	_, _, _, _, _, _, err = oracle.FeeHistory(ctx, 30, rpc.LatestBlockNumber, []float64{50})
+	require.ErrorIs(t, err, context.Canceled)
+	// the remaining blocks are not fetched
+	require.Equal(t, 5, backend.served)
+}
+
+func BenchmarkFeeHistory(b *testing.B) {
+	m := newTestBackendWithBlocks(b, 1024)
+	defer m.Close()
+
+	baseApi := jsonrpc.NewBaseApi(nil, kvcache.NewDummy(), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil)
+	tx, err := m.DB.BeginTemporalRo(m.Ctx)
+	require.NoError(b, err)
+	defer tx.Rollback()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// a fresh cache, every iteration computes the whole range
+		oracle := gasprice.NewOracle(jsonrpc.NewGasPriceOracleBackend(tx, baseApi), gaspricecfg.Config{}, jsonrpc.NewGasPriceCache(), log.New())
+		_, reward, _, _, _, _, err := oracle.FeeHistory(context.Background(), 1024, rpc.LatestBlockNumber, []float64{10, 50, 90})
+		require.NoError(b, err)
+		require.Len(b, reward, 1024)
+	}
+}
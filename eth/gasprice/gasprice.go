@@ -32,6 +32,7 @@ import (
 	"github.com/erigontech/erigon-lib/log/v3"
 	"github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon/eth/gasprice/gaspricecfg"
+	"github.com/erigontech/erigon/execution/consensus/misc"
 	"github.com/erigontech/erigon/rpc"
 )
 
@@ -50,20 +51,30 @@ type OracleBackend interface {
 type Cache interface {
 	GetLatest() (common.Hash, *big.Int)
 	SetLatest(hash common.Hash, price *big.Int)
+
+	// GetFeeHistory and SetFeeHistory cache FeeHistory results. The key holds
+	// the hash of the last block, so entries never go stale on reorgs.
+	GetFeeHistory(key FeeHistoryKey) (*FeeHistoryResult, bool)
+	SetFeeHistory(key FeeHistoryKey, result *FeeHistoryResult)
 }
 
 // Oracle recommends gas prices based on the content of recent
 // blocks. Suitable for both light and full clients.
 type Oracle struct {
-	backend     OracleBackend
-	lastHead    common.Hash
-	lastPrice   *big.Int
-	maxPrice    *big.Int
-	ignorePrice *big.Int
-	cache       Cache
+	backend      OracleBackend
+	lastHead     common.Hash
+	defaultPrice *big.Int // suggested when the recent blocks have no transactions
+	maxPrice     *big.Int
+	ignorePrice  *big.Int
+	minTip       *big.Int // optional
+	maxTip       *big.Int // optional
+	cache        Cache
+
+	ignoreTxTypes map[byte]struct{}
 
 	checkBlocks                       int
 	percentile                        int
+	blobFeeHeadroom                   int
 	maxHeaderHistory, maxBlockHistory int
 
 	log log.Logger
@@ -97,16 +108,41 @@ func NewOracle(backend OracleBackend, params gaspricecfg.Config, cache Cache, lo
 		log.Warn("Sanitizing invalid gasprice oracle ignore price", "provided", params.IgnorePrice, "updated", ignorePrice)
 	}
 
+	blobFeeHeadroom := params.BlobFeeHeadroom
+	if blobFeeHeadroom < 0 {
+		blobFeeHeadroom = 0
+		log.Warn("Sanitizing invalid gasprice oracle blob fee headroom", "provided", params.BlobFeeHeadroom, "updated", blobFeeHeadroom)
+	}
+
+	minTip, maxTip := params.MinSuggestedTip, params.MaxSuggestedPrice
+	if minTip != nil && maxTip != nil && minTip.Cmp(maxTip) > 0 {
+		log.Warn("Sanitizing invalid gasprice oracle suggestion bounds", "min", minTip, "max", maxTip, "updated", maxTip)
+		minTip = maxTip
+	}
+	if minTip != nil && minTip.Cmp(maxPrice) > 0 {
+		log.Warn("Sanitizing invalid gasprice oracle minimum tip", "provided", minTip, "updated", maxPrice)
+		minTip = maxPrice
+	}
+
+	ignoreTxTypes := make(map[byte]struct{}, len(params.IgnoreTxTypes))
+	for _, txType := range params.IgnoreTxTypes {
+		ignoreTxTypes[txType] = struct{}{}
+	}
+
 	setBorDefaultGpoIgnorePrice(backend.ChainConfig(), params, log)
 
 	return &Oracle{
 		backend:          backend,
-		lastPrice:        params.Default,
+		defaultPrice:     params.Default,
 		maxPrice:         maxPrice,
 		ignorePrice:      ignorePrice,
+		minTip:           minTip,
+		maxTip:           maxTip,
 		checkBlocks:      blocks,
 		percentile:       percent,
+		blobFeeHeadroom:  blobFeeHeadroom,
 		cache:            cache,
+		ignoreTxTypes:    ignoreTxTypes,
 		maxHeaderHistory: params.MaxHeaderHistory,
 		maxBlockHistory:  params.MaxBlockHistory,
 		log:              log,
@@ -118,6 +154,25 @@ func NewOracle(backend OracleBackend, params gaspricecfg.Config, cache Cache, lo
 // NODE: if caller wants legacy txn SuggestedPrice, we need to add
 // baseFee to the returned bigInt
 func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
+	price, err := oracle.suggestTipCap(ctx)
+	return oracle.clampTip(price), err
+}
+
+// clampTip bounds a suggested tip to the configured range.
+func (oracle *Oracle) clampTip(tip *big.Int) *big.Int {
+	if tip == nil {
+		return nil
+	}
+	if oracle.minTip != nil && tip.Cmp(oracle.minTip) < 0 {
+		return new(big.Int).Set(oracle.minTip)
+	}
+	if oracle.maxTip != nil && tip.Cmp(oracle.maxTip) > 0 {
+		return new(big.Int).Set(oracle.maxTip)
+	}
+	return tip
+}
+
+func (oracle *Oracle) suggestTipCap(ctx context.Context) (*big.Int, error) {
 	latestHead, latestPrice := oracle.cache.GetLatest()
 	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	if err != nil {
@@ -148,6 +203,10 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 		number--
 	}
 	price := latestPrice
+	if txPrices.Len() == 0 && oracle.defaultPrice != nil {
+		// don't suggest a zero tip, which would not propagate, on quiet chains
+		price = new(big.Int).Set(oracle.defaultPrice)
+	}
 	if txPrices.Len() > 0 {
 		// Item with this position needs to be extracted from the sorting heap
 		// so we pop all the items before it
@@ -169,6 +228,63 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 	return price, nil
 }
 
+// NextBaseFee returns the base fee of the block following the head, as given by
+// the EIP-1559 formula from the gas used by the head. It is nil before London.
+func (oracle *Oracle) NextBaseFee(ctx context.Context) (*big.Int, error) {
+	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, nil
+	}
+	chainConfig := oracle.backend.ChainConfig()
+	if !chainConfig.IsLondon(head.Number.Uint64() + 1) {
+		return nil, nil
+	}
+	return misc.CalcBaseFee(chainConfig, head), nil
+}
+
+// SuggestBlobFee returns a max fee per blob gas for a new blob transaction: the
+// highest blob base fee among the recent blocks and the next one, plus the
+// configured headroom. It is nil before Cancun.
+func (oracle *Oracle) SuggestBlobFee(ctx context.Context) (*big.Int, error) {
+	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if head == nil || head.ExcessBlobGas == nil {
+		return nil, nil
+	}
+	chainConfig := oracle.backend.ChainConfig()
+
+	nextBlockTime := head.Time + chainConfig.SecondsPerSlot()
+	maxFee, err := misc.GetBlobGasPrice(chainConfig, misc.CalcExcessBlobGas(chainConfig, head, nextBlockTime), nextBlockTime)
+	if err != nil {
+		return nil, err
+	}
+	header := head
+	for i := 0; i < oracle.checkBlocks && header != nil && header.ExcessBlobGas != nil; i++ {
+		fee, err := misc.GetBlobGasPrice(chainConfig, *header.ExcessBlobGas, header.Time)
+		if err != nil {
+			return nil, err
+		}
+		if fee.Gt(maxFee) {
+			maxFee = fee
+		}
+		if header.Number.Sign() == 0 {
+			break
+		}
+		if header, err = oracle.backend.HeaderByNumber(ctx, rpc.BlockNumber(header.Number.Uint64()-1)); err != nil {
+			return nil, err
+		}
+	}
+
+	suggested := maxFee.ToBig()
+	suggested.Mul(suggested, big.NewInt(int64(100+oracle.blobFeeHeadroom)))
+	return suggested.Div(suggested, big.NewInt(100)), nil
+}
+
 type transactionsByGasPrice struct {
 	txs     []types.Transaction
 	baseFee *uint256.Int
@@ -236,8 +352,12 @@ func (oracle *Oracle) getBlockPrices(ctx context.Context, blockNum uint64, limit
 	}
 
 	blockTxs := block.Transactions()
-	plainTxs := make([]types.Transaction, len(blockTxs))
-	copy(plainTxs, blockTxs)
+	plainTxs := make([]types.Transaction, 0, len(blockTxs))
+	for _, txn := range blockTxs {
+		if !oracle.ignoreTxType(txn) {
+			plainTxs = append(plainTxs, txn)
+		}
+	}
 	var baseFee *uint256.Int
 	if block.BaseFee() == nil {
 		baseFee = nil
@@ -268,6 +388,12 @@ func (oracle *Oracle) getBlockPrices(ctx context.Context, blockNum uint64, limit
 	return nil
 }
 
+// ignoreTxType reports whether txn has one of the configured types to ignore.
+func (oracle *Oracle) ignoreTxType(txn types.Transaction) bool {
+	_, ok := oracle.ignoreTxTypes[txn.Type()]
+	return ok
+}
+
 type sortingHeap []*uint256.Int
 
 func (s sortingHeap) Len() int           { return len(s) }
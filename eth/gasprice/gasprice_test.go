@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
 
 	"github.com/erigontech/erigon-lib/chain"
 	"github.com/erigontech/erigon-lib/common"
@@ -36,28 +37,38 @@ import (
 	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/eth/gasprice"
 	"github.com/erigontech/erigon/eth/gasprice/gaspricecfg"
+	"github.com/erigontech/erigon/execution/consensus/misc"
+	"github.com/erigontech/erigon/rpc"
 	"github.com/erigontech/erigon/rpc/jsonrpc"
 	"github.com/erigontech/erigon/rpc/rpccfg"
 	"github.com/erigontech/erigon/turbo/stages/mock"
 )
 
 func newTestBackend(t *testing.T) *mock.MockSentry {
+	return newTestBackendWithBlocks(t, 32)
+}
 
+// newTestMock returns a mock with only the genesis block, funding m.Address.
+func newTestMock(t testing.TB) *mock.MockSentry {
 	var (
 		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr   = crypto.PubkeyToAddress(key.PublicKey)
 		gspec  = &types.Genesis{
 			Config: chain.TestChainConfig,
-			Alloc:  types.GenesisAlloc{addr: {Balance: big.NewInt(math.MaxInt64)}},
+			Alloc:  types.GenesisAlloc{addr: {Balance: new(big.Int).Lsh(big.NewInt(math.MaxInt64), 10)}}, // enough for the benchmark chains
 		}
-		signer = types.LatestSigner(gspec.Config)
 	)
-	m := mock.MockWithGenesis(t, gspec, key, false)
+	return mock.MockWithGenesis(t, gspec, key, false)
+}
+
+func newTestBackendWithBlocks(t testing.TB, blocks int) *mock.MockSentry {
+	m := newTestMock(t)
+	signer := types.LatestSigner(m.ChainConfig)
 
 	// Generate testing blocks
-	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 32, func(i int, b *core.BlockGen) {
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, blocks, func(i int, b *core.BlockGen) {
 		b.SetCoinbase(common.Address{1})
-		tx, txErr := types.SignTx(types.NewTransaction(b.TxNonce(addr), common.HexToAddress("deadbeef"), uint256.NewInt(100), 21000, uint256.NewInt(uint64(int64(i+1)*common.GWei)), nil), *signer, key)
+		tx, txErr := types.SignTx(types.NewTransaction(b.TxNonce(m.Address), common.HexToAddress("deadbeef"), uint256.NewInt(100), 21000, uint256.NewInt(uint64(int64(i+1)*common.GWei)), nil), *signer, m.Key)
 		if txErr != nil {
 			t.Fatalf("failed to create tx: %v", txErr)
 		}
@@ -66,9 +77,12 @@ func newTestBackend(t *testing.T) *mock.MockSentry {
 	if err != nil {
 		t.Error(err)
 	}
-	// Construct testing chain
-	if err = m.InsertChain(chain); err != nil {
-		t.Error(err)
+	// Construct testing chain, long chains are inserted in chunks the mock downloader can handle
+	const chunk = 128
+	for i := 0; i < chain.Length(); i += chunk {
+		if err = m.InsertChain(chain.Slice(i, min(i+chunk, chain.Length()))); err != nil {
+			t.Fatal(err)
+		}
 	}
 	return m
 }
@@ -99,3 +113,138 @@ func TestSuggestPrice(t *testing.T) {
 		t.Fatalf("Gas price mismatch, want %d, got %d", expect, got)
 	}
 }
+
+func TestSuggestPriceBounds(t *testing.T) {
+	m := newTestBackend(t)
+	baseApi := jsonrpc.NewBaseApi(nil, kvcache.NewDummy(), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil)
+	tx, err := m.DB.BeginTemporalRo(m.Ctx)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	newOracle := func(minTip, maxPrice int64) *gasprice.Oracle {
+		config := gaspricecfg.Config{
+			Blocks:            2,
+			Percentile:        60,
+			Default:           big.NewInt(common.GWei),
+			MinSuggestedTip:   big.NewInt(minTip * common.GWei),
+			MaxSuggestedPrice: big.NewInt(maxPrice * common.GWei),
+		}
+		return gasprice.NewOracle(jsonrpc.NewGasPriceOracleBackend(tx, baseApi), config, jsonrpc.NewGasPriceCache(), log.New())
+	}
+	suggest := func(oracle *gasprice.Oracle) *big.Int {
+		tip, err := oracle.SuggestTipCap(context.Background())
+		require.NoError(t, err)
+		return tip
+	}
+
+	// the sampled tip is 30 gwei, see TestSuggestPrice
+	require.Equal(t, big.NewInt(30*common.GWei), suggest(newOracle(1, 100)))
+	require.Equal(t, big.NewInt(40*common.GWei), suggest(newOracle(40, 100)))
+	require.Equal(t, big.NewInt(20*common.GWei), suggest(newOracle(1, 20)))
+
+	// a cached suggestion is clamped as well
+	oracle := newOracle(40, 100)
+	suggest(oracle)
+	require.Equal(t, big.NewInt(40*common.GWei), suggest(oracle))
+
+	// fee history reports the actual tips
+	_, reward, _, _, _, _, err := oracle.FeeHistory(context.Background(), 1, 10, []float64{50})
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(10*common.GWei), reward[0][0])
+}
+
+func TestSuggestPriceEmptyBlocks(t *testing.T) {
+	backend := &chainBackend{}
+	for i := 0; i < 5; i++ {
+		backend.addBlock()
+	}
+	suggest := func(defaultPrice *big.Int) *big.Int {
+		config := gaspricecfg.Config{Blocks: 2, Percentile: 60, Default: defaultPrice}
+		oracle := gasprice.NewOracle(backend, config, jsonrpc.NewGasPriceCache(), log.New())
+		tip, err := oracle.SuggestTipCap(context.Background())
+		require.NoError(t, err)
+		return tip
+	}
+
+	require.Equal(t, big.NewInt(common.GWei), suggest(big.NewInt(common.GWei)))
+	// without a default, the suggestion falls back to the previous one
+	require.Equal(t, new(big.Int), suggest(nil))
+}
+
+// headerBackend serves a chain of headers, the last one being the head.
+type headerBackend struct {
+	gasprice.OracleBackend
+	config  *chain.Config
+	headers []*types.Header
+}
+
+func (b *headerBackend) HeaderByNumber(_ context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.headers[len(b.headers)-1], nil
+	}
+	for _, header := range b.headers {
+		if header.Number.Int64() == number.Int64() {
+			return header, nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *headerBackend) ChainConfig() *chain.Config { return b.config }
+
+func TestNextBaseFee(t *testing.T) {
+	const gasLimit = 30_000_000
+	baseFee := big.NewInt(common.GWei)
+	nextBaseFee := func(config *chain.Config, gasUsed uint64) *big.Int {
+		head := &types.Header{Number: big.NewInt(10), GasLimit: gasLimit, GasUsed: gasUsed, BaseFee: baseFee}
+		oracle := gasprice.NewOracle(&headerBackend{config: config, headers: []*types.Header{head}}, gaspricecfg.Config{}, jsonrpc.NewGasPriceCache(), log.New())
+		fee, err := oracle.NextBaseFee(context.Background())
+		require.NoError(t, err)
+		return fee
+	}
+
+	target := uint64(gasLimit / 2) // elasticity multiplier of 2
+	require.Equal(t, baseFee, nextBaseFee(chain.AllProtocolChanges, target))
+	require.Equal(t, 1, nextBaseFee(chain.AllProtocolChanges, gasLimit).Cmp(baseFee))
+	require.Equal(t, -1, nextBaseFee(chain.AllProtocolChanges, target/2).Cmp(baseFee))
+	// a full block raises the base fee by 1/8
+	require.Equal(t, big.NewInt(common.GWei*9/8), nextBaseFee(chain.AllProtocolChanges, gasLimit))
+
+	// TestChainConfig has no London fork
+	require.Nil(t, nextBaseFee(chain.TestChainConfig, gasLimit))
+}
+
+func TestSuggestBlobFee(t *testing.T) {
+	config := chain.AllProtocolChanges
+	var headers []*types.Header
+	for i, excess := range []uint64{40_000_000, 20_000_000, 0, 0} {
+		excess := excess
+		blobGasUsed := uint64(0)
+		headers = append(headers, &types.Header{Number: big.NewInt(int64(i)), Time: uint64(i) * 12, ExcessBlobGas: &excess, BlobGasUsed: &blobGasUsed})
+	}
+	blobFee := func(i int) *big.Int {
+		fee, err := misc.GetBlobGasPrice(config, *headers[i].ExcessBlobGas, headers[i].Time)
+		require.NoError(t, err)
+		return fee.ToBig()
+	}
+	suggest := func(blocks, headroom int) *big.Int {
+		oracle := gasprice.NewOracle(&headerBackend{config: config, headers: headers}, gaspricecfg.Config{Blocks: blocks, BlobFeeHeadroom: headroom}, jsonrpc.NewGasPriceCache(), log.New())
+		fee, err := oracle.SuggestBlobFee(context.Background())
+		require.NoError(t, err)
+		return fee
+	}
+
+	// the recent blocks have the minimum blob base fee
+	require.Equal(t, blobFee(3), suggest(2, 0))
+	require.Equal(t, new(big.Int).Mul(blobFee(3), big.NewInt(2)), suggest(2, 100))
+	// sampling more blocks reaches the expensive ones
+	require.Equal(t, blobFee(1), suggest(3, 0))
+	require.Equal(t, blobFee(0), suggest(4, 0))
+	require.Equal(t, new(big.Int).Div(new(big.Int).Mul(blobFee(0), big.NewInt(150)), big.NewInt(100)), suggest(10, 50))
+
+	// no blobs before Cancun
+	oracle := gasprice.NewOracle(&headerBackend{config: config, headers: []*types.Header{{Number: big.NewInt(0)}}}, gaspricecfg.Config{}, jsonrpc.NewGasPriceCache(), log.New())
+	fee, err := oracle.SuggestBlobFee(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, fee)
+}
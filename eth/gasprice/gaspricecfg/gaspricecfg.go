@@ -31,12 +31,26 @@ var (
 	DefaultMaxPrice = big.NewInt(500 * common.GWei)
 )
 
+// DefaultBlobFeeHeadroom doubles the suggested blob fee, so that a blob transaction
+// stays includable after several blocks of blob base fee increases.
+const DefaultBlobFeeHeadroom = 100
+
 type Config struct {
 	Blocks           int
 	Percentile       int
 	MaxHeaderHistory int
 	MaxBlockHistory  int
-	Default          *big.Int `toml:",omitempty"`
+	Default          *big.Int `toml:",omitempty"` // suggested tip when the recent blocks have no transactions
 	MaxPrice         *big.Int `toml:",omitempty"`
 	IgnorePrice      *big.Int `toml:",omitempty"`
+	// MinSuggestedTip and MaxSuggestedPrice clamp the suggested tip, e.g. so
+	// that quiet chains don't suggest a near zero tip. Unset means no bound.
+	MinSuggestedTip   *big.Int `toml:",omitempty"`
+	MaxSuggestedPrice *big.Int `toml:",omitempty"`
+	// BlobFeeHeadroom is the percentage added on top of the recent blob base
+	// fees when suggesting a max fee per blob gas.
+	BlobFeeHeadroom int
+	// IgnoreTxTypes are the types of the transactions left out of the tip samples
+	// and of the fee history rewards, e.g. the system transactions of an L2.
+	IgnoreTxTypes []byte `toml:",omitempty"`
 }
@@ -0,0 +1,29 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"github.com/erigontech/erigon-lib/metrics"
+)
+
+var (
+	feeHistoryCacheHits   = metrics.GetOrCreateCounter(`gasprice_fee_history_cache{result="hit"}`)
+	feeHistoryCacheMisses = metrics.GetOrCreateCounter(`gasprice_fee_history_cache{result="miss"}`)
+	// feeHistoryTook and feeHistoryBlocks only account for the computed (not cached) results
+	feeHistoryTook   = metrics.GetOrCreateSummary("gasprice_fee_history_seconds")
+	feeHistoryBlocks = metrics.GetOrCreateCounter("gasprice_fee_history_blocks")
+)
@@ -57,6 +57,10 @@ type Config struct {
 
 	PingBackDelay time.Duration
 
+	// RecentBondTTL is the time for which a node bonded with by a lookup is not
+	// bonded again by the other lookups.
+	RecentBondTTL time.Duration
+
 	PrivateKeyGenerator func() (*ecdsa.PrivateKey, error)
 
 	TableRevalidateInterval time.Duration
@@ -78,6 +82,9 @@ func (cfg Config) withDefaults(defaultReplyTimeout time.Duration) Config {
 	if cfg.PingBackDelay == 0 {
 		cfg.PingBackDelay = respTimeout
 	}
+	if cfg.RecentBondTTL <= 0 {
+		cfg.RecentBondTTL = recentBondTTL
+	}
 	if cfg.PrivateKeyGenerator == nil {
 		cfg.PrivateKeyGenerator = crypto.GenerateKey
 	}
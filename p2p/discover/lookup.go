@@ -21,6 +21,7 @@ package discover
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/erigontech/erigon-p2p/enode"
@@ -165,8 +166,8 @@ func (it *lookup) query(n *node, reply chan<- []*node) {
 	fails := it.tab.db.FindFails(n.ID(), n.IP())
 	r, err := it.queryfunc(n)
 
-	if err == errClosed {
-		// Avoid recording failures on shutdown.
+	if err == errClosed || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// Avoid recording failures on shutdown, or when the lookup was cancelled.
 		reply <- nil
 		return
 	} else if len(r) == 0 {
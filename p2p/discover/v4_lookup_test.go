@@ -154,6 +154,40 @@ func TestUDPv4_LookupIteratorClose(t *testing.T) {
 	}
 }
 
+// TestUDPv4_RandomNodesN checks that the iterator of RandomNodesN yields the
+// requested number of distinct nodes, and then ends.
+func TestUDPv4_RandomNodesN(t *testing.T) {
+	t.Parallel()
+	logger := log.New()
+	test := newUDPTest(t, logger)
+	defer test.close()
+
+	// Seed table with initial nodes.
+	bootnodes := make([]*node, len(lookupTestnet.dists[256]))
+	for i := range lookupTestnet.dists[256] {
+		bootnodes[i] = wrapNode(lookupTestnet.node(256, i))
+	}
+	fillTable(test.table, bootnodes)
+	go serveTestnet(test, lookupTestnet)
+
+	const n = 5
+	it := test.udp.RandomNodesN(n)
+	seen := make(map[enode.ID]bool)
+	for it.Next() {
+		if seen[it.Node().ID()] {
+			t.Fatalf("iterator returned duplicate node %v", it.Node().ID())
+		}
+		seen[it.Node().ID()] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("iterator returned %d nodes, want %d", len(seen), n)
+	}
+	if it.Next() {
+		t.Error("Next() == true after the limit")
+	}
+	it.Close()
+}
+
 func serveTestnet(test *udpTest, testnet *preminedTestnet) {
 	for done := false; !done; {
 		done = test.waitPacketOut(func(p v4wire.Packet, to *net.UDPAddr, hash []byte) {
@@ -62,6 +62,7 @@ const (
 	respTimeout    = 750 * time.Millisecond
 	expiration     = 20 * time.Second
 	bondExpiration = 24 * time.Hour
+	recentBondTTL  = 10 * time.Second // Default time for which a node just bonded with is not bonded again
 
 	maxFindnodeFailures = 5                // nodes exceeding this limit are dropped
 	ntpFailureThreshold = 32               // Continuous timeouts after which to check NTP
@@ -87,6 +88,12 @@ type UDPv4 struct {
 	closeOnce   sync.Once
 	wg          sync.WaitGroup
 
+	// queries tracks the running queries, which CloseGracefully lets finish. No query
+	// is started once draining is set.
+	queriesMutex sync.Mutex
+	queries      sync.WaitGroup
+	draining     bool
+
 	addReplyMatcher      chan *replyMatcher
 	addReplyMatcherMutex sync.Mutex
 
@@ -101,6 +108,12 @@ type UDPv4 struct {
 	unsolicitedNodes    *lru.Cache[enode.ID, *enode.Node]
 	privateKeyGenerator func() (*ecdsa.PrivateKey, error)
 
+	// recentBonds holds the nodes bonded with in the last recentBondTTL, so that
+	// concurrent lookups do not bond with them again.
+	recentBondsMutex sync.Mutex
+	recentBonds      map[enode.ID]*bondAttempt
+	recentBondTTL    time.Duration
+
 	trace bool
 }
 
@@ -179,6 +192,8 @@ func ListenV4(ctx context.Context, protocol string, c UDPConn, ln *enode.LocalNo
 		errors:              map[string]uint{},
 		unsolicitedNodes:    unsolicitedNodes,
 		privateKeyGenerator: cfg.PrivateKeyGenerator,
+		recentBonds:         map[enode.ID]*bondAttempt{},
+		recentBondTTL:       cfg.RecentBondTTL,
 	}
 
 	tab, err := newTable(t, protocol, ln.Database(), cfg.Bootnodes, cfg.TableRevalidateInterval, cfg.Log)
@@ -231,29 +246,76 @@ func (t *UDPv4) Close() {
 	})
 }
 
+// CloseGracefully stops accepting new queries, waits up to timeout for the running
+// ones to complete, and then shuts down like Close. Queries started after it was
+// called fail with errClosed.
+func (t *UDPv4) CloseGracefully(timeout time.Duration) {
+	t.queriesMutex.Lock()
+	t.draining = true
+	t.queriesMutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		t.queries.Wait()
+		close(drained)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+	case <-timer.C:
+		t.log.Debug("Closing discovery with queries running", "timeout", timeout)
+	}
+	t.Close()
+}
+
+// startQuery registers a query, unless CloseGracefully was called in which case
+// it returns false. Registered queries must call t.queries.Done when complete.
+func (t *UDPv4) startQuery() bool {
+	t.queriesMutex.Lock()
+	defer t.queriesMutex.Unlock()
+	if t.draining {
+		return false
+	}
+	t.queries.Add(1)
+	return true
+}
+
 // Resolve searches for a specific node with the given ID and tries to get the most recent
 // version of the node record for it. It returns n if the node could not be resolved.
 func (t *UDPv4) Resolve(n *enode.Node) *enode.Node {
+	return t.ResolveContext(context.Background(), n)
+}
+
+// ResolveContext is like Resolve, but gives up when ctx is done, interrupting the
+// running request or lookup, and returns the most recent version of n known so far.
+func (t *UDPv4) ResolveContext(ctx context.Context, n *enode.Node) *enode.Node {
+	ctx, cancel := t.withCloseCtx(ctx)
+	defer cancel()
+
 	// Try asking directly. This works if the node is still responding on the endpoint we have.
-	if rn, err := t.RequestENR(n); err == nil {
+	if rn, err := t.requestENR(ctx, n); err == nil {
 		return rn
 	}
 	// Check table for the ID, we might have a newer version there.
 	if intable := t.tab.getNode(n.ID()); intable != nil && intable.Seq() > n.Seq() {
 		n = intable
-		if rn, err := t.RequestENR(n); err == nil {
+		if rn, err := t.requestENR(ctx, n); err == nil {
 			return rn
 		}
 	}
+	if ctx.Err() != nil {
+		return n
+	}
 	// Otherwise perform a network lookup.
 	var key enode.Secp256k1
 	if n.Load(&key) != nil {
 		return n // no secp256k1 key
 	}
-	result := t.LookupPubkey((*ecdsa.PublicKey)(&key))
+	result := t.lookupPubkey(ctx, (*ecdsa.PublicKey)(&key))
 	for _, rn := range result {
 		if rn.ID() == n.ID() {
-			if rn1, err := t.RequestENR(rn); err == nil {
+			if rn1, err := t.requestENR(ctx, rn); err == nil {
 				return rn1
 			}
 		}
@@ -261,6 +323,16 @@ func (t *UDPv4) Resolve(n *enode.Node) *enode.Node {
 	return n
 }
 
+// withCloseCtx returns a context done when either ctx is done or t is closed.
+func (t *UDPv4) withCloseCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(t.closeCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
 func (t *UDPv4) ourEndpoint() v4wire.Endpoint {
 	n := t.Self()
 	a := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
@@ -275,6 +347,11 @@ func (t *UDPv4) Ping(n *enode.Node) error {
 
 // ping sends a ping message to the given node and waits for a reply.
 func (t *UDPv4) ping(n *enode.Node) (seq uint64, err error) {
+	if !t.startQuery() {
+		return 0, errClosed
+	}
+	defer t.queries.Done()
+
 	rm := t.sendPing(n.ID(), &net.UDPAddr{IP: n.IP(), Port: n.UDP()}, nil)
 	if err = <-rm.errc; err == nil {
 		seq = rm.reply.(*v4wire.Pong).ENRSeq
@@ -319,12 +396,21 @@ func (t *UDPv4) makePing(toaddr *net.UDPAddr) *v4wire.Ping {
 
 // LookupPubkey finds the closest nodes to the given public key.
 func (t *UDPv4) LookupPubkey(key *ecdsa.PublicKey) []*enode.Node {
+	return t.lookupPubkey(t.closeCtx, key)
+}
+
+// lookupPubkey is LookupPubkey, ending early when ctx is done.
+func (t *UDPv4) lookupPubkey(ctx context.Context, key *ecdsa.PublicKey) []*enode.Node {
 	if t.tab.len() == 0 {
 		// All nodes were dropped, refresh. The very first query will hit this
 		// case and run the bootstrapping logic.
-		<-t.tab.refresh()
+		select {
+		case <-t.tab.refresh():
+		case <-ctx.Done():
+			return nil
+		}
 	}
-	return t.newLookup(t.closeCtx, key).run()
+	return t.newLookup(ctx, key).run()
 }
 
 // RandomNodes is an iterator yielding nodes from a random walk of the DHT.
@@ -332,6 +418,12 @@ func (t *UDPv4) RandomNodes() enode.Iterator {
 	return newLookupIterator(t.closeCtx, t.newRandomLookup)
 }
 
+// RandomNodesN is like RandomNodes, but the iterator ends after yielding n distinct
+// nodes, stopping the random walk.
+func (t *UDPv4) RandomNodesN(n int) enode.Iterator {
+	return enode.LimitNodes(t.RandomNodes(), n)
+}
+
 // lookupRandom implements transport.
 func (t *UDPv4) lookupRandom() []*enode.Node {
 	return t.newRandomLookup(t.closeCtx).run()
@@ -356,7 +448,7 @@ func (t *UDPv4) newLookup(ctx context.Context, targetKey *ecdsa.PublicKey) *look
 	target := enode.PubkeyEncoded(targetKeyEnc).ID()
 
 	it := newLookup(ctx, t.tab, target, func(n *node) ([]*node, error) {
-		return t.findnode(n.ID(), n.addr(), targetKeyEnc)
+		return t.findnode(ctx, n.ID(), n.addr(), targetKeyEnc)
 	})
 	return it
 }
@@ -365,12 +457,21 @@ func (t *UDPv4) newLookup(ctx context.Context, targetKey *ecdsa.PublicKey) *look
 // the node has sent up to bucketSize neighbors or a respTimeout has passed.
 func (t *UDPv4) FindNode(toNode *enode.Node, targetKey *ecdsa.PublicKey) ([]*enode.Node, error) {
 	targetKeyEnc := v4wire.EncodePubkey(targetKey)
-	nodes, err := t.findnode(toNode.ID(), wrapNode(toNode).addr(), targetKeyEnc)
+	nodes, err := t.findnode(t.closeCtx, toNode.ID(), wrapNode(toNode).addr(), targetKeyEnc)
 	return unwrapNodes(nodes), err
 }
 
-func (t *UDPv4) findnode(toid enode.ID, toaddr *net.UDPAddr, target v4wire.Pubkey) ([]*node, error) {
-	t.ensureBond(toid, toaddr)
+// findnode sends a findnode request and waits for the neighbors, or until ctx is done.
+func (t *UDPv4) findnode(ctx context.Context, toid enode.ID, toaddr *net.UDPAddr, target v4wire.Pubkey) ([]*node, error) {
+	if !t.startQuery() {
+		return nil, errClosed
+	}
+	defer t.queries.Done()
+
+	t.ensureBond(ctx, toid, toaddr)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Add a matcher for 'neighbours' replies to the pending reply queue. The matcher is
 	// active until enough nodes have been received.
@@ -406,7 +507,12 @@ func (t *UDPv4) findnode(toid enode.ID, toaddr *net.UDPAddr, target v4wire.Pubke
 		return nodes, err
 	}
 
-	err = <-rm.errc
+	select {
+	case err = <-rm.errc:
+	case <-ctx.Done():
+		// the matcher may still add to nodes until it times out
+		return nil, ctx.Err()
+	}
 	if errors.Is(err, errTimeout) && rm.reply != nil {
 		err = nil
 	}
@@ -415,8 +521,21 @@ func (t *UDPv4) findnode(toid enode.ID, toaddr *net.UDPAddr, target v4wire.Pubke
 
 // RequestENR sends enrRequest to the given node and waits for a response.
 func (t *UDPv4) RequestENR(n *enode.Node) (*enode.Node, error) {
+	return t.requestENR(t.closeCtx, n)
+}
+
+// requestENR is RequestENR, giving up when ctx is done.
+func (t *UDPv4) requestENR(ctx context.Context, n *enode.Node) (*enode.Node, error) {
+	if !t.startQuery() {
+		return nil, errClosed
+	}
+	defer t.queries.Done()
+
 	addr := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
-	t.ensureBond(n.ID(), addr)
+	t.ensureBond(ctx, n.ID(), addr)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	req := &v4wire.ENRRequest{
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
@@ -438,7 +557,12 @@ func (t *UDPv4) RequestENR(n *enode.Node) (*enode.Node, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err = <-rm.errc; err != nil {
+	select {
+	case err = <-rm.errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
 		return nil, err
 	}
 	// Verify the response record.
@@ -517,6 +641,9 @@ func (t *UDPv4) loop() {
 		<-timeout.C // ignore first timeout
 		defer timeout.Stop()
 
+		bondsExpiry := time.NewTicker(t.recentBondTTL)
+		defer bondsExpiry.Stop()
+
 		resetTimeout := func() {
 			mutex.Lock()
 			defer mutex.Unlock()
@@ -549,6 +676,9 @@ func (t *UDPv4) loop() {
 			case <-t.closeCtx.Done():
 				return
 
+			case now := <-bondsExpiry.C:
+				t.expireBonds(now)
+
 			case now := <-timeout.C:
 				func() {
 					mutex.Lock()
@@ -779,13 +909,88 @@ func (t *UDPv4) checkBond(id enode.ID, ip net.IP) bool {
 
 // ensureBond solicits a ping from a node if we haven't seen a ping from it for a while.
 // This ensures there is a valid endpoint proof on the remote end.
-func (t *UDPv4) ensureBond(toid enode.ID, toaddr *net.UDPAddr) {
+// A node bonded with less than recentBondTTL ago, or being bonded with, is not
+// pinged again. It returns early when ctx is done.
+func (t *UDPv4) ensureBond(ctx context.Context, toid enode.ID, toaddr *net.UDPAddr) {
 	tooOld := time.Since(t.db.LastPingReceived(toid, toaddr.IP)) > bondExpiration
-	if tooOld || t.db.FindFails(toid, toaddr.IP) > maxFindnodeFailures {
-		rm := t.sendPing(toid, toaddr, nil)
-		<-rm.errc
-		// Wait for them to ping back and process our pong.
-		time.Sleep(t.pingBackDelay)
+	if !tooOld && t.db.FindFails(toid, toaddr.IP) <= maxFindnodeFailures {
+		return
+	}
+	b, ok := t.startBond(toid)
+	if !ok {
+		// Another lookup bonded with the node, wait for it to complete.
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+		}
+		return
+	}
+	rm := t.sendPing(toid, toaddr, nil)
+	var err error
+	select {
+	case err = <-rm.errc:
+	case <-ctx.Done():
+		t.endBond(toid, b, false)
+		return
+	}
+	if err != nil {
+		// The node did not answer, the next lookup tries again.
+		t.endBond(toid, b, false)
+		return
+	}
+	// Wait for them to ping back and process our pong.
+	timer := time.NewTimer(t.pingBackDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		t.endBond(toid, b, false)
+		return
+	}
+	t.endBond(toid, b, true)
+}
+
+// bondAttempt is a bond with a node, started by ensureBond.
+type bondAttempt struct {
+	done    chan struct{} // closed when the bond completes
+	expires time.Time     // zero until the bond completes
+}
+
+// startBond records a bond with the given node and returns it, unless the node was
+// recently bonded with, in which case it returns the recent bond and false.
+func (t *UDPv4) startBond(id enode.ID) (*bondAttempt, bool) {
+	t.recentBondsMutex.Lock()
+	defer t.recentBondsMutex.Unlock()
+
+	if b, ok := t.recentBonds[id]; ok && (b.expires.IsZero() || time.Now().Before(b.expires)) {
+		return b, false
+	}
+	b := &bondAttempt{done: make(chan struct{})}
+	t.recentBonds[id] = b
+	return b, true
+}
+
+// endBond marks a bond started by startBond as completed, or forgets it if it was
+// interrupted before the node replied, so that the next lookup bonds again.
+func (t *UDPv4) endBond(id enode.ID, b *bondAttempt, completed bool) {
+	t.recentBondsMutex.Lock()
+	if completed {
+		b.expires = time.Now().Add(t.recentBondTTL)
+	} else if t.recentBonds[id] == b {
+		delete(t.recentBonds, id)
+	}
+	t.recentBondsMutex.Unlock()
+	close(b.done)
+}
+
+// expireBonds forgets the completed bonds older than recentBondTTL, see loop.
+func (t *UDPv4) expireBonds(now time.Time) {
+	t.recentBondsMutex.Lock()
+	defer t.recentBondsMutex.Unlock()
+	for id, b := range t.recentBonds {
+		if !b.expires.IsZero() && !now.Before(b.expires) {
+			delete(t.recentBonds, id)
+		}
 	}
 }
 
@@ -31,6 +31,7 @@ import (
 	"net"
 	"reflect"
 	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -139,6 +140,26 @@ func (test *udpTest) packetInFrom(wantError error, key *ecdsa.PrivateKey, addr *
 	}
 }
 
+// handles a reply packet like packetIn. Reply matchers are added asynchronously, so
+// it retries while the reply is unsolicited.
+func (test *udpTest) replyIn(data v4wire.Packet) {
+	test.t.Helper()
+
+	enc, _, err := v4wire.Encode(test.remotekey, data)
+	if err != nil {
+		test.t.Errorf("%s encode error: %v", data.Name(), err)
+	}
+	test.sent = append(test.sent, enc)
+
+	deadline := time.Now().Add(time.Second)
+	for err = test.udp.handlePacket(test.remoteaddr, enc); err == errUnsolicitedReply && time.Now().Before(deadline); err = test.udp.handlePacket(test.remoteaddr, enc) {
+		time.Sleep(time.Millisecond)
+	}
+	if err != nil {
+		test.t.Errorf("handlePacket error: %q", err)
+	}
+}
+
 // waits for a packet to be sent by the transport.
 // validate should have type func(X, *net.UDPAddr, []byte), where X is a packet type.
 func (test *udpTest) waitPacketOut(validate interface{}) (closed bool) {
@@ -285,7 +306,7 @@ func TestUDPv4_findnodeTimeout(t *testing.T) {
 	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
 	toid := enode.ID{1, 2, 3, 4}
 	target := v4wire.Pubkey{4, 5, 6, 7}
-	result, err := test.udp.findnode(toid, toaddr, target)
+	result, err := test.udp.findnode(context.Background(), toid, toaddr, target)
 	if err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
@@ -294,6 +315,67 @@ func TestUDPv4_findnodeTimeout(t *testing.T) {
 	}
 }
 
+// This test checks that a node contacted by two overlapping lookups is bonded once.
+func TestUDPv4_ensureBondOnce(t *testing.T) {
+	logger := log.New()
+	test := newUDPTestContext(contextWithReplyTimeout(context.Background(), time.Second), t, logger)
+	defer test.close()
+
+	remoteID := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			test.udp.ensureBond(context.Background(), remoteID, test.remoteaddr)
+		}()
+	}
+	test.waitPacketOut(func(p *v4wire.Ping, to *net.UDPAddr, hash []byte) {
+		test.replyIn(&v4wire.Pong{Expiration: futureExp, ReplyTok: hash})
+	})
+	wg.Wait()
+
+	// A later lookup within the TTL does not bond again either.
+	test.udp.ensureBond(context.Background(), remoteID, test.remoteaddr)
+	if n := len(test.pipe.queue); n != 0 {
+		t.Fatalf("%d more packets sent, want none", n)
+	}
+
+	// The bond is forgotten once expired.
+	test.udp.expireBonds(time.Now().Add(test.udp.recentBondTTL))
+	test.udp.recentBondsMutex.Lock()
+	defer test.udp.recentBondsMutex.Unlock()
+	if n := len(test.udp.recentBonds); n != 0 {
+		t.Fatalf("%d recent bonds left, want none", n)
+	}
+}
+
+func TestUDPv4_ensureBondTimeout(t *testing.T) {
+	logger := log.New()
+	test := newUDPTestContext(contextWithReplyTimeout(context.Background(), 50*time.Millisecond), t, logger)
+	defer test.close()
+
+	// The ping is never answered, so the bond is not recorded.
+	remoteID := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
+	test.udp.ensureBond(context.Background(), remoteID, test.remoteaddr)
+	test.udp.recentBondsMutex.Lock()
+	n := len(test.udp.recentBonds)
+	test.udp.recentBondsMutex.Unlock()
+	if n != 0 {
+		t.Fatalf("%d recent bonds after a ping timeout, want none", n)
+	}
+
+	// Neither is it once ctx is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.udp.ensureBond(ctx, remoteID, test.remoteaddr)
+	test.udp.recentBondsMutex.Lock()
+	defer test.udp.recentBondsMutex.Unlock()
+	if n := len(test.udp.recentBonds); n != 0 {
+		t.Fatalf("%d recent bonds after ctx is done, want none", n)
+	}
+}
+
 func TestUDPv4_findnode(t *testing.T) {
 	logger := log.New()
 	test := newUDPTest(t, logger)
@@ -364,7 +446,7 @@ func TestUDPv4_findnodeMultiReply(t *testing.T) {
 	resultc, errc := make(chan []*node), make(chan error)
 	go func() {
 		rid := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
-		ns, err := test.udp.findnode(rid, test.remoteaddr, testTarget)
+		ns, err := test.udp.findnode(context.Background(), rid, test.remoteaddr, testTarget)
 		if err != nil && len(ns) == 0 {
 			errc <- err
 		} else {
@@ -408,6 +490,124 @@ func TestUDPv4_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+// This test checks that CloseGracefully lets a running findnode complete, and
+// refuses new queries.
+func TestUDPv4_closeGracefully(t *testing.T) {
+	logger := log.New()
+	test := newUDPTestContext(contextWithReplyTimeout(context.Background(), time.Second), t, logger)
+	defer test.close()
+
+	rid := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
+	test.table.db.UpdateLastPingReceived(rid, test.remoteaddr.IP, time.Now())
+
+	errc := make(chan error, 1)
+	go func() {
+		ns, err := test.udp.findnode(context.Background(), rid, test.remoteaddr, testTarget)
+		if err == nil && len(ns) != 1 {
+			err = fmt.Errorf("got %d nodes, want 1", len(ns))
+		}
+		errc <- err
+	}()
+	test.waitPacketOut(func(p *v4wire.Findnode, to *net.UDPAddr, hash []byte) {})
+
+	closed := make(chan struct{})
+	go func() {
+		test.udp.CloseGracefully(5 * time.Second)
+		close(closed)
+	}()
+	for draining := false; !draining; {
+		test.udp.queriesMutex.Lock()
+		draining = test.udp.draining
+		test.udp.queriesMutex.Unlock()
+	}
+	if err := test.udp.Ping(enode.NewV4(&test.remotekey.PublicKey, test.remoteaddr.IP, 0, test.remoteaddr.Port)); err != errClosed {
+		t.Errorf("ping while draining: got %v, want %v", err, errClosed)
+	}
+
+	// reply to the running findnode, which must complete without error.
+	n := wrapNode(enode.MustParse("enode://ba85011c70bcc5c04d8607d3a0ed29aa6179c092cbdda10d5d32684fb33ed01bd94f588ca8f91ac48318087dcb02eaf36773a7a453f0eedd6742af668097b29c@10.0.1.16:30303?discport=30304"))
+	test.packetIn(nil, &v4wire.Neighbors{Expiration: futureExp, Nodes: []v4wire.Node{nodeToRPC(n)}})
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("findnode error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("findnode did not return within 5 seconds")
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CloseGracefully did not return after the findnode completed")
+	}
+}
+
+// This test checks that ResolveContext returns promptly when its context is
+// cancelled, during the ENR request and during the lookup.
+func TestUDPv4_resolveContextCancel(t *testing.T) {
+	t.Run("ENRRequest", func(t *testing.T) {
+		test, remote := newResolveTest(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resultc := test.resolve(ctx, remote)
+		test.waitPacketOut(func(p *v4wire.ENRRequest, to *net.UDPAddr, hash []byte) {})
+		cancel()
+		checkResolvePrompt(t, resultc, remote)
+	})
+	t.Run("Lookup", func(t *testing.T) {
+		test, remote := newResolveTest(t)
+		bootnodes := make([]*node, len(lookupTestnet.dists[256]))
+		for i := range lookupTestnet.dists[256] {
+			bootnodes[i] = wrapNode(lookupTestnet.node(256, i))
+		}
+		fillTable(test.table, bootnodes)
+
+		// The record of the reply is valid but not the one of remote, so the
+		// resolution falls back to a lookup, which first bonds with the table nodes.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resultc := test.resolve(ctx, remote)
+		test.waitPacketOut(func(p *v4wire.ENRRequest, to *net.UDPAddr, hash []byte) {
+			var record enr.Record
+			if err := enode.SignV4(&record, newkey()); err != nil {
+				t.Fatal(err)
+			}
+			test.replyIn(&v4wire.ENRResponse{ReplyTok: hash, Record: record})
+		})
+		test.waitPacketOut(func(p *v4wire.Ping, to *net.UDPAddr, hash []byte) {})
+		cancel()
+		checkResolvePrompt(t, resultc, remote)
+	})
+}
+
+func newResolveTest(t *testing.T) (*udpTest, *enode.Node) {
+	logger := log.New()
+	test := newUDPTestContext(contextWithReplyTimeout(context.Background(), 10*time.Second), t, logger)
+	t.Cleanup(test.close)
+
+	rid := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
+	test.table.db.UpdateLastPingReceived(rid, test.remoteaddr.IP, time.Now())
+	return test, enode.NewV4(&test.remotekey.PublicKey, test.remoteaddr.IP, 0, test.remoteaddr.Port)
+}
+
+func (test *udpTest) resolve(ctx context.Context, n *enode.Node) <-chan *enode.Node {
+	resultc := make(chan *enode.Node, 1)
+	go func() { resultc <- test.udp.ResolveContext(ctx, n) }()
+	return resultc
+}
+
+func checkResolvePrompt(t *testing.T, resultc <-chan *enode.Node, want *enode.Node) {
+	t.Helper()
+	select {
+	case n := <-resultc:
+		if n != want {
+			t.Errorf("wrong node resolved: got %v, want %v", n, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ResolveContext did not return after cancel")
+	}
+}
+
 // This test checks that reply matching of pong verifies the ping hash.
 func TestUDPv4_pingMatch(t *testing.T) {
 	logger := log.New()
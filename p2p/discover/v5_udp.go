@@ -290,6 +290,12 @@ func (t *UDPv5) RandomNodes() enode.Iterator {
 	return newLookupIterator(t.closeCtx, t.newRandomLookup)
 }
 
+// RandomNodesN is like RandomNodes, but the iterator ends after yielding n distinct
+// nodes, stopping the random walk.
+func (t *UDPv5) RandomNodesN(n int) enode.Iterator {
+	return enode.LimitNodes(t.RandomNodes(), n)
+}
+
 // Lookup performs a recursive lookup for the given target.
 // It returns the closest nodes to target.
 func (t *UDPv5) Lookup(target enode.ID) []*enode.Node {
@@ -128,6 +128,38 @@ func (f *filterIter) Next() bool {
 	return false
 }
 
+// LimitNodes wraps an iterator such that Next returns at most n distinct nodes, skipping
+// the nodes already returned. The wrapped iterator is closed once n nodes were returned,
+// or when it ends.
+func LimitNodes(it Iterator, n int) Iterator {
+	return &limitIter{Iterator: it, limit: n, seen: make(map[ID]struct{}, n)}
+}
+
+type limitIter struct {
+	Iterator
+	limit     int
+	seen      map[ID]struct{}
+	closeOnce sync.Once
+}
+
+func (l *limitIter) Next() bool {
+	for len(l.seen) < l.limit && l.Iterator.Next() {
+		id := l.Node().ID()
+		if _, ok := l.seen[id]; ok {
+			continue
+		}
+		l.seen[id] = struct{}{}
+		return true
+	}
+	l.Close()
+	return false
+}
+
+// Close closes the wrapped iterator, which Next may already have done.
+func (l *limitIter) Close() {
+	l.closeOnce.Do(l.Iterator.Close)
+}
+
 // FairMix aggregates multiple node iterators. The mixer itself is an iterator which ends
 // only when Close is called. Source iterators added via AddSource are removed from the
 // mix when they end.
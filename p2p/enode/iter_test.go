@@ -73,6 +73,45 @@ func TestFilterNodes(t *testing.T) {
 	}
 }
 
+// This test checks that LimitNodes returns n distinct nodes, skipping duplicates,
+// and closes the underlying iterator afterwards.
+func TestLimitNodes(t *testing.T) {
+	iter := &closeCountIter{
+		Iterator: CycleNodes([]*Node{
+			testNode(0, 0),
+			testNode(1, 0),
+			testNode(1, 0),
+			testNode(2, 0),
+		}),
+	}
+	it := LimitNodes(iter, 3)
+	var nodes []*Node
+	for it.Next() {
+		nodes = append(nodes, it.Node())
+	}
+	checkNodes(t, nodes, 3)
+	// neither more calls to Next nor Close close it again
+	it.Next()
+	it.Close()
+	if iter.closed != 1 {
+		t.Fatalf("underlying iterator closed %d times, want 1", iter.closed)
+	}
+	if iter.Next() {
+		t.Fatal("underlying iterator not ended after the limit")
+	}
+}
+
+// This test checks that LimitNodes ends with the underlying iterator when it has
+// less than n nodes.
+func TestLimitNodesShort(t *testing.T) {
+	it := LimitNodes(IterNodes([]*Node{testNode(0, 0), testNode(1, 0)}), 3)
+	var nodes []*Node
+	for it.Next() {
+		nodes = append(nodes, it.Node())
+	}
+	checkNodes(t, nodes, 2)
+}
+
 func checkNodes(t *testing.T, nodes []*Node, wantLen int) {
 	if len(nodes) != wantLen {
 		t.Errorf("slice has %d nodes, want %d", len(nodes), wantLen)
@@ -294,3 +333,14 @@ func (it *callCountIter) Next() bool {
 	it.count++
 	return it.Iterator.Next()
 }
+
+// closeCountIter counts calls to Close.
+type closeCountIter struct {
+	Iterator
+	closed int
+}
+
+func (it *closeCountIter) Close() {
+	it.closed++
+	it.Iterator.Close()
+}
@@ -134,13 +134,13 @@ func (args *CallArgs) ToMessage(globalGasCap uint64, baseFee *uint256.Int) (*typ
 				gasPrice = math.U256Min(new(uint256.Int).Add(gasTipCap, baseFee), gasFeeCap)
 			}
 		}
-		if args.MaxFeePerBlobGas != nil {
-			blobFee, overflow := uint256.FromBig(args.MaxFeePerBlobGas.ToInt())
-			if overflow {
-				return nil, errors.New("args.MaxFeePerBlobGas higher than 2^256-1")
-			}
-			maxFeePerBlobGas = blobFee
+	}
+	if args.MaxFeePerBlobGas != nil {
+		blobFee, overflow := uint256.FromBig(args.MaxFeePerBlobGas.ToInt())
+		if overflow {
+			return nil, errors.New("args.MaxFeePerBlobGas higher than 2^256-1")
 		}
+		maxFeePerBlobGas = blobFee
 	}
 
 	value := new(uint256.Int)
@@ -193,9 +193,36 @@ func (args *CallArgs) ToTransaction(globalGasCap uint64, baseFee *uint256.Int) (
 	if err != nil {
 		return nil, err
 	}
+	// the message leaves the nonce to the state, the transaction carries the given one
+	nonce := msg.Nonce()
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	}
 
 	var tx types.Transaction
 	switch {
+	case args.BlobVersionedHashes != nil:
+		al := types.AccessList{}
+		if args.AccessList != nil {
+			al = *args.AccessList
+		}
+		tx = &types.BlobTx{
+			DynamicFeeTransaction: types.DynamicFeeTransaction{
+				CommonTx: types.CommonTx{
+					Nonce:    nonce,
+					GasLimit: msg.Gas(),
+					To:       args.To,
+					Value:    msg.Value(),
+					Data:     msg.Data(),
+				},
+				ChainID:    chainID,
+				FeeCap:     msg.FeeCap(),
+				TipCap:     msg.TipCap(),
+				AccessList: al,
+			},
+			MaxFeePerBlobGas:    msg.MaxFeePerBlobGas(),
+			BlobVersionedHashes: args.BlobVersionedHashes,
+		}
 	case args.MaxFeePerGas != nil:
 		al := types.AccessList{}
 		if args.AccessList != nil {
@@ -203,7 +230,7 @@ func (args *CallArgs) ToTransaction(globalGasCap uint64, baseFee *uint256.Int) (
 		}
 		tx = &types.DynamicFeeTransaction{
 			CommonTx: types.CommonTx{
-				Nonce:    msg.Nonce(),
+				Nonce:    nonce,
 				GasLimit: msg.Gas(),
 				To:       args.To,
 				Value:    msg.Value(),
@@ -222,7 +249,7 @@ func (args *CallArgs) ToTransaction(globalGasCap uint64, baseFee *uint256.Int) (
 		tx = &types.AccessListTx{
 			LegacyTx: types.LegacyTx{
 				CommonTx: types.CommonTx{
-					Nonce:    msg.Nonce(),
+					Nonce:    nonce,
 					GasLimit: msg.Gas(),
 					To:       args.To,
 					Value:    msg.Value(),
@@ -236,7 +263,7 @@ func (args *CallArgs) ToTransaction(globalGasCap uint64, baseFee *uint256.Int) (
 	default:
 		tx = &types.LegacyTx{
 			CommonTx: types.CommonTx{
-				Nonce:    msg.Nonce(),
+				Nonce:    nonce,
 				GasLimit: msg.Gas(),
 				To:       args.To,
 				Value:    msg.Value(),
@@ -42,7 +42,10 @@ import (
 	"github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon-lib/types/accounts"
 	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/eth/ethconfig"
 	"github.com/erigontech/erigon/eth/filters"
+	"github.com/erigontech/erigon/eth/gasprice"
+	"github.com/erigontech/erigon/eth/gasprice/gaspricecfg"
 	"github.com/erigontech/erigon/execution/consensus"
 	"github.com/erigontech/erigon/execution/consensus/misc"
 	"github.com/erigontech/erigon/polygon/bor/borcfg"
@@ -400,14 +403,23 @@ type APIImpl struct {
 	AllowUnprotectedTxs         bool
 	MaxGetProofRewindBlockCount int
 	SubscribeLogsChannelSize    int
+	MaxBundles                  int                // Maximum number of bundles in an eth_callMany request
+	MaxTxPerBundle              int                // Maximum number of transactions in an eth_callMany bundle
+	GPO                         gaspricecfg.Config // Settings of the gas price oracle
 	logger                      log.Logger
 }
 
 // NewEthAPI returns APIImpl instance
-func NewEthAPI(base *BaseAPI, db kv.TemporalRoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, mining txpool.MiningClient, gascap uint64, feecap float64, returnDataLimit int, allowUnprotectedTxs bool, maxGetProofRewindBlockCount int, subscribeLogsChannelSize int, logger log.Logger) *APIImpl {
+func NewEthAPI(base *BaseAPI, db kv.TemporalRoDB, eth rpchelper.ApiBackend, txPool txpool.TxpoolClient, mining txpool.MiningClient, gascap uint64, feecap float64, returnDataLimit int, allowUnprotectedTxs bool, maxGetProofRewindBlockCount int, subscribeLogsChannelSize int, maxBundles int, maxTxPerBundle int, logger log.Logger) *APIImpl {
 	if gascap == 0 {
 		gascap = uint64(math.MaxUint64 / 2)
 	}
+	if maxBundles == 0 {
+		maxBundles = DefaultCallManyMaxBundles
+	}
+	if maxTxPerBundle == 0 {
+		maxTxPerBundle = DefaultCallManyMaxTxPerBundle
+	}
 
 	if base.useBridgeReader {
 		logger.Info("starting rpc with polygon bridge")
@@ -426,6 +438,9 @@ func NewEthAPI(base *BaseAPI, db kv.TemporalRoDB, eth rpchelper.ApiBackend, txPo
 		ReturnDataLimit:             returnDataLimit,
 		MaxGetProofRewindBlockCount: maxGetProofRewindBlockCount,
 		SubscribeLogsChannelSize:    subscribeLogsChannelSize,
+		MaxBundles:                  maxBundles,
+		MaxTxPerBundle:              maxTxPerBundle,
+		GPO:                         ethconfig.Defaults.GPO,
 		logger:                      logger,
 	}
 }
@@ -450,16 +465,27 @@ func newRPCRawTransactionFromBlockIndex(b *types.Block, index uint64) (hexutil.B
 	return buf.Bytes(), err
 }
 
+// maxFeeHistoryCacheEntries bounds the number of distinct eth_feeHistory
+// requests cached.
+const maxFeeHistoryCacheEntries = 128
+
 type GasPriceCache struct {
 	latestPrice *big.Int
 	latestHash  common.Hash
 	mtx         sync.Mutex
+
+	feeHistory *lru.Cache[gasprice.FeeHistoryKey, *gasprice.FeeHistoryResult] // thread-safe
 }
 
 func NewGasPriceCache() *GasPriceCache {
+	feeHistory, err := lru.New[gasprice.FeeHistoryKey, *gasprice.FeeHistoryResult](maxFeeHistoryCacheEntries)
+	if err != nil {
+		panic(err)
+	}
 	return &GasPriceCache{
 		latestPrice: big.NewInt(0),
 		latestHash:  common.Hash{},
+		feeHistory:  feeHistory,
 	}
 }
 
@@ -479,3 +505,11 @@ func (c *GasPriceCache) SetLatest(hash common.Hash, price *big.Int) {
 	c.latestHash = hash
 	c.mtx.Unlock()
 }
+
+func (c *GasPriceCache) GetFeeHistory(key gasprice.FeeHistoryKey) (*gasprice.FeeHistoryResult, bool) {
+	return c.feeHistory.Get(key)
+}
+
+func (c *GasPriceCache) SetFeeHistory(key gasprice.FeeHistoryKey, result *gasprice.FeeHistoryResult) {
+	c.feeHistory.Add(key, result)
+}
@@ -17,29 +17,43 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"time"
 
 	"github.com/holiman/uint256"
 
+	"github.com/erigontech/erigon-lib/abi"
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/hexutil"
 	"github.com/erigontech/erigon-lib/common/math"
+	"github.com/erigontech/erigon-lib/crypto"
+	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/log/v3"
 	"github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/tracing"
 	"github.com/erigontech/erigon/core/vm"
 	"github.com/erigontech/erigon/core/vm/evmtypes"
+	"github.com/erigontech/erigon/eth/tracers"
+	"github.com/erigontech/erigon/execution/consensus"
 	"github.com/erigontech/erigon/rpc"
 	"github.com/erigontech/erigon/rpc/ethapi"
 	"github.com/erigontech/erigon/rpc/rpchelper"
 )
 
+const (
+	DefaultCallManyMaxBundles     = 100
+	DefaultCallManyMaxTxPerBundle = 1000
+)
+
 type BlockOverrides struct {
 	BlockNumber *hexutil.Uint64
 	Coinbase    *common.Address
@@ -47,17 +61,45 @@ type BlockOverrides struct {
 	GasLimit    *hexutil.Uint
 	Difficulty  *hexutil.Uint
 	BaseFee     *uint256.Int
+	PrevRandao  *common.Hash
 	BlockHash   *map[uint64]common.Hash
 }
 
 type Bundle struct {
-	Transactions  []ethapi.CallArgs
-	BlockOverride BlockOverrides
+	Transactions   []ethapi.CallArgs
+	BlockOverride  BlockOverrides
+	StateOverrides *ethapi.StateOverrides // applied on top of the state left by the previous bundles
+	// ContinueOnError records a call which can't be applied (e.g. nonce or balance
+	// checks fail) as an error result and goes on with the next one, like a block
+	// including a failed transaction, instead of aborting the whole request.
+	ContinueOnError bool
+	// Trace attaches the callTracer to every call of the bundle and returns its
+	// call frames in the results.
+	Trace bool
+	// StorageAccess returns the storage slots read and written by every call of
+	// the bundle, per address.
+	StorageAccess bool
+	// Timeout limits the execution of the bundle, in milliseconds. A bundle out
+	// of time ends with a timeout error and the next bundles still run, while
+	// the timeout of the whole request aborts it.
+	Timeout *int64
+}
+
+// BundleResult holds the results of the calls of a bundle, along with the fees
+// of the block the bundle was simulated in.
+type BundleResult struct {
+	Results           []map[string]interface{} `json:"results"`
+	BaseFeePerGas     *hexutil.Big             `json:"baseFeePerGas,omitempty"`
+	BlobBaseFeePerGas *hexutil.Big             `json:"blobBaseFeePerGas,omitempty"`
 }
 
 type StateContext struct {
 	BlockNumber      rpc.BlockNumberOrHash
 	TransactionIndex *int
+	BlockOverride    BlockOverrides // applied to the block context once the transactions before TransactionIndex are replayed
+	// NoBaseFee skips the base fee and the sender balance checks of the simulated calls, like eth_call
+	// does, so they can be sent from unfunded accounts.
+	NoBaseFee bool
 }
 
 func blockHeaderOverride(blockCtx *evmtypes.BlockContext, blockOverride BlockOverrides, overrideBlockHash map[uint64]common.Hash) {
@@ -79,6 +121,9 @@ func blockHeaderOverride(blockCtx *evmtypes.BlockContext, blockOverride BlockOve
 	if blockOverride.GasLimit != nil {
 		blockCtx.GasLimit = uint64(*blockOverride.GasLimit)
 	}
+	if blockOverride.PrevRandao != nil {
+		blockCtx.PrevRanDao = blockOverride.PrevRandao
+	}
 	if blockOverride.BlockHash != nil {
 		for blockNum, hash := range *blockOverride.BlockHash {
 			overrideBlockHash[blockNum] = hash
@@ -86,7 +131,89 @@ func blockHeaderOverride(blockCtx *evmtypes.BlockContext, blockOverride BlockOve
 	}
 }
 
-func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateContext StateContext, stateOverride *ethapi.StateOverrides, timeoutMilliSecondsPtr *int64) ([][]map[string]interface{}, error) {
+// overriddenHashFn returns the canonical hashes of the blocks, unless they are
+// overridden in overrideBlockHash.
+func (api *APIImpl) overriddenHashFn(ctx context.Context, tx kv.Getter, overrideBlockHash map[uint64]common.Hash) func(uint64) (common.Hash, error) {
+	return func(i uint64) (common.Hash, error) {
+		if hash, ok := overrideBlockHash[i]; ok {
+			return hash, nil
+		}
+		hash, ok, err := api._blockReader.CanonicalHash(ctx, tx, i)
+		if err != nil || !ok {
+			log.Debug("Can't get block hash by number", "number", i, "only-canonical", true, "err", err, "ok", ok)
+		}
+		return hash, err
+	}
+}
+
+// withCallTimeout returns a context done after timeout, or only when cancelled
+// if timeout isn't positive, as for unmetered calls.
+func withCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// applyMessageUntil applies msg like core.ApplyMessage with refunds, cancelling
+// evm when ctx is done.
+func applyMessageUntil(ctx context.Context, evm *vm.EVM, msg core.Message, gp *core.GasPool, gasBailout bool, engine consensus.EngineReader) (*evmtypes.ExecutionResult, error) {
+	stop := context.AfterFunc(ctx, evm.Cancel)
+	defer stop()
+	return core.ApplyMessage(evm, msg, gp, true /* refunds */, gasBailout, engine)
+}
+
+func callManyLogs(logs types.Logs) []map[string]interface{} {
+	jsonLogs := make([]map[string]interface{}, 0, len(logs))
+	for _, l := range logs {
+		jsonLogs = append(jsonLogs, map[string]interface{}{
+			"address": l.Address,
+			"topics":  l.Topics,
+			"data":    hexutil.Bytes(l.Data),
+		})
+	}
+	return jsonLogs
+}
+
+// panicSelector is the selector of the Panic(uint256) errors raised by solidity.
+var panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+// panicReasons describes the solidity panic codes, see
+// https://docs.soliditylang.org/en/v0.8.21/control-structures.html#panic-via-assert-and-error-via-require
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// unpackRevertReason decodes revert data encoded as Error(string), like eth_call,
+// or as Panic(uint256).
+func unpackRevertReason(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], panicSelector) {
+		return abi.UnpackRevert(data)
+	}
+	typ, _ := abi.NewType("uint256", "", nil)
+	unpacked, err := (abi.Arguments{{Type: typ}}).Unpack(data[4:])
+	if err != nil {
+		return "", err
+	}
+	code := unpacked[0].(*big.Int)
+	if code.IsUint64() {
+		if reason, ok := panicReasons[code.Uint64()]; ok {
+			return reason, nil
+		}
+	}
+	return fmt.Sprintf("unknown panic code: %#x", code), nil
+}
+
+func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateContext StateContext, stateOverride *ethapi.StateOverrides, timeoutMilliSecondsPtr *int64) ([]*BundleResult, error) {
 	var (
 		hash               common.Hash
 		replayTransactions types.Transactions
@@ -96,6 +223,15 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 		overrideBlockHash  map[uint64]common.Hash
 	)
 
+	if len(bundles) > api.MaxBundles {
+		return nil, fmt.Errorf("too many bundles: %d, max %d", len(bundles), api.MaxBundles)
+	}
+	for i, bundle := range bundles {
+		if len(bundle.Transactions) > api.MaxTxPerBundle {
+			return nil, fmt.Errorf("too many transactions in bundle %d: %d, max %d", i, len(bundle.Transactions), api.MaxTxPerBundle)
+		}
+	}
+
 	overrideBlockHash = make(map[uint64]common.Hash)
 	tx, err := api.db.BeginTemporalRo(ctx)
 	if err != nil {
@@ -122,15 +258,24 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 
 	defer func(start time.Time) { log.Trace("Executing EVM callMany finished", "runtime", time.Since(start)) }(time.Now())
 
-	blockNum, hash, _, err := rpchelper.GetBlockNumber(ctx, simulateContext.BlockNumber, tx, api._blockReader, api.filters)
-	if err != nil {
-		return nil, err
+	var block *types.Block
+	// the pending block isn't stored, its transactions are replayed on top of the latest state
+	if number, ok := simulateContext.BlockNumber.Number(); ok && number == rpc.PendingBlockNumber && api.filters != nil {
+		block = api.pendingBlock()
 	}
-
-	block, err := api.blockWithSenders(ctx, tx, hash, blockNum)
-	if err != nil {
-		return nil, err
+	if block == nil {
+		blockNum, hash, _, err := rpchelper.GetBlockNumber(ctx, simulateContext.BlockNumber, tx, api._blockReader, api.filters)
+		if err != nil {
+			return nil, err
+		}
+		if block, err = api.blockWithSenders(ctx, tx, hash, blockNum); err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block %d(%x) not found", blockNum, hash)
+		}
 	}
+	blockNum, hash := block.NumberU64(), block.Hash()
 
 	// -1 is a default value for transaction index.
 	// If it's -1, we will try to replay every single transaction in that block
@@ -160,16 +305,7 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 		return nil, fmt.Errorf("block %d(%x) not found", blockNum, hash)
 	}
 
-	getHash := func(i uint64) (common.Hash, error) {
-		if hash, ok := overrideBlockHash[i]; ok {
-			return hash, nil
-		}
-		hash, ok, err := api._blockReader.CanonicalHash(ctx, tx, i)
-		if err != nil || !ok {
-			log.Debug("Can't get block hash by number", "number", i, "only-canonical", true, "err", err, "ok", ok)
-		}
-		return hash, err
-	}
+	getHash := api.overriddenHashFn(ctx, tx, overrideBlockHash)
 
 	blockCtx = core.NewEVMBlockContext(header, getHash, api.engine(), nil /* author */, chainConfig)
 
@@ -185,14 +321,7 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 	}
 
 	timeout := time.Millisecond * time.Duration(timeoutMilliSeconds)
-	// Setup context so it may be cancelled the call has completed
-	// or, in case of unmetered gas, setup a context with a timeout.
-	var cancel context.CancelFunc
-	if timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, timeout)
-	} else {
-		ctx, cancel = context.WithCancel(ctx)
-	}
+	ctx, cancel := withCallTimeout(ctx, timeout)
 	// Make sure the context is cancelled when the call has completed
 	// this makes sure resources are cleaned up.
 	defer cancel()
@@ -229,8 +358,8 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 		}
 	}
 
-	// after replaying the txns, we want to overload the state
-	// overload state
+	// after replaying the txns, we want to overload the block context and the state
+	blockHeaderOverride(&blockCtx, simulateContext.BlockOverride, overrideBlockHash)
 	if stateOverride != nil {
 		err = stateOverride.Override(evm.IntraBlockState())
 		if err != nil {
@@ -238,33 +367,31 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 		}
 	}
 
-	ret := make([][]map[string]interface{}, 0)
+	ret := make([]*BundleResult, 0, len(bundles))
 
-	for _, bundle := range bundles {
-		// first change blockContext
-		if bundle.BlockOverride.BlockNumber != nil {
-			blockCtx.BlockNumber = uint64(*bundle.BlockOverride.BlockNumber)
-		}
-		if bundle.BlockOverride.BaseFee != nil {
-			blockCtx.BaseFee = bundle.BlockOverride.BaseFee
-		}
-		if bundle.BlockOverride.Coinbase != nil {
-			blockCtx.Coinbase = *bundle.BlockOverride.Coinbase
-		}
-		if bundle.BlockOverride.Difficulty != nil {
-			blockCtx.Difficulty = new(big.Int).SetUint64(uint64(*bundle.BlockOverride.Difficulty))
+	// simulated transactions are indexed after the replayed ones
+	txIndex := transactionIndex
+	for bundleIndex, bundle := range bundles {
+		bundleCtx, bundleCancel := ctx, context.CancelFunc(func() {})
+		var bundleTimeout time.Duration
+		if bundle.Timeout != nil && *bundle.Timeout > 0 {
+			bundleTimeout = time.Millisecond * time.Duration(*bundle.Timeout)
+			bundleCtx, bundleCancel = context.WithTimeout(ctx, bundleTimeout)
 		}
-		if bundle.BlockOverride.Timestamp != nil {
-			blockCtx.Time = uint64(*bundle.BlockOverride.Timestamp)
+		// timedOut tells apart the bundle running out of its own time, which
+		// only ends that bundle, from the whole request running out of time
+		timedOut := func() bool {
+			return bundleTimeout > 0 && errors.Is(bundleCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
 		}
-		if bundle.BlockOverride.GasLimit != nil {
-			blockCtx.GasLimit = uint64(*bundle.BlockOverride.GasLimit)
-		}
-		if bundle.BlockOverride.BlockHash != nil {
-			for blockNum, hash := range *bundle.BlockOverride.BlockHash {
-				overrideBlockHash[blockNum] = hash
+
+		if bundle.StateOverrides != nil {
+			if err = bundle.StateOverrides.Override(evm.IntraBlockState()); err != nil {
+				bundleCancel()
+				return nil, err
 			}
 		}
+		// then change blockContext
+		blockHeaderOverride(&blockCtx, bundle.BlockOverride, overrideBlockHash)
 		results := []map[string]interface{}{}
 		for _, txn := range bundle.Transactions {
 			if txn.Gas == nil || *(txn.Gas) == 0 {
@@ -272,21 +399,74 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 			}
 			msg, err := txn.ToMessage(api.GasCap, blockCtx.BaseFee)
 			if err != nil {
+				if bundle.ContinueOnError {
+					results = append(results, map[string]interface{}{"error": err.Error()})
+					txIndex++
+					continue
+				}
+				bundleCancel()
 				return nil, err
 			}
+			var (
+				tracer   *tracers.Tracer
+				vmConfig = vm.Config{NoBaseFee: simulateContext.NoBaseFee}
+			)
+			if bundle.Trace {
+				if tracer, err = tracers.New("callTracer", &tracers.Context{TxIndex: txIndex}, json.RawMessage("{}")); err != nil {
+					bundleCancel()
+					return nil, err
+				}
+				vmConfig.Tracer = tracer.Hooks
+			}
+			var storageTracer *storageAccessTracer
+			if bundle.StorageAccess {
+				storageTracer = newStorageAccessTracer()
+				vmConfig.Tracer = storageTracer.hooks(vmConfig.Tracer)
+			}
 			txCtx = core.NewEVMTxContext(msg)
-			evm = vm.NewEVM(blockCtx, txCtx, evm.IntraBlockState(), chainConfig, vm.Config{})
-			result, err := core.ApplyMessage(evm, msg, gp, true /* refunds */, false /* gasBailout */, api.engine())
+			st.SetTxContext(blockCtx.BlockNumber, txIndex)
+			st.SetHooks(vmConfig.Tracer)
+			evm = vm.NewEVM(blockCtx, txCtx, evm.IntraBlockState(), chainConfig, vmConfig)
+			if tracer != nil && tracer.OnTxStart != nil {
+				tracedTxn, err := txn.ToTransaction(api.GasCap, blockCtx.BaseFee)
+				if err != nil {
+					bundleCancel()
+					return nil, err
+				}
+				tracer.OnTxStart(evm.GetVMContext(), tracedTxn, msg.From())
+			}
+			// a failed call may have bought gas and bumped the nonce of its sender
+			// before failing, which must not leak into the next calls
+			gasSnap, blobGasSnap := gp.Gas(), gp.BlobGas()
+			snap := st.Snapshot()
+			result, err := applyMessageUntil(bundleCtx, evm, msg, gp, simulateContext.NoBaseFee /* gasBailout */, api.engine())
+			// If the timer caused an abort, return an appropriate error message
+			if evm.Cancelled() {
+				if !timedOut() {
+					bundleCancel()
+					return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+				}
+				// the rest of the bundle is skipped, the next bundles still run
+				st.RevertToSnapshot(snap, nil)
+				gp.Reset(gasSnap, blobGasSnap)
+				results = append(results, map[string]interface{}{
+					"error": fmt.Sprintf("bundle %d execution aborted (timeout = %v)", bundleIndex, bundleTimeout),
+				})
+				break
+			}
 			if err != nil {
+				if bundle.ContinueOnError {
+					st.RevertToSnapshot(snap, err)
+					gp.Reset(gasSnap, blobGasSnap)
+					results = append(results, map[string]interface{}{"error": err.Error()})
+					txIndex++
+					continue
+				}
+				bundleCancel()
 				return nil, err
 			}
 
 			_ = st.FinalizeTx(rules, state.NewNoopWriter())
-
-			// If the timer caused an abort, return an appropriate error message
-			if evm.Cancelled() {
-				return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
-			}
 			jsonResult := make(map[string]interface{})
 			if result.Err != nil {
 				if len(result.Revert()) > 0 {
@@ -295,20 +475,125 @@ func (api *APIImpl) CallMany(ctx context.Context, bundles []Bundle, simulateCont
 						"message": revertErr.Error(),
 						"data":    revertErr.ErrorData(),
 					}
+					jsonResult["revertData"] = hexutil.Bytes(result.Revert())
+					if reason, errUnpack := unpackRevertReason(result.Revert()); errUnpack == nil {
+						jsonResult["revertReason"] = reason
+					}
 				} else {
 					jsonResult["error"] = result.Err.Error()
 				}
 			} else {
 				jsonResult["value"] = hex.EncodeToString(result.Return())
 			}
+			jsonResult["gasUsed"] = hexutil.Uint64(result.GasUsed)
+			jsonResult["logs"] = callManyLogs(st.GetRawLogs(txIndex))
+			if storageTracer != nil {
+				jsonResult["storageAccess"] = storageTracer.result()
+			}
+			if tracer != nil {
+				if tracer.OnTxEnd != nil {
+					tracer.OnTxEnd(&types.Receipt{GasUsed: result.GasUsed}, nil)
+				}
+				if jsonResult["trace"], err = tracer.GetResult(); err != nil {
+					bundleCancel()
+					return nil, err
+				}
+			}
+			txIndex++
 
 			results = append(results, jsonResult)
 		}
 
+		bundleCancel()
+
+		// every bundle is simulated as its own block, report the fees it was executed with
+		bundleResult := &BundleResult{Results: results}
+		if rules.IsLondon {
+			bundleResult.BaseFeePerGas = (*hexutil.Big)(blockCtx.BaseFee.ToBig())
+		}
+		if blockCtx.BlobBaseFee != nil {
+			bundleResult.BlobBaseFeePerGas = (*hexutil.Big)(blockCtx.BlobBaseFee.ToBig())
+		}
+
 		blockCtx.BlockNumber++
 		blockCtx.Time++
-		ret = append(ret, results)
+		ret = append(ret, bundleResult)
 	}
 
 	return ret, err
 }
+
+// storageAccessTracer records the storage slots accessed by SLOAD and SSTORE,
+// the same way the access list tracer of eth_createAccessList does.
+type storageAccessTracer struct {
+	reads  map[common.Address]map[common.Hash]struct{}
+	writes map[common.Address]map[common.Hash]struct{}
+}
+
+func newStorageAccessTracer() *storageAccessTracer {
+	return &storageAccessTracer{
+		reads:  make(map[common.Address]map[common.Hash]struct{}),
+		writes: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// hooks returns a copy of base with the tracer's OnOpcode chained in.
+func (t *storageAccessTracer) hooks(base *tracing.Hooks) *tracing.Hooks {
+	hooks := &tracing.Hooks{}
+	if base != nil {
+		*hooks = *base
+	}
+	next := hooks.OnOpcode
+	hooks.OnOpcode = func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+		t.onOpcode(scope, vm.OpCode(op))
+		if next != nil {
+			next(pc, op, gas, cost, scope, rData, depth, err)
+		}
+	}
+	return hooks
+}
+
+func (t *storageAccessTracer) onOpcode(scope tracing.OpContext, op vm.OpCode) {
+	var slots map[common.Address]map[common.Hash]struct{}
+	switch op {
+	case vm.SLOAD:
+		slots = t.reads
+	case vm.SSTORE:
+		slots = t.writes
+	default:
+		return
+	}
+	stackData := scope.StackData()
+	if len(stackData) < 1 {
+		return
+	}
+	addr := scope.Address()
+	if slots[addr] == nil {
+		slots[addr] = make(map[common.Hash]struct{})
+	}
+	slots[addr][common.Hash(stackData[len(stackData)-1].Bytes32())] = struct{}{}
+}
+
+func (t *storageAccessTracer) result() map[common.Address]map[string][]common.Hash {
+	sorted := func(slots map[common.Hash]struct{}) []common.Hash {
+		res := make([]common.Hash, 0, len(slots))
+		for slot := range slots {
+			res = append(res, slot)
+		}
+		slices.SortFunc(res, func(a, b common.Hash) int { return bytes.Compare(a[:], b[:]) })
+		return res
+	}
+	res := make(map[common.Address]map[string][]common.Hash)
+	for _, access := range []struct {
+		kind  string
+		slots map[common.Address]map[common.Hash]struct{}
+	}{{"reads", t.reads}, {"writes", t.writes}} {
+		for addr, slots := range access.slots {
+			if res[addr] == nil {
+				res[addr] = map[string][]common.Hash{"reads": {}, "writes": {}}
+			}
+			res[addr][access.kind] = sorted(slots)
+		}
+	}
+	return res
+}
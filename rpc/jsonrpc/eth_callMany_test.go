@@ -17,19 +17,28 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/holiman/uint256"
+
 	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/chain/params"
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/common/hexutil"
 	"github.com/erigontech/erigon-lib/crypto"
+	txpool "github.com/erigontech/erigon-lib/gointerfaces/txpoolproto"
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/rlp"
 	"github.com/erigontech/erigon-lib/types"
 	"github.com/erigontech/erigon/eth/ethconfig"
 	"github.com/erigontech/erigon/execution/abi/bind"
@@ -38,6 +47,7 @@ import (
 	"github.com/erigontech/erigon/rpc/ethapi"
 	"github.com/erigontech/erigon/rpc/jsonrpc/contracts"
 	"github.com/erigontech/erigon/rpc/rpccfg"
+	"github.com/erigontech/erigon/rpc/rpchelper"
 )
 
 // block 1 contains 3 Transactions
@@ -98,7 +108,7 @@ func TestCallMany(t *testing.T) {
 
 	db := contractBackend.DB()
 	engine := contractBackend.Engine()
-	api := NewEthAPI(NewBaseApi(nil, stateCache, contractBackend.BlockReader(), false, rpccfg.DefaultEvmCallTimeout, engine, datadir.New(t.TempDir()), nil), db, nil, nil, nil, 5000000, ethconfig.Defaults.RPCTxFeeCap, 100_000, false, 100_000, 128, log.New())
+	api := NewEthAPI(NewBaseApi(nil, stateCache, contractBackend.BlockReader(), false, rpccfg.DefaultEvmCallTimeout, engine, datadir.New(t.TempDir()), nil), db, nil, nil, nil, 5000000, ethconfig.Defaults.RPCTxFeeCap, 100_000, false, 100_000, 128, 0, 0, log.New())
 
 	callArgAddr1 := ethapi.CallArgs{From: &address, To: &tokenAddr, Nonce: &nonce,
 		MaxPriorityFeePerGas: (*hexutil.Big)(big.NewInt(1e9)),
@@ -126,8 +136,8 @@ func TestCallMany(t *testing.T) {
 	}
 
 	// parse the results and do balance checks
-	addr1CalRet := fmt.Sprintf("%v", res[0][0]["value"])[2:]
-	addr2CalRet := fmt.Sprintf("%v", res[0][1]["value"])[2:]
+	addr1CalRet := fmt.Sprintf("%v", res[0].Results[0]["value"])[2:]
+	addr2CalRet := fmt.Sprintf("%v", res[0].Results[1]["value"])[2:]
 	addr1Balance, err := strconv.ParseInt(addr1CalRet, 16, 64)
 	if err != nil {
 		t.Errorf("eth_callMany: %v", err)
@@ -140,6 +150,12 @@ func TestCallMany(t *testing.T) {
 	if addr1Balance != 100 || addr2Balance != 0 {
 		t.Errorf("eth_callMany: %v", "balanceUnmatch")
 	}
+	// a balanceOf call costs more than the intrinsic gas
+	for _, result := range res[0].Results {
+		if gasUsed, ok := result["gasUsed"].(hexutil.Uint64); !ok || gasUsed <= 21000 {
+			t.Errorf("eth_callMany: unexpected gasUsed %v", result["gasUsed"])
+		}
+	}
 
 	txIndex = 2
 	res, err = api.CallMany(ctx, []Bundle{{
@@ -148,8 +164,8 @@ func TestCallMany(t *testing.T) {
 		t.Errorf("eth_callMany: %v", err)
 	}
 
-	addr1CalRet = fmt.Sprintf("%v", res[0][0]["value"])[2:]
-	addr2CalRet = fmt.Sprintf("%v", res[0][1]["value"])[2:]
+	addr1CalRet = fmt.Sprintf("%v", res[0].Results[0]["value"])[2:]
+	addr2CalRet = fmt.Sprintf("%v", res[0].Results[1]["value"])[2:]
 	addr1Balance, err = strconv.ParseInt(addr1CalRet, 16, 64)
 	if err != nil {
 		t.Errorf("%v", err)
@@ -168,8 +184,8 @@ func TestCallMany(t *testing.T) {
 		t.Errorf("%v", err)
 	}
 
-	addr1CalRet = fmt.Sprintf("%v", res[0][1]["value"])[2:]
-	addr2CalRet = fmt.Sprintf("%v", res[0][2]["value"])[2:]
+	addr1CalRet = fmt.Sprintf("%v", res[0].Results[1]["value"])[2:]
+	addr2CalRet = fmt.Sprintf("%v", res[0].Results[2]["value"])[2:]
 
 	addr1Balance, err = strconv.ParseInt(addr1CalRet, 16, 64)
 	if err != nil {
@@ -182,4 +198,586 @@ func TestCallMany(t *testing.T) {
 	if addr1Balance != 100 || addr2Balance != 0 {
 		t.Errorf("eth_callMany: %s", "balanceUnmatch")
 	}
+
+	// override the token balance of addr1 (balanceOf is the mapping at slot 1) for the second bundle only
+	balanceSlot := crypto.Keccak256Hash(common.LeftPadBytes(address1.Bytes(), 32), common.LeftPadBytes([]byte{1}, 32))
+	overrides := ethapi.StateOverrides{tokenAddr: ethapi.Account{
+		StateDiff: &map[common.Hash]common.Hash{balanceSlot: common.BigToHash(big.NewInt(1000))},
+	}}
+	res, err = api.CallMany(ctx, []Bundle{
+		{Transactions: []ethapi.CallArgs{callArgAddr1}},
+		{Transactions: []ethapi.CallArgs{callArgAddr1}, StateOverrides: &overrides},
+	}, StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), TransactionIndex: &txIndex}, nil, &timeout)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	for i, expected := range []int64{100, 1000} {
+		addr1CalRet = fmt.Sprintf("%v", res[i].Results[0]["value"])[2:]
+		addr1Balance, err = strconv.ParseInt(addr1CalRet, 16, 64)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if addr1Balance != expected {
+			t.Errorf("eth_callMany: bundle %d balance %d, expected %d", i, addr1Balance, expected)
+		}
+	}
+
+	res, err = api.CallMany(ctx, []Bundle{
+		{Transactions: []ethapi.CallArgs{callArgAddr1}, StorageAccess: true},
+	}, StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), TransactionIndex: &txIndex}, nil, &timeout)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	storageAccess := res[0].Results[0]["storageAccess"].(map[common.Address]map[string][]common.Hash)
+	if reads := storageAccess[tokenAddr]["reads"]; len(reads) != 1 || reads[0] != balanceSlot {
+		t.Errorf("eth_callMany: unexpected storage reads %v, expected %v", reads, balanceSlot)
+	}
+	if writes := storageAccess[tokenAddr]["writes"]; len(writes) != 0 {
+		t.Errorf("eth_callMany: unexpected storage writes %v", writes)
+	}
+}
+
+// callManyTestKey funds the account returned by newCallManyTestAPI
+const callManyTestKey = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+
+// newCallManyTestAPI returns an API backed by a chain with a single empty block
+// and a funded account to send the simulated calls from.
+func newCallManyTestAPI(t *testing.T) (*APIImpl, common.Address) {
+	return newCallManyTestAPIWithConfig(t, chain.TestChainConfig)
+}
+
+func newCallManyTestAPIWithConfig(t *testing.T, config *chain.Config) (*APIImpl, common.Address) {
+	var (
+		key, _  = crypto.HexToECDSA(callManyTestKey)
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		alloc   = types.GenesisAlloc{address: {Balance: big.NewInt(9000000000000000000)}}
+	)
+	contractBackend := backends.NewTestSimulatedBackendWithConfig(t, alloc, config, 10000000)
+	t.Cleanup(contractBackend.Close)
+	// the simulated backend can't seal post-merge blocks, such configs run on top of genesis
+	if config.TerminalTotalDifficulty == nil {
+		contractBackend.Commit()
+	}
+
+	stateCache := kvcache.New(kvcache.DefaultCoherentConfig)
+	api := NewEthAPI(NewBaseApi(nil, stateCache, contractBackend.BlockReader(), false, rpccfg.DefaultEvmCallTimeout, contractBackend.Engine(), datadir.New(t.TempDir()), nil), contractBackend.DB(), nil, nil, nil, 5000000, ethconfig.Defaults.RPCTxFeeCap, 100_000, false, 100_000, 128, 0, 0, log.New())
+	return api, address
+}
+
+func TestCallManyBlockOverrides(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		// TIMESTAMP PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		timestampCode = hexutil.Bytes{0x42, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+		contractAddr  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	)
+
+	overrides := ethapi.StateOverrides{contractAddr: ethapi.Account{Code: &timestampCode}}
+	timestamp := hexutil.Uint64(1_234_567)
+	call := ethapi.CallArgs{From: &address, To: &contractAddr}
+	timeout := int64(50000)
+	res, err := api.CallMany(ctx, []Bundle{
+		{Transactions: []ethapi.CallArgs{call}},
+		{Transactions: []ethapi.CallArgs{call}},
+	}, StateContext{
+		BlockNumber:   rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber),
+		BlockOverride: BlockOverrides{Timestamp: &timestamp},
+	}, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	// every following bundle is simulated one second later
+	for i, expected := range []uint64{1_234_567, 1_234_568} {
+		ret, err := strconv.ParseUint(fmt.Sprintf("%v", res[i].Results[0]["value"]), 16, 64)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if ret != expected {
+			t.Errorf("eth_callMany: bundle %d TIMESTAMP %d, expected %d", i, ret, expected)
+		}
+	}
+}
+
+func TestCallManyLogs(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		topic        = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+		contractAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	)
+	// PUSH1 42 PUSH1 0 MSTORE PUSH32 topic PUSH1 32 PUSH1 0 LOG1 STOP
+	logCode := hexutil.Bytes{0x60, 0x2a, 0x60, 0x00, 0x52, 0x7f}
+	logCode = append(logCode, topic.Bytes()...)
+	logCode = append(logCode, 0x60, 0x20, 0x60, 0x00, 0xa1, 0x00)
+	overrides := ethapi.StateOverrides{contractAddr: ethapi.Account{Code: &logCode}}
+
+	emit := ethapi.CallArgs{From: &address, To: &contractAddr}
+	transfer := ethapi.CallArgs{From: &address, To: &address, Value: (*hexutil.Big)(big.NewInt(1))}
+	timeout := int64(50000)
+	res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{emit, transfer, emit}}},
+		StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	for i, expected := range []int{1, 0, 1} {
+		logs := res[0].Results[i]["logs"].([]map[string]interface{})
+		if len(logs) != expected {
+			t.Fatalf("eth_callMany: call %d has %d logs, expected %d", i, len(logs), expected)
+		}
+		if expected == 0 {
+			continue
+		}
+		if logs[0]["address"] != contractAddr {
+			t.Errorf("eth_callMany: unexpected log address %v", logs[0]["address"])
+		}
+		if topics := logs[0]["topics"].([]common.Hash); len(topics) != 1 || topics[0] != topic {
+			t.Errorf("eth_callMany: unexpected log topics %v", topics)
+		}
+		if data := logs[0]["data"].(hexutil.Bytes); new(big.Int).SetBytes(data).Int64() != 42 {
+			t.Errorf("eth_callMany: unexpected log data %v", data)
+		}
+	}
+}
+
+func TestCallManyContinueOnError(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		revertAddr   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		// PUSH1 0 PUSH1 0 REVERT
+		revertCode = hexutil.Bytes{0x60, 0x00, 0x60, 0x00, 0xfd}
+		overrides  = ethapi.StateOverrides{revertAddr: ethapi.Account{Code: &revertCode}}
+		poor       = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		timeout    = int64(50000)
+	)
+	calls := []ethapi.CallArgs{
+		{From: &address, To: &revertAddr},
+		// fails the balance check before execution
+		{From: &poor, To: &address, Value: (*hexutil.Big)(big.NewInt(1))},
+		{From: &address, To: &poor, Value: (*hexutil.Big)(big.NewInt(1))},
+	}
+	stateContext := StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}
+
+	if _, err := api.CallMany(ctx, []Bundle{{Transactions: calls}}, stateContext, &overrides, &timeout); err == nil {
+		t.Fatal("eth_callMany: expected the bundle to be aborted")
+	}
+
+	res, err := api.CallMany(ctx, []Bundle{{Transactions: calls, ContinueOnError: true}}, stateContext, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if len(res[0].Results) != len(calls) {
+		t.Fatalf("eth_callMany: %d results, expected %d", len(res[0].Results), len(calls))
+	}
+	if _, ok := res[0].Results[0]["error"]; !ok {
+		t.Errorf("eth_callMany: expected revert error, got %v", res[0].Results[0])
+	}
+	if _, ok := res[0].Results[1]["error"]; !ok {
+		t.Errorf("eth_callMany: expected balance error, got %v", res[0].Results[1])
+	}
+	if _, ok := res[0].Results[2]["value"]; !ok {
+		t.Errorf("eth_callMany: expected success, got %v", res[0].Results[2])
+	}
+}
+
+func TestCallManyContinueOnErrorReverts(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		balanceAddr  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		// CALLER BALANCE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		balanceCode = hexutil.Bytes{0x33, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+		overrides   = ethapi.StateOverrides{balanceAddr: ethapi.Account{Code: &balanceCode}}
+		lowGas      = hexutil.Uint64(1000)
+		timeout     = int64(50000)
+	)
+	balanceCall := ethapi.CallArgs{From: &address, To: &balanceAddr}
+	// buys its gas, then fails the intrinsic gas check
+	failingCall := ethapi.CallArgs{From: &address, To: &balanceAddr, Gas: &lowGas, GasPrice: (*hexutil.Big)(big.NewInt(1e12))}
+	stateContext := StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}
+
+	want, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{balanceCall}}}, stateContext, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{failingCall, balanceCall}, ContinueOnError: true}}, stateContext, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if errMsg, ok := res[0].Results[0]["error"].(string); !ok || !strings.Contains(errMsg, "intrinsic gas") {
+		t.Fatalf("eth_callMany: expected intrinsic gas error, got %v", res[0].Results[0])
+	}
+	if res[0].Results[1]["value"] != want[0].Results[0]["value"] {
+		t.Errorf("eth_callMany: sender balance %v after a failed call, expected %v", res[0].Results[1]["value"], want[0].Results[0]["value"])
+	}
+}
+
+func TestCallManyBlobHashes(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPIWithConfig(t, chain.AllProtocolChanges)
+		ctx          = context.Background()
+		contractAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		// PUSH1 0 BLOBHASH PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		blobHashCode = hexutil.Bytes{0x60, 0x00, 0x49, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+		overrides    = ethapi.StateOverrides{contractAddr: ethapi.Account{Code: &blobHashCode}}
+		blobHash     = common.HexToHash("0x01a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+		timeout      = int64(50000)
+	)
+	call := ethapi.CallArgs{
+		From:                &address,
+		To:                  &contractAddr,
+		MaxFeePerGas:        (*hexutil.Big)(big.NewInt(1_000_000_000)),
+		MaxFeePerBlobGas:    (*hexutil.Big)(big.NewInt(1_000_000_000)),
+		BlobVersionedHashes: []common.Hash{blobHash},
+	}
+	res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{call}}},
+		StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if res[0].Results[0]["value"] != hex.EncodeToString(blobHash.Bytes()) {
+		t.Errorf("eth_callMany: BLOBHASH returned %v, expected %x", res[0].Results[0], blobHash)
+	}
+}
+
+// revertWithCode returns EVM code reverting with the given data.
+func revertWithCode(data []byte) hexutil.Bytes {
+	var code hexutil.Bytes
+	for i := 0; i < len(data); i += 32 {
+		word := make([]byte, 32)
+		copy(word, data[i:])
+		code = append(code, 0x7f) // PUSH32
+		code = append(code, word...)
+		code = append(code, 0x60, byte(i), 0x52) // PUSH1 i MSTORE
+	}
+	return append(code, 0x60, byte(len(data)), 0x60, 0x00, 0xfd) // PUSH1 len PUSH1 0 REVERT
+}
+
+func TestCallManyRevertReason(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		errorAddr    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		panicAddr    = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		rawAddr      = common.HexToAddress("0x1000000000000000000000000000000000000003")
+		// Error("boom")
+		errorData = common.Hex2Bytes("08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000004" +
+			"626f6f6d00000000000000000000000000000000000000000000000000000000")
+		// Panic(0x11)
+		panicData = common.Hex2Bytes("4e487b710000000000000000000000000000000000000000000000000000000000000011")
+		rawData   = []byte{0xde, 0xad, 0xbe, 0xef}
+		errorCode = revertWithCode(errorData)
+		panicCode = revertWithCode(panicData)
+		rawCode   = revertWithCode(rawData)
+		overrides = ethapi.StateOverrides{
+			errorAddr: ethapi.Account{Code: &errorCode},
+			panicAddr: ethapi.Account{Code: &panicCode},
+			rawAddr:   ethapi.Account{Code: &rawCode},
+		}
+		timeout = int64(50000)
+	)
+	res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{
+		{From: &address, To: &errorAddr},
+		{From: &address, To: &panicAddr},
+		{From: &address, To: &rawAddr},
+	}}}, StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	for i, expected := range []struct {
+		reason string
+		data   []byte
+	}{{"boom", errorData}, {"arithmetic underflow or overflow", panicData}, {"", rawData}} {
+		reason, ok := res[0].Results[i]["revertReason"]
+		if expected.reason == "" {
+			if ok {
+				t.Errorf("eth_callMany: call %d unexpected revert reason %v", i, reason)
+			}
+		} else if reason != expected.reason {
+			t.Errorf("eth_callMany: call %d revert reason %v, expected %q", i, reason, expected.reason)
+		}
+		if data := res[0].Results[i]["revertData"].(hexutil.Bytes); !bytes.Equal(data, expected.data) {
+			t.Errorf("eth_callMany: call %d revert data %x, expected %x", i, data, expected.data)
+		}
+	}
+}
+
+func TestCallManyBundleTimeout(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		loopAddr     = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		// JUMPDEST PUSH1 0 JUMP
+		loopCode      = hexutil.Bytes{0x5b, 0x60, 0x00, 0x56}
+		overrides     = ethapi.StateOverrides{loopAddr: ethapi.Account{Code: &loopCode}}
+		timeout       = int64(50000)
+		bundleTimeout = int64(1)
+	)
+	loops := make([]ethapi.CallArgs, 100)
+	for i := range loops {
+		loops[i] = ethapi.CallArgs{From: &address, To: &loopAddr}
+	}
+	cheap := []ethapi.CallArgs{{From: &address, To: &address}}
+	stateContext := StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}
+
+	// the bundle running out of time does not starve the next ones
+	res, err := api.CallMany(ctx, []Bundle{
+		{Transactions: loops, Timeout: &bundleTimeout},
+		{Transactions: cheap},
+	}, stateContext, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("eth_callMany: %d bundle results, expected 2", len(res))
+	}
+	timedOut := res[0].Results[len(res[0].Results)-1]
+	if msg, _ := timedOut["error"].(string); !strings.Contains(msg, "bundle 0 execution aborted") {
+		t.Fatalf("eth_callMany: expected bundle 0 to time out, got %v", timedOut)
+	}
+	if len(res[1].Results) != 1 || res[1].Results[0]["error"] != nil {
+		t.Fatalf("eth_callMany: expected bundle 1 to run, got %v", res[1])
+	}
+
+	res, err = api.CallMany(ctx, []Bundle{
+		{Transactions: cheap, Timeout: &timeout},
+		{Transactions: cheap},
+	}, stateContext, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("eth_callMany: %d bundle results, expected 2", len(res))
+	}
+}
+
+func TestCallManyBaseFee(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPIWithConfig(t, chain.AllProtocolChanges)
+		ctx          = context.Background()
+		timeout      = int64(50000)
+		baseFee      = uint256.NewInt(7)
+		call         = ethapi.CallArgs{From: &address, To: &address, MaxFeePerGas: (*hexutil.Big)(big.NewInt(2 * params.InitialBaseFee))}
+	)
+	res, err := api.CallMany(ctx, []Bundle{
+		{Transactions: []ethapi.CallArgs{call}},
+		{Transactions: []ethapi.CallArgs{call}, BlockOverride: BlockOverrides{BaseFee: baseFee}},
+	}, StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}, nil, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	for i, expected := range []*big.Int{big.NewInt(params.InitialBaseFee), baseFee.ToBig()} {
+		if fee := res[i].BaseFeePerGas.ToInt(); fee.Cmp(expected) != 0 {
+			t.Errorf("eth_callMany: bundle %d baseFeePerGas %v, expected %v", i, fee, expected)
+		}
+		if fee := res[i].BlobBaseFeePerGas.ToInt(); fee.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("eth_callMany: bundle %d blobBaseFeePerGas %v, expected 1", i, fee)
+		}
+		// the fees are reported once per bundle, not by every call
+		if _, ok := res[i].Results[0]["baseFeePerGas"]; ok {
+			t.Errorf("eth_callMany: bundle %d call result carries the base fee", i)
+		}
+	}
+}
+
+func TestCallManyTrace(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		callerAddr   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		calleeAddr   = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		timeout      = int64(50000)
+	)
+	// PUSH1 0 PUSH1 0 PUSH1 0 PUSH1 0 PUSH1 0 PUSH20 callee GAS CALL STOP
+	callerCode := hexutil.Bytes{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x73}
+	callerCode = append(callerCode, calleeAddr.Bytes()...)
+	callerCode = append(callerCode, 0x5a, 0xf1, 0x00)
+	calleeCode := hexutil.Bytes{0x00}
+	overrides := ethapi.StateOverrides{
+		callerAddr: ethapi.Account{Code: &callerCode},
+		calleeAddr: ethapi.Account{Code: &calleeCode},
+	}
+	call := ethapi.CallArgs{From: &address, To: &callerAddr}
+	stateContext := StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}
+
+	res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{call}}}, stateContext, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if _, ok := res[0].Results[0]["trace"]; ok {
+		t.Fatal("eth_callMany: trace returned without being requested")
+	}
+
+	res, err = api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{call}, Trace: true}}, stateContext, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	type callFrame struct {
+		To    common.Address `json:"to"`
+		Calls []callFrame    `json:"calls"`
+	}
+	var frame callFrame
+	if err := json.Unmarshal(res[0].Results[0]["trace"].(json.RawMessage), &frame); err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if frame.To != callerAddr || len(frame.Calls) != 1 || frame.Calls[0].To != calleeAddr || len(frame.Calls[0].Calls) != 0 {
+		t.Errorf("eth_callMany: unexpected call frames %s", res[0].Results[0]["trace"])
+	}
+}
+
+func TestCallManyPendingBlock(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		key, _       = crypto.HexToECDSA(callManyTestKey)
+		recipient    = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		balanceAddr  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		timeout      = int64(50000)
+	)
+	// PUSH20 recipient BALANCE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	balanceCode := hexutil.Bytes{0x73}
+	balanceCode = append(balanceCode, recipient.Bytes()...)
+	balanceCode = append(balanceCode, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+	overrides := ethapi.StateOverrides{balanceAddr: ethapi.Account{Code: &balanceCode}}
+	call := ethapi.CallArgs{From: &address, To: &balanceAddr}
+
+	filtersCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	api.filters = rpchelper.New(filtersCtx, rpchelper.DefaultFiltersConfig, nil, nil, nil, func() {}, log.New())
+
+	tx, err := api.db.BeginTemporalRo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := api.headerByRPCNumber(ctx, rpc.LatestBlockNumber, tx)
+	tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pendingTxn, err := types.SignTx(types.NewTransaction(0, recipient, uint256.NewInt(1000), 21000, uint256.NewInt(0), nil), *types.LatestSignerForChainID(nil), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := rlp.EncodeToBytes(types.NewBlock(&types.Header{
+		ParentHash: latest.Hash(),
+		Number:     new(big.Int).Add(latest.Number, common.Big1),
+		GasLimit:   latest.GasLimit,
+		Time:       latest.Time + 1,
+		Difficulty: new(big.Int),
+	}, []types.Transaction{pendingTxn}, nil, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.filters.HandlePendingBlock(&txpool.OnPendingBlockReply{RplBlock: pending})
+
+	for _, c := range []struct {
+		number   rpc.BlockNumber
+		expected uint64
+	}{{rpc.LatestBlockNumber, 0}, {rpc.PendingBlockNumber, 1000}} {
+		res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{call}}},
+			StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(c.number)}, &overrides, &timeout)
+		if err != nil {
+			t.Fatalf("eth_callMany: %v", err)
+		}
+		balance, err := strconv.ParseUint(fmt.Sprintf("%v", res[0].Results[0]["value"]), 16, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance != c.expected {
+			t.Errorf("eth_callMany: %v balance %d, expected %d", c.number, balance, c.expected)
+		}
+	}
+}
+
+func TestCallManyLimits(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		timeout      = int64(50000)
+		call         = ethapi.CallArgs{From: &address, To: &address}
+		stateContext = StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}
+	)
+	api.MaxBundles, api.MaxTxPerBundle = 2, 3
+
+	if _, err := api.CallMany(ctx, []Bundle{
+		{Transactions: []ethapi.CallArgs{call, call, call}},
+		{Transactions: []ethapi.CallArgs{call}},
+	}, stateContext, nil, &timeout); err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	if _, err := api.CallMany(ctx, []Bundle{
+		{Transactions: []ethapi.CallArgs{call}},
+		{Transactions: []ethapi.CallArgs{call}},
+		{Transactions: []ethapi.CallArgs{call}},
+	}, stateContext, nil, &timeout); err == nil || !strings.Contains(err.Error(), "too many bundles") {
+		t.Errorf("eth_callMany: expected too many bundles error, got %v", err)
+	}
+	if _, err := api.CallMany(ctx, []Bundle{
+		{Transactions: []ethapi.CallArgs{call}},
+		{Transactions: []ethapi.CallArgs{call, call, call, call}},
+	}, stateContext, nil, &timeout); err == nil || !strings.Contains(err.Error(), "too many transactions in bundle 1") {
+		t.Errorf("eth_callMany: expected too many transactions error, got %v", err)
+	}
+}
+
+func TestCallManyStorageAccess(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		contractAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		// PUSH1 1 PUSH1 2 SSTORE PUSH1 3 SLOAD STOP
+		code      = hexutil.Bytes{0x60, 0x01, 0x60, 0x02, 0x55, 0x60, 0x03, 0x54, 0x00}
+		overrides = ethapi.StateOverrides{contractAddr: ethapi.Account{Code: &code}}
+		timeout   = int64(50000)
+	)
+	res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{{From: &address, To: &contractAddr}}, StorageAccess: true, Trace: true}},
+		StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}, &overrides, &timeout)
+	if err != nil {
+		t.Fatalf("eth_callMany: %v", err)
+	}
+	storageAccess := res[0].Results[0]["storageAccess"].(map[common.Address]map[string][]common.Hash)
+	if reads := storageAccess[contractAddr]["reads"]; len(reads) != 1 || reads[0] != common.BigToHash(big.NewInt(3)) {
+		t.Errorf("eth_callMany: unexpected storage reads %v", reads)
+	}
+	if writes := storageAccess[contractAddr]["writes"]; len(writes) != 1 || writes[0] != common.BigToHash(big.NewInt(2)) {
+		t.Errorf("eth_callMany: unexpected storage writes %v", writes)
+	}
+	if _, ok := res[0].Results[0]["trace"]; !ok {
+		t.Error("eth_callMany: expected the call trace alongside the storage access")
+	}
+}
+
+func TestCallManyNoBaseFee(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		poor    = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		timeout = int64(50000)
+	)
+	for _, c := range []struct {
+		name   string
+		config *chain.Config
+		call   ethapi.CallArgs
+	}{
+		{"legacy gas price", chain.TestChainConfig, ethapi.CallArgs{From: &poor, To: &poor, GasPrice: (*hexutil.Big)(big.NewInt(1))}},
+		{"below base fee", chain.AllProtocolChanges, ethapi.CallArgs{From: &poor, To: &poor}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			api, _ := newCallManyTestAPIWithConfig(t, c.config)
+			stateContext := StateContext{BlockNumber: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)}
+			if _, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{c.call}}}, stateContext, nil, &timeout); err == nil {
+				t.Fatal("eth_callMany: expected the call to fail the fee checks")
+			}
+			stateContext.NoBaseFee = true
+			res, err := api.CallMany(ctx, []Bundle{{Transactions: []ethapi.CallArgs{c.call}}}, stateContext, nil, &timeout)
+			if err != nil {
+				t.Fatalf("eth_callMany: %v", err)
+			}
+			if _, ok := res[0].Results[0]["value"]; !ok {
+				t.Errorf("eth_callMany: expected success, got %v", res[0].Results[0])
+			}
+		})
+	}
 }
@@ -0,0 +1,458 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/empty"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/math"
+	"github.com/erigontech/erigon-lib/crypto"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/types"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/tracing"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/execution/consensus/misc"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/rpc/ethapi"
+	"github.com/erigontech/erigon/rpc/rpchelper"
+)
+
+// maxSimulateBlocks is the maximum number of blocks a single eth_simulateV1
+// request may simulate.
+const maxSimulateBlocks = 256
+
+var (
+	// transferLogAddress is the pseudo-address emitting the synthetic ether
+	// Transfer logs when traceTransfers is requested.
+	transferLogAddress = common.HexToAddress("0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE")
+	transferLogTopic   = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// SimulationRequest is the payload of eth_simulateV1.
+type SimulationRequest struct {
+	BlockStateCalls        []SimulatedBlock `json:"blockStateCalls"`
+	TraceTransfers         bool             `json:"traceTransfers"`
+	Validation             bool             `json:"validation"`
+	ReturnFullTransactions bool             `json:"returnFullTransactions"`
+}
+
+// SimulatedBlock is a block built on top of the previous one, with its calls
+// executed after the block and state overrides are applied.
+type SimulatedBlock struct {
+	BlockOverrides *ethapi.BlockOverrides `json:"blockOverrides"`
+	StateOverrides *ethapi.StateOverrides `json:"stateOverrides"`
+	Calls          []ethapi.CallArgs      `json:"calls"`
+}
+
+// SimulateV1 implements eth_simulateV1. It executes a sequence of blocks of calls on top of the given block,
+// the same way CallMany executes bundles, and returns the simulated blocks with per-call results.
+func (api *APIImpl) SimulateV1(ctx context.Context, req SimulationRequest, blockNrOrHash *rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if len(req.BlockStateCalls) == 0 {
+		return nil, errors.New("empty input")
+	}
+	if len(req.BlockStateCalls) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks: %d, max %d", len(req.BlockStateCalls), maxSimulateBlocks)
+	}
+	if blockNrOrHash == nil {
+		blockNrOrHash = &latestNumOrHash
+	}
+
+	tx, err := api.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	chainConfig, err := api.chainConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(start time.Time) { log.Trace("Executing EVM simulateV1 finished", "runtime", time.Since(start)) }(time.Now())
+
+	parent, _, err := headerByNumberOrHash(ctx, tx, *blockNrOrHash, api)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, errors.New("header not found")
+	}
+	stateReader, err := rpchelper.CreateStateReader(ctx, tx, api._blockReader, *blockNrOrHash, 0, api.filters, api.stateCache, api._txNumReader)
+	if err != nil {
+		return nil, err
+	}
+	ibs := state.New(stateReader)
+
+	// the hashes of the simulated blocks override the canonical ones
+	simulatedHashes := make(map[uint64]common.Hash)
+	getHash := api.overriddenHashFn(ctx, tx, simulatedHashes)
+
+	var tracer *transferTracer
+	vmConfig := vm.Config{NoBaseFee: !req.Validation}
+	if req.TraceTransfers {
+		tracer = &transferTracer{}
+		vmConfig.Tracer = tracer.Hooks()
+		ibs.SetHooks(vmConfig.Tracer)
+	}
+	ctx, cancel := withCallTimeout(ctx, api.evmCallTimeout)
+	defer cancel()
+
+	// The intra-block state is shared by all the simulated blocks, so its tx
+	// index keeps counting across them: restarting it would hand a call the
+	// logs of the same position in the previous blocks.
+	var ibsTxIndex int
+	ret := make([]map[string]interface{}, 0, len(req.BlockStateCalls))
+	for _, block := range req.BlockStateCalls {
+		header, err := simulatedHeader(chainConfig, parent, block.BlockOverrides, req.Validation)
+		if err != nil {
+			return nil, err
+		}
+		blockCtx := core.NewEVMBlockContext(header, getHash, api.engine(), nil /* author */, chainConfig)
+		if block.BlockOverrides != nil && block.BlockOverrides.BlobBaseFee != nil {
+			blobBaseFee, overflow := uint256.FromBig(block.BlockOverrides.BlobBaseFee.ToInt())
+			if overflow {
+				return nil, errors.New("BlockOverrides.BlobBaseFee uint256 overflow")
+			}
+			blockCtx.BlobBaseFee = blobBaseFee
+		}
+		rules := chainConfig.Rules(header.Number.Uint64(), header.Time)
+
+		if block.StateOverrides != nil {
+			if err = block.StateOverrides.Override(ibs); err != nil {
+				return nil, err
+			}
+		}
+
+		var (
+			gp       = new(core.GasPool).AddGas(header.GasLimit).AddBlobGas(math.MaxUint64)
+			txs      = make(types.Transactions, 0, len(block.Calls))
+			receipts = make(types.Receipts, 0, len(block.Calls))
+			senders  = make([]common.Address, 0, len(block.Calls))
+			results  = make([]map[string]interface{}, 0, len(block.Calls))
+		)
+		for txIndex, call := range block.Calls {
+			if call.Gas == nil || *call.Gas == 0 {
+				remaining := min(api.GasCap, gp.Gas())
+				call.Gas = (*hexutil.Uint64)(&remaining)
+			}
+			if call.ChainID == nil {
+				call.ChainID = (*hexutil.Big)(chainConfig.ChainID)
+			}
+			from := common.Address{}
+			if call.From != nil {
+				from = *call.From
+			}
+			nonce, err := ibs.GetNonce(from)
+			if err != nil {
+				return nil, err
+			}
+			if call.Nonce == nil {
+				// the nonce tells apart the transactions of identical calls
+				call.Nonce = (*hexutil.Uint64)(&nonce)
+			} else if req.Validation && uint64(*call.Nonce) != nonce {
+				return nil, fmt.Errorf("invalid nonce: address %v, nonce: %d, expected: %d", from, uint64(*call.Nonce), nonce)
+			}
+			msg, err := call.ToMessage(api.GasCap, blockCtx.BaseFee)
+			if err != nil {
+				return nil, err
+			}
+			txn, err := call.ToTransaction(api.GasCap, blockCtx.BaseFee)
+			if err != nil {
+				return nil, err
+			}
+
+			ibs.SetTxContext(header.Number.Uint64(), ibsTxIndex)
+			ibsTxIndex++
+			if tracer != nil {
+				tracer.reset()
+			}
+			evm := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), ibs, chainConfig, vmConfig)
+			result, err := applyMessageUntil(ctx, evm, msg, gp, false /* gasBailout */, api.engine())
+			if err != nil {
+				return nil, err
+			}
+			if err = ibs.FinalizeTx(rules, state.NewNoopWriter()); err != nil {
+				return nil, err
+			}
+			// If the timer caused an abort, return an appropriate error message
+			if evm.Cancelled() {
+				return nil, fmt.Errorf("execution aborted (timeout = %v)", api.evmCallTimeout)
+			}
+
+			logs := ibs.GetRawLogs(ibsTxIndex - 1)
+			if tracer != nil {
+				logs = tracer.logs()
+			}
+			receipt := &types.Receipt{
+				Type:             txn.Type(),
+				Status:           types.ReceiptStatusSuccessful,
+				GasUsed:          result.GasUsed,
+				Logs:             logs,
+				TxHash:           txn.Hash(),
+				TransactionIndex: uint(txIndex),
+			}
+			if result.Failed() {
+				receipt.Status = types.ReceiptStatusFailed
+			}
+			receipt.CumulativeGasUsed = result.GasUsed
+			if len(receipts) > 0 {
+				receipt.CumulativeGasUsed += receipts[len(receipts)-1].CumulativeGasUsed
+			}
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			if header.BlobGasUsed != nil {
+				*header.BlobGasUsed += txn.GetBlobGas()
+			}
+			txs = append(txs, txn)
+			senders = append(senders, from)
+			receipts = append(receipts, receipt)
+
+			callResult := map[string]interface{}{
+				"returnData": hexutil.Bytes(result.Return()),
+				"logs":       logs,
+				"gasUsed":    hexutil.Uint64(result.GasUsed),
+				"status":     hexutil.Uint64(receipt.Status),
+			}
+			if result.Err != nil {
+				if len(result.Revert()) > 0 {
+					revertErr := ethapi.NewRevertError(result)
+					callResult["returnData"] = hexutil.Bytes(result.Revert())
+					callResult["error"] = map[string]interface{}{
+						"code":    revertErr.ErrorCode(),
+						"message": revertErr.Error(),
+						"data":    revertErr.ErrorData(),
+					}
+				} else {
+					callResult["error"] = map[string]interface{}{
+						"code":    -32015,
+						"message": result.Err.Error(),
+					}
+				}
+			}
+			results = append(results, callResult)
+		}
+
+		var withdrawals []*types.Withdrawal
+		if block.BlockOverrides != nil {
+			withdrawals = block.BlockOverrides.Withdrawals
+		}
+		for _, w := range withdrawals {
+			amount := new(uint256.Int).Mul(uint256.NewInt(w.Amount), uint256.NewInt(common.GWei))
+			if err = ibs.AddBalance(w.Address, *amount, tracing.BalanceIncreaseWithdrawal); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(receipts) > 0 {
+			header.GasUsed = receipts[len(receipts)-1].CumulativeGasUsed
+		}
+		header.TxHash = types.DeriveSha(txs)
+		header.ReceiptHash = types.DeriveSha(receipts)
+		header.Bloom = types.CreateBloom(receipts)
+		blockHash := header.Hash()
+		simulatedHashes[header.Number.Uint64()] = blockHash
+
+		// Now that the block hash is known, fill in the inclusion information.
+		var logIndex uint
+		for i, receipt := range receipts {
+			for _, l := range receipt.Logs {
+				l.BlockNumber = header.Number.Uint64()
+				l.BlockHash = blockHash
+				l.TxHash = receipt.TxHash
+				l.TxIndex = uint(i)
+				l.Index = logIndex
+				logIndex++
+			}
+		}
+
+		fields := ethapi.RPCMarshalHeader(header)
+		transactions := make([]interface{}, len(txs))
+		for i, txn := range txs {
+			if req.ReturnFullTransactions {
+				transactions[i] = simulatedTransaction(txn, senders[i], blockHash, header.Number.Uint64(), i)
+			} else {
+				transactions[i] = txn.Hash()
+			}
+		}
+		fields["transactions"] = transactions
+		fields["calls"] = results
+		ret = append(ret, fields)
+
+		parent = header
+	}
+	return ret, nil
+}
+
+// simulatedHeader derives the header of the block following parent, with the
+// given overrides applied. Without validation the base fee defaults to zero,
+// so calls without fee fields are not rejected.
+func simulatedHeader(chainConfig *chain.Config, parent *types.Header, overrides *ethapi.BlockOverrides, validation bool) (*types.Header, error) {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		UncleHash:  empty.UncleHash,
+		Coinbase:   parent.Coinbase,
+		Difficulty: new(big.Int),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + 12,
+		MixDigest:  parent.MixDigest,
+	}
+	if chainConfig.IsLondon(header.Number.Uint64()) {
+		header.BaseFee = misc.CalcBaseFee(chainConfig, parent)
+		if !validation {
+			header.BaseFee = new(big.Int)
+		}
+	}
+	if overrides != nil {
+		if err := overrideHeader(header, parent, overrides); err != nil {
+			return nil, err
+		}
+	}
+	setCancunFields(chainConfig, parent, header)
+	return header, nil
+}
+
+// overrideHeader applies the block overrides of a simulated block to its header.
+func overrideHeader(header, parent *types.Header, overrides *ethapi.BlockOverrides) error {
+	if overrides.Number != nil {
+		if overrides.Number.ToInt().Cmp(parent.Number) <= 0 {
+			return fmt.Errorf("block numbers must be in order: %d <= %d", overrides.Number.ToInt(), parent.Number)
+		}
+		header.Number = new(big.Int).Set(overrides.Number.ToInt())
+	}
+	if overrides.Time != nil {
+		if uint64(*overrides.Time) <= parent.Time {
+			return fmt.Errorf("block timestamps must be in order: %d <= %d", uint64(*overrides.Time), parent.Time)
+		}
+		header.Time = uint64(*overrides.Time)
+	}
+	if overrides.GasLimit != nil {
+		header.GasLimit = uint64(*overrides.GasLimit)
+	}
+	if overrides.FeeRecipient != nil {
+		header.Coinbase = *overrides.FeeRecipient
+	}
+	if overrides.PrevRanDao != nil {
+		header.MixDigest = *overrides.PrevRanDao
+	}
+	if overrides.BaseFeePerGas != nil {
+		header.BaseFee = new(big.Int).Set(overrides.BaseFeePerGas.ToInt())
+	}
+	return nil
+}
+
+// setCancunFields fills the blob gas fields of a simulated header, which give the
+// blob base fee of its block, and a zero parent beacon block root.
+func setCancunFields(chainConfig *chain.Config, parent, header *types.Header) {
+	if !chainConfig.IsCancun(header.Time) {
+		return
+	}
+	excessBlobGas := misc.CalcExcessBlobGas(chainConfig, parent, header.Time)
+	header.ExcessBlobGas = &excessBlobGas
+	header.BlobGasUsed = new(uint64)
+	header.ParentBeaconBlockRoot = new(common.Hash)
+}
+
+// simulatedTransaction marshals an unsigned simulated transaction. It can't go
+// through ethapi.NewRPCTransaction, which recovers the sender from the signature.
+func simulatedTransaction(txn types.Transaction, from common.Address, blockHash common.Hash, blockNumber uint64, index int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":             hexutil.Uint64(txn.Type()),
+		"hash":             txn.Hash(),
+		"from":             from,
+		"to":               txn.GetTo(),
+		"nonce":            hexutil.Uint64(txn.GetNonce()),
+		"gas":              hexutil.Uint64(txn.GetGasLimit()),
+		"value":            (*hexutil.Big)(txn.GetValue().ToBig()),
+		"input":            hexutil.Bytes(txn.GetData()),
+		"blockHash":        blockHash,
+		"blockNumber":      (*hexutil.Big)(new(big.Int).SetUint64(blockNumber)),
+		"transactionIndex": hexutil.Uint64(index),
+	}
+}
+
+// transferTracer collects the logs of a simulated call, adding a synthetic
+// ERC-20 like Transfer log for every ether transfer. Logs of reverted frames
+// are dropped together with the frame.
+type transferTracer struct {
+	frames []types.Logs
+}
+
+func (t *transferTracer) Hooks() *tracing.Hooks {
+	return &tracing.Hooks{
+		OnEnter: t.onEnter,
+		OnExit:  t.onExit,
+		OnLog:   t.onLog,
+	}
+}
+
+func (t *transferTracer) reset() {
+	t.frames = []types.Logs{nil}
+}
+
+func (t *transferTracer) logs() types.Logs {
+	if len(t.frames) == 0 {
+		return nil
+	}
+	return t.frames[0]
+}
+
+func (t *transferTracer) onEnter(depth int, typ byte, from common.Address, to common.Address, precompile bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+	t.frames = append(t.frames, nil)
+	op := vm.OpCode(typ)
+	if value == nil || value.IsZero() || op == vm.DELEGATECALL || op == vm.STATICCALL {
+		return
+	}
+	amount := value.Bytes32()
+	t.onLog(&types.Log{
+		Address: transferLogAddress,
+		Topics: []common.Hash{
+			transferLogTopic,
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: amount[:],
+	})
+}
+
+func (t *transferTracer) onExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(t.frames) < 2 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	t.frames = t.frames[:len(t.frames)-1]
+	if !reverted {
+		t.frames[len(t.frames)-1] = append(t.frames[len(t.frames)-1], frame...)
+	}
+}
+
+func (t *transferTracer) onLog(l *types.Log) {
+	if len(t.frames) == 0 {
+		t.reset()
+	}
+	t.frames[len(t.frames)-1] = append(t.frames[len(t.frames)-1], l)
+}
@@ -0,0 +1,193 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/chain/params"
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/types"
+	"github.com/erigontech/erigon/execution/consensus/misc"
+	"github.com/erigontech/erigon/rpc/ethapi"
+)
+
+func TestSimulateV1(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		ctx          = context.Background()
+		contractAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		revertAddr   = common.HexToAddress("0x1000000000000000000000000000000000000002")
+		recipient    = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		// TIMESTAMP PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		timestampCode = hexutil.Bytes{0x42, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+		// PUSH1 0 PUSH1 0 REVERT
+		revertCode = hexutil.Bytes{0x60, 0x00, 0x60, 0x00, 0xfd}
+		timestamp  = hexutil.Uint64(1_234_567_890)
+	)
+
+	res, err := api.SimulateV1(ctx, SimulationRequest{
+		TraceTransfers: true,
+		BlockStateCalls: []SimulatedBlock{
+			{
+				StateOverrides: &ethapi.StateOverrides{
+					contractAddr: ethapi.Account{Code: &timestampCode},
+					revertAddr:   ethapi.Account{Code: &revertCode},
+				},
+				Calls: []ethapi.CallArgs{
+					{From: &address, To: &recipient, Value: (*hexutil.Big)(big.NewInt(1000))},
+					{From: &address, To: &revertAddr},
+				},
+			},
+			{
+				BlockOverrides: &ethapi.BlockOverrides{Time: &timestamp},
+				Calls:          []ethapi.CallArgs{{From: &address, To: &contractAddr}},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	// blocks are chained on top of each other
+	require.Equal(t, (*hexutil.Big)(big.NewInt(2)), res[0]["number"])
+	require.Equal(t, (*hexutil.Big)(big.NewInt(3)), res[1]["number"])
+	require.Equal(t, res[0]["hash"], res[1]["parentHash"])
+	require.Equal(t, hexutil.Uint64(timestamp), res[1]["timestamp"])
+
+	calls := res[0]["calls"].([]map[string]interface{})
+	require.Len(t, calls, 2)
+	require.Equal(t, hexutil.Uint64(types.ReceiptStatusSuccessful), calls[0]["status"])
+	require.Equal(t, hexutil.Uint64(21000), calls[0]["gasUsed"])
+	logs := calls[0]["logs"].(types.Logs)
+	require.Len(t, logs, 1)
+	require.Equal(t, transferLogAddress, logs[0].Address)
+	require.Equal(t, []common.Hash{transferLogTopic, common.BytesToHash(address.Bytes()), common.BytesToHash(recipient.Bytes())}, logs[0].Topics)
+	require.Equal(t, int64(1000), new(big.Int).SetBytes(logs[0].Data).Int64())
+
+	require.Equal(t, hexutil.Uint64(types.ReceiptStatusFailed), calls[1]["status"])
+	require.Contains(t, calls[1], "error")
+
+	calls = res[1]["calls"].([]map[string]interface{})
+	require.Len(t, calls, 1)
+	require.Equal(t, uint64(timestamp), new(big.Int).SetBytes(calls[0]["returnData"].(hexutil.Bytes)).Uint64())
+}
+
+func TestSimulateV1BlockOrder(t *testing.T) {
+	api, address := newCallManyTestAPI(t)
+	number := (*hexutil.Big)(big.NewInt(1))
+	_, err := api.SimulateV1(context.Background(), SimulationRequest{
+		BlockStateCalls: []SimulatedBlock{{
+			BlockOverrides: &ethapi.BlockOverrides{Number: number},
+			Calls:          []ethapi.CallArgs{{From: &address, To: &address}},
+		}},
+	}, nil)
+	require.Error(t, err)
+}
+
+func TestSimulateV1Cancun(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPIWithConfig(t, chain.AllProtocolChanges)
+		contractAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		// BLOBBASEFEE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		blobBaseFeeCode = hexutil.Bytes{0x4a, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+		blobHash        = common.HexToHash("0x01a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	)
+	blobCall := ethapi.CallArgs{
+		From:                &address,
+		To:                  &contractAddr,
+		MaxFeePerGas:        (*hexutil.Big)(big.NewInt(1_000_000_000)),
+		MaxFeePerBlobGas:    (*hexutil.Big)(big.NewInt(1_000_000_000)),
+		BlobVersionedHashes: []common.Hash{blobHash},
+	}
+	res, err := api.SimulateV1(context.Background(), SimulationRequest{
+		BlockStateCalls: []SimulatedBlock{
+			{
+				StateOverrides: &ethapi.StateOverrides{contractAddr: ethapi.Account{Code: &blobBaseFeeCode}},
+				Calls:          []ethapi.CallArgs{blobCall},
+			},
+			{Calls: []ethapi.CallArgs{{From: &address, To: &contractAddr}}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	require.Equal(t, hexutil.Uint64(params.BlobGasPerBlob), *res[0]["blobGasUsed"].(*hexutil.Uint64))
+	require.Equal(t, common.Hash{}, *res[0]["parentBeaconBlockRoot"].(*common.Hash))
+	for _, block := range res {
+		excessBlobGas := uint64(*block["excessBlobGas"].(*hexutil.Uint64))
+		want, err := misc.GetBlobGasPrice(chain.AllProtocolChanges, excessBlobGas, uint64(block["timestamp"].(hexutil.Uint64)))
+		require.NoError(t, err)
+		calls := block["calls"].([]map[string]interface{})
+		require.Equal(t, hexutil.Uint64(types.ReceiptStatusSuccessful), calls[0]["status"])
+		require.Equal(t, want.Uint64(), new(big.Int).SetBytes(calls[0]["returnData"].(hexutil.Bytes)).Uint64())
+	}
+}
+
+func TestSimulateV1Nonces(t *testing.T) {
+	api, address := newCallManyTestAPI(t)
+	call := ethapi.CallArgs{From: &address, To: &address}
+	res, err := api.SimulateV1(context.Background(), SimulationRequest{
+		ReturnFullTransactions: true,
+		BlockStateCalls:        []SimulatedBlock{{Calls: []ethapi.CallArgs{call, call}}},
+	}, nil)
+	require.NoError(t, err)
+
+	// identical calls without a nonce take the next nonces of their sender
+	txs := res[0]["transactions"].([]interface{})
+	require.Len(t, txs, 2)
+	first, second := txs[0].(map[string]interface{}), txs[1].(map[string]interface{})
+	require.Equal(t, first["nonce"].(hexutil.Uint64)+1, second["nonce"])
+	require.NotEqual(t, first["hash"], second["hash"])
+}
+
+func TestSimulateV1LogsPerBlock(t *testing.T) {
+	var (
+		api, address = newCallManyTestAPI(t)
+		contractAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		// PUSH1 0 PUSH1 0 LOG0 STOP
+		logCode = hexutil.Bytes{0x60, 0x00, 0x60, 0x00, 0xa0, 0x00}
+		call    = ethapi.CallArgs{From: &address, To: &contractAddr}
+	)
+	res, err := api.SimulateV1(context.Background(), SimulationRequest{
+		BlockStateCalls: []SimulatedBlock{
+			{
+				StateOverrides: &ethapi.StateOverrides{contractAddr: ethapi.Account{Code: &logCode}},
+				Calls:          []ethapi.CallArgs{call},
+			},
+			{Calls: []ethapi.CallArgs{call}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	// the first call of each block only sees the log it emitted itself
+	for _, block := range res {
+		calls := block["calls"].([]map[string]interface{})
+		logs := calls[0]["logs"].(types.Logs)
+		require.Len(t, logs, 1)
+		require.Equal(t, contractAddr, logs[0].Address)
+		require.Equal(t, block["number"].(*hexutil.Big).Uint64(), logs[0].BlockNumber)
+		require.Equal(t, uint(0), logs[0].TxIndex)
+		require.Equal(t, uint(0), logs[0].Index)
+	}
+}
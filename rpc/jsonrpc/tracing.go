@@ -556,7 +556,8 @@ func (api *PrivateDebugAPIImpl) TraceCallMany(ctx context.Context, bundles []Bun
 	evm = vm.NewEVM(blockCtx, txCtx, ibs, chainConfig, vm.Config{})
 	rules := chainConfig.Rules(blockNum, blockCtx.Time)
 
-	// after replaying the txns, we want to overload the state
+	// after replaying the txns, we want to overload the block context and the state
+	blockHeaderOverride(&blockCtx, simulateContext.BlockOverride, overrideBlockHash)
 	if config.StateOverrides != nil {
 		err = config.StateOverrides.Override(ibs)
 		if err != nil {
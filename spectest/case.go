@@ -3,12 +3,11 @@ package spectest
 import (
 	"io/fs"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/erigontech/erigon/cl/clparams"
 	"github.com/erigontech/erigon/cl/transition/machine"
-
-	"gfx.cafe/util/go/generic"
 )
 
 type TestCase struct {
@@ -27,22 +26,22 @@ func (t *TestCase) Version() clparams.StateVersion {
 	return v
 }
 
+// pathElements returns the directories of the case, from the config down.
+func (t *TestCase) pathElements() []string {
+	return []string{t.ConfigName, t.ForkPhaseName, t.RunnerName, t.HandlerName, t.SuiteName, t.CaseName}
+}
+
+// Path returns the directory of the case relative to the tests root.
+func (t *TestCase) Path() string {
+	return path.Join(t.pathElements()...)
+}
+
 type TestCases struct {
-	tc   []TestCase
-	tree generic.Map6[string, string, string, string, string, string, TestCase]
+	tc []TestCase
 }
 
 func (tx *TestCases) add(t TestCase) {
 	tx.tc = append(tx.tc, t)
-	tx.tree.Store(
-		t.ConfigName,
-		t.ForkPhaseName,
-		t.RunnerName,
-		t.HandlerName,
-		t.SuiteName,
-		t.CaseName,
-		t,
-	)
 }
 
 func (t *TestCases) Slice() []TestCase {
@@ -2,91 +2,71 @@ package spectest
 
 import (
 	"io/fs"
-	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/erigontech/erigon/cl/transition/machine"
 
-	"gfx.cafe/util/go/generic"
 	"github.com/stretchr/testify/require"
 )
 
+// RunCases runs every case under root as a subtest named after its path. The
+// cases run in parallel, at most -parallel at a time, and each one reads its
+// own fixtures so that no state is shared between them.
 func RunCases(t *testing.T, app Appendix, machineImpl machine.Interface, root fs.FS) {
 	cases, err := ReadTestCases(root)
 	require.NoError(t, err, "reading cases")
-	// prepare for gore.....
-	type (
-		K1 = string
-		K2 = string
-		K3 = string
-		K4 = string
-		K5 = string
-		V  = TestCase
-	)
-	// welcome to hell
-	cases.tree.Range0(func(s string, m *generic.Map5[K1, K2, K3, K4, K5, V]) bool {
-		t.Run(s, func(t *testing.T) {
+	sorted := slices.Clone(cases.Slice())
+	slices.SortStableFunc(sorted, func(a, b TestCase) int {
+		return slices.Compare(a.pathElements(), b.pathElements())
+	})
+	sorted = slices.CompactFunc(sorted, func(a, b TestCase) bool {
+		return a.Path() == b.Path()
+	})
+	runCaseTree(t, sorted, 0, func(t *testing.T, c TestCase) {
+		runCase(t, app, machineImpl, root, c)
+	})
+}
+
+// runCaseTree runs the sorted cases as nested parallel subtests, one level per
+// element of their path, so that -run filters like /mainnet/deneb/ keep working.
+func runCaseTree(t *testing.T, cases []TestCase, level int, run func(t *testing.T, c TestCase)) {
+	for len(cases) > 0 {
+		name := cases[0].pathElements()[level]
+		n := 1
+		for n < len(cases) && cases[n].pathElements()[level] == name {
+			n++
+		}
+		group := cases[:n]
+		cases = cases[n:]
+		t.Run(name, func(t *testing.T) {
 			t.Parallel()
-			m.Range0(func(s string, m *generic.Map4[K1, K2, K3, K4, V]) bool {
-				t.Run(s, func(t *testing.T) {
-					t.Parallel()
-					m.Range0(func(s string, m *generic.Map3[K1, K2, K3, V]) bool {
-						t.Run(s, func(t *testing.T) {
-							t.Parallel()
-							m.Range0(func(s string, m *generic.Map2[K1, K2, V]) bool {
-								t.Run(s, func(t *testing.T) {
-									t.Parallel()
-									m.Range0(func(s string, m *generic.Map1[K1, V]) bool {
-										t.Run(s, func(t *testing.T) {
-											t.Parallel()
-											m.Range0(func(key string, value TestCase) bool {
-												if value.ForkPhaseName == "whisk" || value.ForkPhaseName == "eip7594" {
-													t.Skipf("skipping %s", value.ForkPhaseName)
-													return true
-												}
-												t.Run(key, func(t *testing.T) {
-													require.NotPanics(t, func() {
-														t.Parallel()
-														runner, ok := app[value.RunnerName]
-														if !ok {
-															t.Skipf("runner not found: %s", value.RunnerName)
-															return
-														}
-														handler, err := runner.GetHandler(value.HandlerName)
-														if err != nil {
-															t.Skipf("handler not found: %s/%s", value.RunnerName, value.HandlerName)
-															return
-														}
-														subfs, err := fs.Sub(root, filepath.Join(
-															value.ConfigName,
-															value.ForkPhaseName,
-															value.RunnerName,
-															value.HandlerName,
-															value.SuiteName,
-															value.CaseName,
-														))
-														value.Machine = machineImpl
-														require.NoError(t, err)
-														err = handler.Run(t, subfs, value)
-														require.NoError(t, err)
-													})
-												})
-												return true
-											})
-										})
-										return true
-									})
-								})
-								return true
-							})
-						})
-						return true
-					})
-				})
-				return true
-			})
+			if level == len(group[0].pathElements())-1 {
+				run(t, group[0])
+				return
+			}
+			runCaseTree(t, group, level+1, run)
 		})
-		return true
-	})
+	}
+}
 
+func runCase(t *testing.T, app Appendix, machineImpl machine.Interface, root fs.FS, c TestCase) {
+	if c.ForkPhaseName == "whisk" || c.ForkPhaseName == "eip7594" {
+		t.Skipf("skipping %s", c.ForkPhaseName)
+	}
+	runner, ok := app[c.RunnerName]
+	if !ok {
+		t.Skipf("runner not found: %s", c.RunnerName)
+	}
+	handler, err := runner.GetHandler(c.HandlerName)
+	if err != nil {
+		t.Skipf("handler not found: %s/%s", c.RunnerName, c.HandlerName)
+	}
+	subfs, err := fs.Sub(root, c.Path())
+	require.NoError(t, err, "case %s", c.Path())
+	c.Machine = machineImpl
+	require.NotPanics(t, func() {
+		err = handler.Run(t, subfs, c)
+	}, "case %s", c.Path())
+	require.NoError(t, err, "case %s", c.Path())
 }
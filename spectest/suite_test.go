@@ -0,0 +1,41 @@
+package spectest
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCases(t *testing.T) {
+	root := os.DirFS("data_faketest/tests")
+	cases, err := ReadTestCases(root)
+	require.NoError(t, err)
+	require.Len(t, cases.Slice(), 20)
+
+	var mu sync.Mutex
+	ran := map[string]int{}
+	record := HandlerFunc(func(t *testing.T, root fs.FS, c TestCase) error {
+		// the case sees its own directory
+		_, err := fs.Stat(root, "data.yaml")
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		ran[c.Path()]++
+		return nil
+	})
+	app := Appendix{}
+	app.Add("bls").
+		With("eth_aggregate_pubkeys", record).
+		With("eth_fast_aggregate_verify", record)
+
+	t.Run("cases", func(t *testing.T) {
+		RunCases(t, app, nil, root)
+	})
+	require.Len(t, ran, len(cases.Slice()))
+	for _, c := range cases.Slice() {
+		require.Equal(t, 1, ran[c.Path()], c.Path())
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
+	"strings"
 
 	clparams2 "github.com/erigontech/erigon/cl/clparams"
 	"github.com/erigontech/erigon/cl/cltypes"
@@ -15,6 +17,10 @@ import (
 	"github.com/erigontech/erigon-lib/types/ssz"
 )
 
+// The read errors of the helpers below are *fs.PathError, which name the file
+// and which callers check with os.IsNotExist, so only the decode errors are
+// wrapped with the file name.
+
 func ReadMeta(root fs.FS, name string, obj any) error {
 	bts, err := fs.ReadFile(root, name)
 	if err != nil {
@@ -22,7 +28,7 @@ func ReadMeta(root fs.FS, name string, obj any) error {
 	}
 	err = yaml.Unmarshal(bts, obj)
 	if err != nil {
-		return fmt.Errorf("couldnt parse meta: %w", err)
+		return fmt.Errorf("couldnt parse meta %s: %w", name, err)
 	}
 	return nil
 }
@@ -30,21 +36,49 @@ func ReadMeta(root fs.FS, name string, obj any) error {
 func ReadYml(root fs.FS, name string, obj any) error {
 	bts, err := fs.ReadFile(root, name)
 	if err != nil {
-		return fmt.Errorf("couldnt read meta: %w", err)
+		return fmt.Errorf("couldnt read yaml: %w", err)
 	}
 	err = yaml.Unmarshal(bts, obj)
 	if err != nil {
-		return fmt.Errorf("couldnt parse meta: %w", err)
+		return fmt.Errorf("couldnt parse yaml %s: %w", name, err)
 	}
 	return nil
 }
 
+// decodeSsz decodes the fixture name into obj, the fixtures with the .ssz
+// extension are not snappy compressed.
+func decodeSsz(name string, bts []byte, obj ssz.Unmarshaler, version clparams2.StateVersion) error {
+	var err error
+	if path.Ext(name) == ".ssz" {
+		err = obj.DecodeSSZ(bts, int(version))
+	} else {
+		err = utils.DecodeSSZSnappy(obj, bts, int(version))
+	}
+	if err != nil {
+		return fmt.Errorf("couldnt decode %s: %w", name, err)
+	}
+	return nil
+}
+
+// readIndexedSsz reads the fixture prefix_index, compressed or not.
+func readIndexedSsz(root fs.FS, prefix string, index int) (name string, bts []byte, err error) {
+	name = fmt.Sprintf("%s_%d.ssz_snappy", prefix, index)
+	bts, err = fs.ReadFile(root, name)
+	if os.IsNotExist(err) {
+		name = fmt.Sprintf("%s_%d.ssz", prefix, index)
+		if raw, rawErr := fs.ReadFile(root, name); !os.IsNotExist(rawErr) {
+			bts, err = raw, rawErr
+		}
+	}
+	return name, bts, err
+}
+
 func ReadSsz(root fs.FS, version clparams2.StateVersion, name string, obj ssz.Unmarshaler) error {
 	bts, err := fs.ReadFile(root, name)
 	if err != nil {
-		return fmt.Errorf("couldnt read meta: %w", err)
+		return fmt.Errorf("couldnt read ssz: %w", err)
 	}
-	return utils.DecodeSSZSnappy(obj, bts, int(version))
+	return decodeSsz(name, bts, obj, version)
 }
 
 func ReadSszOld(root fs.FS, obj ssz.Unmarshaler, version clparams2.StateVersion, name string) error {
@@ -52,22 +86,51 @@ func ReadSszOld(root fs.FS, obj ssz.Unmarshaler, version clparams2.StateVersion,
 }
 
 func ReadBeaconState(root fs.FS, version clparams2.StateVersion, name string) (*state.CachingBeaconState, error) {
-	sszSnappy, err := fs.ReadFile(root, name)
+	bts, err := fs.ReadFile(root, name)
 	if err != nil {
 		return nil, err
 	}
 	config := clparams2.MainnetBeaconConfig
 	testState := state.New(&config)
-	if err := utils.DecodeSSZSnappy(testState, sszSnappy, int(version)); err != nil {
+	if err := decodeSsz(name, bts, testState, version); err != nil {
 		return nil, err
 	}
 	return testState, nil
 }
 
+// VersionFromPath returns the fork of the fixture at p, named by the first
+// element of p that is a fork, like deneb in mainnet/deneb/sanity/blocks.
+func VersionFromPath(p string) (clparams2.StateVersion, error) {
+	for _, elem := range strings.Split(path.Clean(p), "/") {
+		if version, err := clparams2.StringToClVersion(elem); err == nil {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("no fork in fixture path %s", p)
+}
+
+// ReadBeaconStateAutoVersion is ReadBeaconState for the fork found in name by
+// VersionFromPath.
+func ReadBeaconStateAutoVersion(root fs.FS, name string) (*state.CachingBeaconState, error) {
+	version, err := VersionFromPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return ReadBeaconState(root, version, name)
+}
+
+// ReadBlockAutoVersion is ReadBlockByPath for the fork found in name by
+// VersionFromPath.
+func ReadBlockAutoVersion(root fs.FS, name string) (*cltypes.SignedBeaconBlock, error) {
+	version, err := VersionFromPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return ReadBlockByPath(root, version, name)
+}
+
 func ReadBlock(root fs.FS, version clparams2.StateVersion, index int) (*cltypes.SignedBeaconBlock, error) {
-	var blockBytes []byte
-	var err error
-	blockBytes, err = fs.ReadFile(root, fmt.Sprintf("blocks_%d.ssz_snappy", index))
+	name, blockBytes, err := readIndexedSsz(root, "blocks", index)
 	if os.IsNotExist(err) {
 		return nil, nil
 	}
@@ -75,13 +138,47 @@ func ReadBlock(root fs.FS, version clparams2.StateVersion, index int) (*cltypes.
 		return nil, err
 	}
 	blk := cltypes.NewSignedBeaconBlock(&clparams2.MainnetBeaconConfig, version)
-	if err = utils.DecodeSSZSnappy(blk, blockBytes, int(version)); err != nil {
+	if err = decodeSsz(name, blockBytes, blk, version); err != nil {
 		return nil, err
 	}
 
 	return blk, nil
 }
 
+// ReadBlobSidecar reads the blob sidecar fixture blob_sidecar_<index>, it
+// returns nil if there is none.
+func ReadBlobSidecar(root fs.FS, version clparams2.StateVersion, index int) (*cltypes.BlobSidecar, error) {
+	name, sidecarBytes, err := readIndexedSsz(root, "blob_sidecar", index)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sidecar := &cltypes.BlobSidecar{}
+	if err = decodeSsz(name, sidecarBytes, sidecar, version); err != nil {
+		return nil, err
+	}
+	return sidecar, nil
+}
+
+// ReadExecutionPayload reads the execution payload fixture name, it returns nil
+// if there is none.
+func ReadExecutionPayload(root fs.FS, version clparams2.StateVersion, name string) (*cltypes.Eth1Block, error) {
+	payloadBytes, err := fs.ReadFile(root, name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload := cltypes.NewEth1Block(version, &clparams2.MainnetBeaconConfig)
+	if err = decodeSsz(name, payloadBytes, payload, version); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
 func ReadBlockByPath(root fs.FS, version clparams2.StateVersion, path string) (*cltypes.SignedBeaconBlock, error) {
 	var blockBytes []byte
 	var err error
@@ -93,7 +190,7 @@ func ReadBlockByPath(root fs.FS, version clparams2.StateVersion, path string) (*
 		return nil, err
 	}
 	blk := cltypes.NewSignedBeaconBlock(&clparams2.MainnetBeaconConfig, version)
-	if err = utils.DecodeSSZSnappy(blk, blockBytes, int(version)); err != nil {
+	if err = decodeSsz(path, blockBytes, blk, version); err != nil {
 		return nil, err
 	}
 
@@ -111,7 +208,7 @@ func ReadAnchorBlock(root fs.FS, version clparams2.StateVersion, name string) (*
 		return nil, err
 	}
 	blk := cltypes.NewBeaconBlock(&clparams2.MainnetBeaconConfig, version)
-	if err = utils.DecodeSSZSnappy(blk, blockBytes, int(version)); err != nil {
+	if err = decodeSsz(name, blockBytes, blk, version); err != nil {
 		return nil, err
 	}
 
@@ -119,9 +216,7 @@ func ReadAnchorBlock(root fs.FS, version clparams2.StateVersion, name string) (*
 }
 
 func ReadBlockSlot(root fs.FS, index int) (uint64, error) {
-	var blockBytes []byte
-	var err error
-	blockBytes, err = fs.ReadFile(root, fmt.Sprintf("blocks_%d.ssz_snappy", index))
+	name, blockBytes, err := readIndexedSsz(root, "blocks", index)
 	if os.IsNotExist(err) {
 		return 0, nil
 	}
@@ -129,9 +224,14 @@ func ReadBlockSlot(root fs.FS, index int) (uint64, error) {
 		return 0, err
 	}
 
-	blockBytes, err = utils.DecompressSnappy(blockBytes, false)
-	if err != nil {
-		return 0, err
+	if path.Ext(name) != ".ssz" {
+		blockBytes, err = utils.DecompressSnappy(blockBytes, false)
+		if err != nil {
+			return 0, fmt.Errorf("couldnt decompress %s: %w", name, err)
+		}
+	}
+	if len(blockBytes) < 108 {
+		return 0, fmt.Errorf("couldnt read the slot of %s: %d bytes", name, len(blockBytes))
 	}
 	return ssz.UnmarshalUint64SSZ(blockBytes[100:108]), nil
 }
@@ -140,13 +240,14 @@ func ReadBlocks(root fs.FS, version clparams2.StateVersion) ([]*cltypes.SignedBe
 	blocks := []*cltypes.SignedBeaconBlock{}
 	var err error
 	for {
+		var name string
 		var blockBytes []byte
-		blockBytes, err = fs.ReadFile(root, fmt.Sprintf("blocks_%d.ssz_snappy", i))
+		name, blockBytes, err = readIndexedSsz(root, "blocks", i)
 		if err != nil {
 			break
 		}
 		blk := cltypes.NewSignedBeaconBlock(&clparams2.MainnetBeaconConfig, version)
-		if err = utils.DecodeSSZSnappy(blk, blockBytes, int(version)); err != nil {
+		if err = decodeSsz(name, blockBytes, blk, version); err != nil {
 			return nil, err
 		}
 		blocks = append(blocks, blk)
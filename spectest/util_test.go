@@ -0,0 +1,153 @@
+package spectest
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/cl/cltypes/solid"
+	"github.com/erigontech/erigon/cl/utils"
+)
+
+func TestReadSszRawAndSnappy(t *testing.T) {
+	compressed, err := os.ReadFile("../cl/transition/impl/eth2/statechange/test_data/block_processing/capella_block.ssz_snappy")
+	require.NoError(t, err)
+	raw, err := utils.DecompressSnappy(compressed, false)
+	require.NoError(t, err)
+	root := fstest.MapFS{
+		"object.ssz_snappy":   {Data: compressed},
+		"object.ssz":          {Data: raw},
+		"blocks_0.ssz_snappy": {Data: compressed},
+		"blocks_1.ssz":        {Data: raw},
+	}
+	blk := cltypes.NewSignedBeaconBlock(&clparams.MainnetBeaconConfig, clparams.CapellaVersion)
+	require.NoError(t, utils.DecodeSSZSnappy(blk, compressed, int(clparams.CapellaVersion)))
+	want, err := blk.HashSSZ()
+	require.NoError(t, err)
+
+	for _, name := range []string{"object.ssz_snappy", "object.ssz"} {
+		got := cltypes.NewSignedBeaconBlock(&clparams.MainnetBeaconConfig, clparams.CapellaVersion)
+		require.NoError(t, ReadSsz(root, clparams.CapellaVersion, name, got), name)
+		hash, err := got.HashSSZ()
+		require.NoError(t, err)
+		require.Equal(t, want, hash, name)
+	}
+
+	blocks, err := ReadBlocks(root, clparams.CapellaVersion)
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	for i, got := range blocks {
+		hash, err := got.HashSSZ()
+		require.NoError(t, err)
+		require.Equal(t, want, hash, i)
+
+		slot, err := ReadBlockSlot(root, i)
+		require.NoError(t, err)
+		require.Equal(t, blk.Block.Slot, slot, i)
+	}
+}
+
+func TestReadBlobSidecar(t *testing.T) {
+	sidecar := &cltypes.BlobSidecar{
+		Index:                    3,
+		SignedBlockHeader:        &cltypes.SignedBeaconBlockHeader{Header: &cltypes.BeaconBlockHeader{Slot: 42}},
+		CommitmentInclusionProof: solid.NewHashVector(cltypes.CommitmentBranchSize),
+	}
+	sidecar.Blob[0] = 1
+	sidecar.KzgCommitment[0] = 2
+	raw, err := sidecar.EncodeSSZ(nil)
+	require.NoError(t, err)
+	root := fstest.MapFS{
+		"blob_sidecar_0.ssz_snappy": {Data: utils.CompressSnappy(raw)},
+		"blob_sidecar_1.ssz":        {Data: raw},
+	}
+	want, err := sidecar.HashSSZ()
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		got, err := ReadBlobSidecar(root, clparams.DenebVersion, i)
+		require.NoError(t, err)
+		hash, err := got.HashSSZ()
+		require.NoError(t, err)
+		require.Equal(t, want, hash, i)
+	}
+
+	got, err := ReadBlobSidecar(root, clparams.DenebVersion, 2)
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestReadErrorsNameTheFile(t *testing.T) {
+	root := fstest.MapFS{
+		"pre.ssz_snappy":    {Data: utils.CompressSnappy([]byte{1, 2, 3})},
+		"blocks_0.ssz":      {Data: []byte{1, 2, 3}},
+		"object.ssz_snappy": {Data: []byte("not snappy")},
+		"meta.yaml":         {Data: []byte("{")},
+	}
+	_, err := ReadBeaconState(root, clparams.DenebVersion, "pre.ssz_snappy")
+	require.ErrorContains(t, err, "pre.ssz_snappy")
+	_, err = ReadBlock(root, clparams.DenebVersion, 0)
+	require.ErrorContains(t, err, "blocks_0.ssz")
+	_, err = ReadBlockSlot(root, 0)
+	require.ErrorContains(t, err, "blocks_0.ssz")
+	err = ReadSsz(root, clparams.DenebVersion, "object.ssz_snappy", &cltypes.BlobSidecar{})
+	require.ErrorContains(t, err, "object.ssz_snappy")
+	err = ReadMeta(root, "meta.yaml", &map[string]any{})
+	require.ErrorContains(t, err, "meta.yaml")
+	err = ReadYml(root, "missing.yaml", &map[string]any{})
+	require.ErrorContains(t, err, "missing.yaml")
+
+	// a missing state is still reported as such
+	_, err = ReadBeaconState(root, clparams.DenebVersion, "post.ssz_snappy")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestVersionFromPath(t *testing.T) {
+	for p, want := range map[string]clparams.StateVersion{
+		"mainnet/phase0/sanity/blocks/pyspec_tests/empty/pre.ssz_snappy": clparams.Phase0Version,
+		"tests/mainnet/deneb/operations/deposit":                          clparams.DenebVersion,
+		"mainnet/electra/fork/fork/pyspec_tests/base/post.ssz_snappy":     clparams.ElectraVersion,
+	} {
+		version, err := VersionFromPath(p)
+		require.NoError(t, err, p)
+		require.Equal(t, want, version, p)
+	}
+	_, err := VersionFromPath("mainnet/whisk/sanity")
+	require.Error(t, err)
+
+	compressed, err := os.ReadFile("../cl/transition/impl/eth2/statechange/test_data/block_processing/capella_block.ssz_snappy")
+	require.NoError(t, err)
+	root := fstest.MapFS{"mainnet/capella/sanity/blocks_0.ssz_snappy": {Data: compressed}}
+	blk, err := ReadBlockAutoVersion(root, "mainnet/capella/sanity/blocks_0.ssz_snappy")
+	require.NoError(t, err)
+	require.Equal(t, clparams.CapellaVersion, blk.Version())
+	_, err = ReadBlockAutoVersion(root, "blocks_0.ssz_snappy")
+	require.Error(t, err)
+}
+
+func TestReadExecutionPayload(t *testing.T) {
+	compressed, err := os.ReadFile("../cl/transition/impl/eth2/statechange/test_data/block_processing/capella_block.ssz_snappy")
+	require.NoError(t, err)
+	blk := cltypes.NewSignedBeaconBlock(&clparams.MainnetBeaconConfig, clparams.CapellaVersion)
+	require.NoError(t, utils.DecodeSSZSnappy(blk, compressed, int(clparams.CapellaVersion)))
+	payload := blk.Block.Body.ExecutionPayload
+	raw, err := payload.EncodeSSZ(nil)
+	require.NoError(t, err)
+	root := fstest.MapFS{"execution_payload.ssz_snappy": {Data: utils.CompressSnappy(raw)}}
+
+	got, err := ReadExecutionPayload(root, clparams.CapellaVersion, "execution_payload.ssz_snappy")
+	require.NoError(t, err)
+	want, err := payload.HashSSZ()
+	require.NoError(t, err)
+	hash, err := got.HashSSZ()
+	require.NoError(t, err)
+	require.Equal(t, want, hash)
+	require.Equal(t, payload.BlockNumber, got.BlockNumber)
+
+	got, err = ReadExecutionPayload(root, clparams.CapellaVersion, "missing.ssz_snappy")
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
@@ -0,0 +1,152 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/types"
+)
+
+// diffGenesis lists the differences between the stored genesis and the given
+// one: top-level and config fields, then added, removed and changed accounts.
+func diffGenesis(stored, genesis *types.Genesis) ([]string, error) {
+	storedFields, err := genesisFields(stored)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := genesisFields(genesis)
+	if err != nil {
+		return nil, err
+	}
+	diffs := diffFields("", storedFields, fields)
+
+	storedConfig, err := jsonFields(stored.Config)
+	if err != nil {
+		return nil, err
+	}
+	config, err := jsonFields(genesis.Config)
+	if err != nil {
+		return nil, err
+	}
+	diffs = append(diffs, diffFields("config.", storedConfig, config)...)
+
+	allocDiffs, err := diffAlloc(stored.Alloc, genesis.Alloc)
+	if err != nil {
+		return nil, err
+	}
+	return append(diffs, allocDiffs...), nil
+}
+
+// genesisFields returns the top-level fields of a genesis but the config and the alloc.
+func genesisFields(g *types.Genesis) (map[string]json.RawMessage, error) {
+	header := *g
+	header.Config, header.Alloc = nil, nil
+	fields, err := jsonFields(&header)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "config")
+	delete(fields, "alloc")
+	return fields, nil
+}
+
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if string(data) == "null" {
+		return fields, nil
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func diffFields(prefix string, stored, fields map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(stored)+len(fields))
+	for key := range stored {
+		keys = append(keys, key)
+	}
+	for key := range fields {
+		if _, ok := stored[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var diffs []string
+	for _, key := range keys {
+		old, hadOld := stored[key]
+		value, hasValue := fields[key]
+		switch {
+		case !hadOld:
+			diffs = append(diffs, fmt.Sprintf("%s%s: added %s", prefix, key, value))
+		case !hasValue:
+			diffs = append(diffs, fmt.Sprintf("%s%s: removed %s", prefix, key, old))
+		case !bytes.Equal(old, value):
+			diffs = append(diffs, fmt.Sprintf("%s%s: %s -> %s", prefix, key, old, value))
+		}
+	}
+	return diffs
+}
+
+func diffAlloc(stored, alloc types.GenesisAlloc) ([]string, error) {
+	addrs := make([]common.Address, 0, len(stored)+len(alloc))
+	for addr := range stored {
+		addrs = append(addrs, addr)
+	}
+	for addr := range alloc {
+		if _, ok := stored[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.SortFunc(addrs, func(a, b common.Address) int { return bytes.Compare(a[:], b[:]) })
+
+	var diffs []string
+	for _, addr := range addrs {
+		old, hadOld := stored[addr]
+		account, hasAccount := alloc[addr]
+		switch {
+		case !hadOld:
+			diffs = append(diffs, fmt.Sprintf("alloc %x: added", addr))
+		case !hasAccount:
+			diffs = append(diffs, fmt.Sprintf("alloc %x: removed", addr))
+		default:
+			// Compare the encodings, big.Int values can't be compared deeply.
+			oldJSON, err := json.Marshal(old)
+			if err != nil {
+				return nil, err
+			}
+			accountJSON, err := json.Marshal(account)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(oldJSON, accountJSON) {
+				diffs = append(diffs, fmt.Sprintf("alloc %x: changed", addr))
+			}
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/types"
+	"github.com/erigontech/erigon/core"
+)
+
+func TestDiffGenesis(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	require.NoError(t, core.WriteGenesisIfNotExist(tx, testGenesis()))
+	stored, err := core.ReadGenesis(tx)
+	require.NoError(t, err)
+
+	diffs, err := diffGenesis(stored, testGenesis())
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+
+	genesis := testGenesis()
+	genesis.GasLimit = 60_000_000
+	configJSON, err := json.Marshal(genesis.Config)
+	require.NoError(t, err)
+	genesis.Config = new(chain.Config)
+	require.NoError(t, json.Unmarshal(configJSON, genesis.Config))
+	genesis.Config.ChainID = big.NewInt(42)
+	delete(genesis.Alloc, common.HexToAddress("0x01"))
+	genesis.Alloc[common.HexToAddress("0x02")] = types.GenesisAccount{Balance: big.NewInt(2)}
+	genesis.Alloc[common.HexToAddress("0x03")] = types.GenesisAccount{Balance: big.NewInt(3)}
+
+	diffs, err = diffGenesis(stored, genesis)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`gasLimit: "0x1c9c380" -> "0x3938700"`,
+		`config.chainId: 1337 -> 42`,
+		"alloc 0000000000000000000000000000000000000001: removed",
+		"alloc 0000000000000000000000000000000000000002: changed",
+		"alloc 0000000000000000000000000000000000000003: added",
+	}, diffs)
+}
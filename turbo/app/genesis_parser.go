@@ -0,0 +1,392 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/types"
+)
+
+const (
+	// genesisAllocLogEvery is how often the streaming parser reports its progress.
+	genesisAllocLogEvery = 1_000_000
+	// genesisAllocBatch is how many undecoded accounts may be queued per worker.
+	genesisAllocBatch = 64
+
+	// genesisURLTimeout and genesisURLMaxSize bound the download of a genesis
+	// given as a URL.
+	genesisURLTimeout = 10 * time.Minute
+	genesisURLMaxSize = 16 << 30
+)
+
+var (
+	errGenesisTooLarge = errors.New("genesis exceeds the size limit")
+
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// openGenesis opens the genesis file at path, which may also be an http(s) URL.
+// Gzip and zstd compressed files are recognised by their magic bytes and
+// transparently decompressed while being read, so they never have to be
+// inflated on disk or in memory.
+func openGenesis(path string) (io.ReadCloser, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		body, err := openGenesisURL(path, genesisURLTimeout, genesisURLMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		r, err := decompressGenesis(body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		return r, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := decompressGenesis(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openGenesisURL downloads a genesis, the body is streamed to the caller rather
+// than buffered. The timeout covers the whole download and at most maxSize bytes
+// are read from the body.
+func openGenesisURL(url string, timeout time.Duration, maxSize int64) (io.ReadCloser, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("genesis at %s is %d bytes, larger than the limit of %d bytes", url, resp.ContentLength, maxSize)
+	}
+	return &genesisReader{Reader: &cappedReader{r: resp.Body, left: maxSize}, closers: []io.Closer{resp.Body}}, nil
+}
+
+// cappedReader fails once more than the allowed number of bytes have been read,
+// unlike io.LimitReader which would silently truncate the genesis.
+type cappedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.left < 0 {
+		return 0, errGenesisTooLarge
+	}
+	if int64(len(p)) > c.left+1 {
+		p = p[:c.left+1]
+	}
+	n, err := c.r.Read(p)
+	c.left -= int64(n)
+	if c.left < 0 {
+		return n, errGenesisTooLarge
+	}
+	return n, err
+}
+
+// decompressGenesis wraps rc with a decompressor matching its content. Closing
+// the result closes rc.
+func decompressGenesis(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return &genesisReader{Reader: zr, closers: []io.Closer{zr, rc}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		return &genesisReader{Reader: zr, closers: []io.Closer{zr.IOReadCloser(), rc}}, nil
+	default:
+		return &genesisReader{Reader: br, closers: []io.Closer{rc}}, nil
+	}
+}
+
+// genesisReader reads a possibly decompressed genesis and closes the whole
+// chain of readers it was built from.
+type genesisReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *genesisReader) Close() error {
+	var errs []error
+	for _, c := range r.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// parseGenesisStreaming decodes a genesis JSON document from r into genesis.
+// Unlike a plain json.Decoder.Decode, the alloc object is decoded one account
+// at a time, so only the resulting accounts are kept in memory and never the
+// raw JSON of the whole alloc.
+func parseGenesisStreaming(r io.Reader, genesis *types.Genesis, logger log.Logger) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	var alloc types.GenesisAlloc
+	// Everything but the alloc is small, it goes through the regular unmarshaller.
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		key, err := readKey(dec)
+		if err != nil {
+			return err
+		}
+		if key != "alloc" {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return fmt.Errorf("field %q: %w", key, err)
+			}
+			fields[key] = value
+			continue
+		}
+		if alloc, err = parseGenesisAllocStreaming(dec, logger); err != nil {
+			return fmt.Errorf("alloc: %w", err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if alloc == nil {
+		return errors.New("missing required field 'alloc' for Genesis")
+	}
+
+	fields["alloc"] = json.RawMessage("{}")
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(rest, genesis); err != nil {
+		return err
+	}
+	genesis.Alloc = alloc
+	return nil
+}
+
+// rawGenesisAccount is an alloc entry read from the decoder but not decoded yet.
+type rawGenesisAccount struct {
+	key     string
+	addr    common.Address
+	account json.RawMessage
+}
+
+// parseGenesisAllocStreaming decodes the alloc object the decoder is positioned at.
+// Reading the JSON is sequential, but decoding the accounts (hex balances, code
+// and storage) is CPU bound, so it is spread over a bounded pool of workers.
+func parseGenesisAllocStreaming(dec *json.Decoder, logger log.Logger) (types.GenesisAlloc, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var (
+		alloc   = make(types.GenesisAlloc)
+		allocMu sync.Mutex
+		workers = runtime.GOMAXPROCS(0)
+		raws    = make(chan rawGenesisAccount, workers*genesisAllocBatch)
+	)
+	g, ctx := errgroup.WithContext(context.Background())
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for raw := range raws {
+				account, err := decodeAllocAccount(raw.account)
+				if err != nil {
+					return fmt.Errorf("account %q: %w", raw.key, err)
+				}
+				allocMu.Lock()
+				alloc[raw.addr] = account
+				if len(alloc)%genesisAllocLogEvery == 0 {
+					logger.Info("Parsing genesis alloc", "accounts", len(alloc))
+				}
+				allocMu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	readErr := func() error {
+		defer close(raws)
+		// Keys are checked in file order, so that spellings of the same address
+		// (case, 0x prefix) are rejected the same way on every run.
+		keys := make(map[common.Address]string)
+		for dec.More() {
+			key, err := readKey(dec)
+			if err != nil {
+				return err
+			}
+			addr, err := parseAllocAddress(key)
+			if err != nil {
+				return err
+			}
+			if first, ok := keys[addr]; ok {
+				return fmt.Errorf("duplicate account %q, already allocated as %q", key, first)
+			}
+			keys[addr] = key
+			var account json.RawMessage
+			if err := dec.Decode(&account); err != nil {
+				return fmt.Errorf("account %q: %w", key, err)
+			}
+			select {
+			case raws <- rawGenesisAccount{key: key, addr: addr, account: account}:
+			case <-ctx.Done():
+				return nil // the worker error is reported below
+			}
+		}
+		return expectDelim(dec, '}')
+	}()
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return alloc, nil
+}
+
+// allocAccountBase64 holds the base64 alternatives to the hex encoded code
+// fields, which keep genesis files with a lot of contracts much smaller.
+type allocAccountBase64 struct {
+	CodeBase64        *string `json:"codeBase64"`
+	ConstructorBase64 *string `json:"constructorBase64"`
+}
+
+var base64FieldSuffix = []byte(`Base64"`)
+
+// decodeAllocAccount decodes an alloc account, taking its code and constructor
+// from the base64 fields when they are set.
+func decodeAllocAccount(raw json.RawMessage) (types.GenesisAccount, error) {
+	var account types.GenesisAccount
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return account, err
+	}
+	// Most accounts have no base64 fields, don't decode those twice.
+	if !bytes.Contains(raw, base64FieldSuffix) {
+		return account, nil
+	}
+	var b64 allocAccountBase64
+	if err := json.Unmarshal(raw, &b64); err != nil {
+		return account, err
+	}
+	if b64.CodeBase64 != nil {
+		if len(account.Code) > 0 {
+			return account, errors.New("both code and codeBase64 are set")
+		}
+		code, err := base64.StdEncoding.DecodeString(*b64.CodeBase64)
+		if err != nil {
+			return account, fmt.Errorf("codeBase64: %w", err)
+		}
+		account.Code = code
+	}
+	if b64.ConstructorBase64 != nil {
+		if len(account.Constructor) > 0 {
+			return account, errors.New("both constructor and constructorBase64 are set")
+		}
+		constructor, err := base64.StdEncoding.DecodeString(*b64.ConstructorBase64)
+		if err != nil {
+			return account, fmt.Errorf("constructorBase64: %w", err)
+		}
+		account.Constructor = constructor
+	}
+	return account, nil
+}
+
+// parseAllocAddress parses an alloc key, which must be exactly 20 hex encoded
+// bytes with an optional 0x prefix. Mixed-case keys must carry a valid EIP-55
+// checksum, so that copy-paste mistakes are caught instead of silently funding
+// another account.
+func parseAllocAddress(key string) (common.Address, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(key, "0x"), "0X")
+	if len(digits) != 2*length.Addr {
+		return common.Address{}, fmt.Errorf("invalid alloc address %q: has %d hex digits, want %d", key, len(digits), 2*length.Addr)
+	}
+	var addr common.Address
+	if _, err := hex.Decode(addr[:], []byte(digits)); err != nil {
+		return common.Address{}, fmt.Errorf("invalid alloc address %q: %w", key, err)
+	}
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && "0x"+digits != addr.Hex() {
+		return common.Address{}, fmt.Errorf("invalid alloc address %q: bad EIP-55 checksum, want %s", key, addr.Hex())
+	}
+	return addr, nil
+}
+
+func readKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected token %v, expected object key", tok)
+	}
+	return key, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected token %v, expected %v", tok, delim)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/types"
+	"github.com/erigontech/erigon/core"
+)
+
+func testGenesis() *types.Genesis {
+	return &types.Genesis{
+		Config:     chain.AllProtocolChanges,
+		Timestamp:  1234,
+		ExtraData:  []byte{1, 2, 3},
+		GasLimit:   30_000_000,
+		Difficulty: big.NewInt(1),
+		Alloc: types.GenesisAlloc{
+			common.HexToAddress("0x01"): {Balance: big.NewInt(1)},
+			common.HexToAddress("0x02"): {
+				Balance: big.NewInt(1_000_000_000),
+				Nonce:   7,
+				Code:    []byte{0x60, 0x00, 0x56},
+				Storage: map[common.Hash]common.Hash{common.HexToHash("0x01"): common.HexToHash("0x02")},
+			},
+		},
+	}
+}
+
+func TestParseGenesisStreaming(t *testing.T) {
+	data, err := json.Marshal(testGenesis())
+	require.NoError(t, err)
+
+	var expected types.Genesis
+	require.NoError(t, json.Unmarshal(data, &expected))
+
+	var genesis types.Genesis
+	require.NoError(t, parseGenesisStreaming(bytes.NewReader(data), &genesis, log.New()))
+	require.Equal(t, expected, genesis)
+}
+
+func TestParseGenesisStreamingMalformed(t *testing.T) {
+	for name, input := range map[string]string{
+		"not an object": `[]`,
+		"missing alloc": `{"gasLimit": "0x1", "difficulty": "0x1"}`,
+		"bad address":   `{"gasLimit": "0x1", "difficulty": "0x1", "alloc": {"0xzz": {"balance": "0x1"}}}`,
+		"bad account":   `{"gasLimit": "0x1", "difficulty": "0x1", "alloc": {"0x01": {"balance": []}}}`,
+		"truncated":     `{"gasLimit": "0x1", "difficulty": "0x1", "alloc": {"0x01": {"balance": "0x1"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var genesis types.Genesis
+			require.Error(t, parseGenesisStreaming(strings.NewReader(input), &genesis, log.New()))
+		})
+	}
+}
+
+func TestParseGenesisStreamingDuplicateAccount(t *testing.T) {
+	// the same address, once lower case with a prefix and once upper case without
+	input := `{"gasLimit": "0x1", "difficulty": "0x1", "alloc": {
+		"0x00000000000000000000000000000000000000ab": {"balance": "0x1"},
+		"00000000000000000000000000000000000000AB": {"balance": "0x2"}
+	}}`
+	var genesis types.Genesis
+	err := parseGenesisStreaming(strings.NewReader(input), &genesis, log.New())
+	require.ErrorContains(t, err, `duplicate account "00000000000000000000000000000000000000AB", already allocated as "0x00000000000000000000000000000000000000ab"`)
+}
+
+func TestOpenGenesisCompressed(t *testing.T) {
+	data, err := json.Marshal(testGenesis())
+	require.NoError(t, err)
+
+	var gz, zst bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err = gw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	zw, err := zstd.NewWriter(&zst)
+	require.NoError(t, err)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	dir := t.TempDir()
+	readGenesis := func(name string, content []byte) *types.Genesis {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+		r, err := openGenesis(path)
+		require.NoError(t, err)
+		defer r.Close()
+		genesis := new(types.Genesis)
+		require.NoError(t, parseGenesisStreaming(r, genesis, log.New()))
+		return genesis
+	}
+	blockHash := func(genesis *types.Genesis) common.Hash {
+		block, _, err := core.GenesisToBlock(genesis, datadir.New(t.TempDir()), log.New())
+		require.NoError(t, err)
+		return block.Hash()
+	}
+
+	plain := readGenesis("genesis.json", data)
+	expected := blockHash(plain)
+	for name, content := range map[string][]byte{
+		"genesis.json.gz":  gz.Bytes(),
+		"genesis.json.zst": zst.Bytes(),
+		// the format is sniffed from the content, not from the extension
+		"genesis.json": gz.Bytes(),
+	} {
+		genesis := readGenesis(name, content)
+		require.Equal(t, plain, genesis, name)
+		require.Equal(t, expected, blockHash(genesis), name)
+	}
+}
+
+func TestOpenGenesisURL(t *testing.T) {
+	data, err := json.Marshal(testGenesis())
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/genesis.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	r, err := openGenesis(srv.URL + "/genesis.json")
+	require.NoError(t, err)
+	defer r.Close()
+	var genesis types.Genesis
+	require.NoError(t, parseGenesisStreaming(r, &genesis, log.New()))
+	require.Equal(t, testGenesis().Alloc, genesis.Alloc)
+	_, _, err = core.GenesisToBlock(&genesis, datadir.New(t.TempDir()), log.New())
+	require.NoError(t, err)
+
+	_, err = openGenesis(srv.URL + "/missing.json")
+	require.ErrorContains(t, err, "404")
+
+	_, err = openGenesisURL(srv.URL+"/genesis.json", time.Minute, int64(len(data)-1))
+	require.ErrorContains(t, err, "larger than the limit")
+}
+
+func TestCappedReader(t *testing.T) {
+	data := bytes.Repeat([]byte{'x'}, 100)
+
+	read, err := io.ReadAll(&cappedReader{r: bytes.NewReader(data), left: 100})
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+
+	_, err = io.ReadAll(&cappedReader{r: bytes.NewReader(data), left: 99})
+	require.ErrorIs(t, err, errGenesisTooLarge)
+}
+
+func TestParseAllocAddress(t *testing.T) {
+	addr := common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	for _, key := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		"0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED",
+	} {
+		parsed, err := parseAllocAddress(key)
+		require.NoError(t, err, key)
+		require.Equal(t, addr, parsed, key)
+	}
+
+	for key, msg := range map[string]string{
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beae":   "has 39 hex digits",
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed0": "has 41 hex digits",
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaeg":  "invalid byte",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD":  "bad EIP-55 checksum",
+	} {
+		_, err := parseAllocAddress(key)
+		require.ErrorContains(t, err, msg, key)
+		require.ErrorContains(t, err, key)
+	}
+}
+
+func TestParseGenesisStreamingBase64Code(t *testing.T) {
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	genesisJSON := func(account string) string {
+		return `{"config": {"chainId": 1337}, "gasLimit": "0x1c9c380", "difficulty": "0x1", "alloc": {"0000000000000000000000000000000000000001": ` + account + `}}`
+	}
+	parse := func(account string) (*types.Genesis, error) {
+		genesis := new(types.Genesis)
+		return genesis, parseGenesisStreaming(strings.NewReader(genesisJSON(account)), genesis, log.New())
+	}
+	hexGenesis, err := parse(fmt.Sprintf(`{"balance": "0x1", "code": "0x%x"}`, code))
+	require.NoError(t, err)
+	b64Genesis, err := parse(fmt.Sprintf(`{"balance": "0x1", "codeBase64": %q}`, base64.StdEncoding.EncodeToString(code)))
+	require.NoError(t, err)
+	require.Equal(t, hexGenesis, b64Genesis)
+
+	hexBlock, _, err := core.GenesisToBlock(hexGenesis, datadir.New(t.TempDir()), log.New())
+	require.NoError(t, err)
+	b64Block, _, err := core.GenesisToBlock(b64Genesis, datadir.New(t.TempDir()), log.New())
+	require.NoError(t, err)
+	require.Equal(t, hexBlock.Root(), b64Block.Root())
+
+	b64Genesis, err = parse(fmt.Sprintf(`{"balance": "0x1", "constructorBase64": %q}`, base64.StdEncoding.EncodeToString(code)))
+	require.NoError(t, err)
+	require.Equal(t, code, b64Genesis.Alloc[common.HexToAddress("0x01")].Constructor)
+
+	_, err = parse(fmt.Sprintf(`{"balance": "0x1", "code": "0x%x", "codeBase64": %q}`, code, base64.StdEncoding.EncodeToString(code)))
+	require.ErrorContains(t, err, "both code and codeBase64 are set")
+	_, err = parse(`{"balance": "0x1", "codeBase64": "!"}`)
+	require.ErrorContains(t, err, "codeBase64")
+}
+
+// writeSyntheticGenesis writes a genesis with the given number of funded accounts.
+func writeSyntheticGenesis(t testing.TB, path string, accounts int) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprint(w, `{"config": {"chainId": 1337}, "gasLimit": "0x1c9c380", "difficulty": "0x1", "alloc": {`)
+	for i := 0; i < accounts; i++ {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, `"%x": {"balance": "0x%x"}`, common.BigToAddress(big.NewInt(int64(i+1))), i+1)
+	}
+	fmt.Fprint(w, `}}`)
+	require.NoError(t, w.Flush())
+}
+
+func TestParseGenesisStreamingLargeAlloc(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	const accounts = 200_000
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	writeSyntheticGenesis(t, path, accounts)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// A low GC target keeps the heap close to the live data, so the peak below
+	// is not dominated by the collector's headroom.
+	defer debug.SetGCPercent(debug.SetGCPercent(10))
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	var genesis types.Genesis
+	require.NoError(t, parseGenesisStreaming(f, &genesis, log.New()))
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	require.Len(t, genesis.Alloc, accounts)
+	require.Equal(t, big.NewInt(accounts), genesis.Alloc[common.BigToAddress(big.NewInt(accounts))].Balance)
+	// HeapSys follows the peak heap usage, which is bounded by the decoded
+	// accounts (and the growth of their map) rather than by the raw JSON
+	peak := int64(after.HeapSys) - int64(before.HeapSys)
+	retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	require.Less(t, peak, 2*retained, "peak heap %d bytes for %d bytes of accounts parsed from a %d bytes genesis", peak, retained, info.Size())
+	runtime.KeepAlive(genesis)
+}
+
+func BenchmarkParseGenesisStreaming(b *testing.B) {
+	const accounts = 1_000_000
+	path := filepath.Join(b.TempDir(), "genesis.json")
+	writeSyntheticGenesis(b, path, accounts)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(path)
+		require.NoError(b, err)
+		var genesis types.Genesis
+		require.NoError(b, parseGenesisStreaming(f, &genesis, log.New()))
+		require.Len(b, genesis.Alloc, accounts)
+		f.Close()
+	}
+}
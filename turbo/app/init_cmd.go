@@ -17,12 +17,13 @@
 package app
 
 import (
-	"encoding/json"
+	"fmt"
 	"os"
 	"runtime/pprof"
 
 	"github.com/urfave/cli/v2"
 
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/log/v3"
@@ -42,6 +43,9 @@ var initCommand = cli.Command{
 	Flags: []cli.Flag{
 		&utils.DataDirFlag,
 		&utils.ChainFlag,
+		&initAllocProfileFlag,
+		&initExpectedHashFlag,
+		&initDiffFlag,
 	},
 	//Category: "BLOCKCHAIN COMMANDS",
 	Description: `
@@ -49,7 +53,28 @@ The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It expects the genesis file as argument, either a path or an http(s) URL. The
+file may be gzip or zstd compressed.`,
+}
+
+// initAllocProfileFlag lets maintainers diagnose the memory usage of big genesis
+// files, together with the pprof HTTP server enabled by --pprof.
+var initAllocProfileFlag = cli.StringFlag{
+	Name:  "pprof.allocprofile",
+	Usage: "Write the allocation profile to the given file once the genesis is written",
+}
+
+// initExpectedHashFlag guards coordinated launches, where every node must start
+// from exactly the same genesis.
+var initExpectedHashFlag = cli.StringFlag{
+	Name:  "expected-hash",
+	Usage: "Fail without writing anything if the hash of the genesis block differs from the given one",
+}
+
+// initDiffFlag shows what a re-init would change instead of doing it.
+var initDiffFlag = cli.BoolFlag{
+	Name:  "diff",
+	Usage: "Compare the genesis file with the genesis stored in the database and exit without writing anything",
 }
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -67,23 +92,23 @@ func initGenesis(cliCtx *cli.Context) error {
 		utils.Fatalf("Must supply path to genesis JSON file")
 	}
 
-	file, err := os.Open(genesisPath)
+	file, err := openGenesis(genesisPath)
 	if err != nil {
 		utils.Fatalf("Failed to read genesis file: %v", err)
 	}
 	defer file.Close()
 
 	genesis := new(types.Genesis)
-	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+	if err := parseGenesisStreaming(file, genesis, logger); err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
-	if allocFile, err := os.Create("initgenesis_alloc_final.prof"); err == nil {
-		pprof.Lookup("allocs").WriteTo(allocFile, 0)
-		allocFile.Close()
-		logger.Info("Allocation profile saved", "stage", "final", "file", "initgenesis_alloc_final.prof")
+
+	// Check the hash before the datadir is touched, a wrong genesis must not be written
+	if expected := cliCtx.String(initExpectedHashFlag.Name); expected != "" {
+		if err := verifyGenesisHash(genesis, expected, logger); err != nil {
+			return fmt.Errorf("unexpected genesis: %w", err)
+		}
 	}
-	// DEBUG: just test json decode to save time
-	return nil
 
 	// Open and initialise both full and light databases
 	stack, err := MakeNodeWithDefaultConfig(cliCtx, logger)
@@ -92,6 +117,10 @@ func initGenesis(cliCtx *cli.Context) error {
 	}
 	defer stack.Close()
 
+	if cliCtx.Bool(initDiffFlag.Name) {
+		return diffStoredGenesis(cliCtx, stack, genesis, logger)
+	}
+
 	chaindb, err := node.OpenDatabase(cliCtx.Context, stack.Config(), kv.ChainDB, "", false, logger)
 	if err != nil {
 		utils.Fatalf("Failed to open database: %v", err)
@@ -108,5 +137,86 @@ func initGenesis(cliCtx *cli.Context) error {
 	}
 	chaindb.Close()
 	logger.Info("Successfully wrote genesis state", "hash", hash.Hash())
+
+	if profilePath := cliCtx.String(initAllocProfileFlag.Name); profilePath != "" {
+		if err := writeAllocProfile(profilePath); err != nil {
+			return err
+		}
+		logger.Info("Allocation profile saved", "file", profilePath)
+	}
+	return nil
+}
+
+// diffStoredGenesis logs how genesis differs from the genesis committed to the chain database.
+func diffStoredGenesis(cliCtx *cli.Context, stack *node.Node, genesis *types.Genesis, logger log.Logger) error {
+	chaindb, err := node.OpenDatabase(cliCtx.Context, stack.Config(), kv.ChainDB, "", true, logger)
+	if err != nil {
+		utils.Fatalf("Failed to open database: %v", err)
+	}
+	defer chaindb.Close()
+
+	var stored *types.Genesis
+	if err := chaindb.View(cliCtx.Context, func(tx kv.Tx) error {
+		stored, err = core.ReadGenesis(tx)
+		return err
+	}); err != nil {
+		utils.Fatalf("Failed to read the stored genesis: %v", err)
+	}
+	if stored == nil {
+		logger.Info("No genesis stored in the database")
+		return nil
+	}
+
+	diffs, err := diffGenesis(stored, genesis)
+	if err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		logger.Info("Genesis differs", "diff", diff)
+	}
+	if len(diffs) == 0 {
+		logger.Info("Genesis file matches the stored genesis")
+	} else {
+		logger.Warn("Genesis file differs from the stored genesis", "differences", len(diffs))
+	}
+	return nil
+}
+
+// verifyGenesisHash computes the genesis block in a temporary directory and checks
+// its hash against expected.
+func verifyGenesisHash(genesis *types.Genesis, expected string, logger log.Logger) error {
+	tmpDir, err := os.MkdirTemp("", "erigon-genesis-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	block, _, err := core.GenesisToBlock(genesis, datadir.New(tmpDir), logger)
+	if err != nil {
+		return err
+	}
+	return checkGenesisHash(expected, block.Hash())
+}
+
+// checkGenesisHash compares the genesis block hash with the hex encoded expected one.
+func checkGenesisHash(expected string, hash common.Hash) error {
+	var want common.Hash
+	if err := want.UnmarshalText([]byte(expected)); err != nil {
+		return fmt.Errorf("invalid expected hash %q: %w", expected, err)
+	}
+	if want != hash {
+		return fmt.Errorf("genesis hash mismatch: have %x, want %x", hash, want)
+	}
+	return nil
+}
+
+func writeAllocProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create allocation profile: %w", err)
+	}
+	defer f.Close()
+	if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+		return fmt.Errorf("could not write allocation profile: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,82 @@
+// Copyright 2025 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/types"
+	"github.com/erigontech/erigon/core"
+)
+
+func TestCheckGenesisHash(t *testing.T) {
+	genesisHash := func(path string) common.Hash {
+		r, err := openGenesis(path)
+		require.NoError(t, err)
+		defer r.Close()
+		var genesis types.Genesis
+		require.NoError(t, parseGenesisStreaming(r, &genesis, log.New()))
+		block, _, err := core.GenesisToBlock(&genesis, datadir.New(t.TempDir()), log.New())
+		require.NoError(t, err)
+		return block.Hash()
+	}
+	writeGenesis := func(name string, genesis *types.Genesis) string {
+		data, err := json.Marshal(genesis)
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		return path
+	}
+
+	expected := genesisHash(writeGenesis("genesis.json", testGenesis())).Hex()
+	require.NoError(t, checkGenesisHash(expected, genesisHash(writeGenesis("genesis.json", testGenesis()))))
+
+	tampered := testGenesis()
+	tampered.Alloc[common.HexToAddress("0x01")] = types.GenesisAccount{Balance: big.NewInt(2)}
+	require.ErrorContains(t, checkGenesisHash(expected, genesisHash(writeGenesis("tampered.json", tampered))), "mismatch")
+
+	require.ErrorContains(t, checkGenesisHash("0x1234", common.Hash{}), "invalid expected hash")
+}
+
+func TestInitExpectedHashMismatch(t *testing.T) {
+	data, err := json.Marshal(testGenesis())
+	require.NoError(t, err)
+	genesisPath := filepath.Join(t.TempDir(), "genesis.json")
+	require.NoError(t, os.WriteFile(genesisPath, data, 0o600))
+	dataDir := t.TempDir()
+
+	app := &cli.App{Commands: []*cli.Command{&initCommand}}
+	err = app.Run([]string{"erigon", "init", "--datadir", dataDir, "--expected-hash", common.Hash{1}.Hex(), genesisPath})
+	require.ErrorContains(t, err, "mismatch")
+
+	// nothing but the logs was written to the datadir
+	entries, err := os.ReadDir(dataDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.Equal(t, "logs", entry.Name())
+	}
+}
@@ -83,6 +83,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.RpcGasCapFlag,
 	&utils.RpcBatchLimit,
 	&utils.RpcReturnDataLimit,
+	&utils.RpcCallManyMaxBundlesFlag,
+	&utils.RpcCallManyMaxTxPerBundleFlag,
 	&utils.AllowUnprotectedTxs,
 	&utils.RPCGlobalTxFeeCapFlag,
 	&utils.TxpoolApiAddrFlag,
@@ -142,6 +144,11 @@ var DefaultFlags = []cli.Flag{
 	&utils.FakePoWFlag,
 	&utils.GpoBlocksFlag,
 	&utils.GpoPercentileFlag,
+	&utils.GpoMaxGasPriceFlag,
+	&utils.GpoMinSuggestedTipFlag,
+	&utils.GpoMaxSuggestedPriceFlag,
+	&utils.GpoBlobFeeHeadroomFlag,
+	&utils.GpoIgnoreTxTypesFlag,
 	&utils.InsecureUnlockAllowedFlag,
 	&utils.IdentityFlag,
 	&utils.CliqueSnapshotCheckpointIntervalFlag,
@@ -184,6 +191,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.CaplinCheckpointSyncUrlFlag,
 	&utils.CaplinSubscribeAllTopicsFlag,
 	&utils.CaplinMaxPeerCount,
+	&utils.CaplinMaxPendingProposerSlashingsFlag,
+	&utils.CaplinProposerSlashingQueueDepthFlag,
 	&utils.CaplinEnableUPNPlag,
 	&utils.CaplinMaxInboundTrafficPerPeerFlag,
 	&utils.CaplinMaxOutboundTrafficPerPeerFlag,
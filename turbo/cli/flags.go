@@ -473,6 +473,9 @@ func setEmbeddedRpcDaemon(ctx *cli.Context, cfg *nodecfg.Config, logger log.Logg
 		ReturnDataLimit:     ctx.Int(utils.RpcReturnDataLimit.Name),
 		AllowUnprotectedTxs: ctx.Bool(utils.AllowUnprotectedTxs.Name),
 
+		CallManyMaxBundles:     ctx.Int(utils.RpcCallManyMaxBundlesFlag.Name),
+		CallManyMaxTxPerBundle: ctx.Int(utils.RpcCallManyMaxTxPerBundleFlag.Name),
+
 		OtsMaxPageSize: ctx.Uint64(utils.OtsSearchMaxCapFlag.Name),
 
 		TxPoolApiAddr: ctx.String(utils.TxpoolApiAddrFlag.Name),